@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"pdf2jpg/internal/service"
+)
+
+const (
+	resultCacheMemory    = "memory"
+	resultCacheDisk      = "disk"
+	defaultResultCacheMB = 256
+)
+
+// newResultCache builds the conversion cache selected by RESULT_CACHE, or returns nil when caching is off.
+func newResultCache(logger *slog.Logger) (service.CacheStore, error) {
+	backend := strings.ToLower(strings.TrimSpace(os.Getenv("RESULT_CACHE")))
+	maxMB := parseIntEnv("RESULT_CACHE_MB", defaultResultCacheMB)
+	if backend == "" || maxMB <= 0 {
+		return nil, nil
+	}
+	maxBytes := megabytesToBytes(int64(maxMB))
+
+	switch backend {
+	case resultCacheMemory:
+		logger.Info("caching conversion results in memory", "max_mb", maxMB)
+		return service.NewMemoryCache(maxBytes), nil
+	case resultCacheDisk:
+		dir := strings.TrimSpace(os.Getenv("RESULT_CACHE_DIR"))
+		if dir == "" {
+			dir = filepath.Join(os.TempDir(), "pdf2jpg-cache")
+		}
+		logger.Info("caching conversion results on disk", "dir", dir, "max_mb", maxMB)
+		return service.NewDiskCache(dir, maxBytes)
+	default:
+		return nil, fmt.Errorf("unknown RESULT_CACHE backend %q", backend)
+	}
+}
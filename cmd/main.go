@@ -4,9 +4,8 @@ import (
 	"bufio"
 	"context"
 	"errors"
-	"expvar"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -18,32 +17,51 @@ import (
 	"cloud.google.com/go/firestore"
 
 	"pdf2jpg/internal/auth"
+	"pdf2jpg/internal/doctype"
 	"pdf2jpg/internal/handler"
+	"pdf2jpg/internal/jobs"
+	"pdf2jpg/internal/limiter"
+	"pdf2jpg/internal/logging"
+	"pdf2jpg/internal/metrics"
 	"pdf2jpg/internal/service"
+	"pdf2jpg/internal/urlfetch"
 )
 
 const (
 	defaultPort     = "8080"
 	maxUploadSizeMB = 10
 	shutdownTimeout = 10 * time.Second
-	jpegQuality     = 85
+	// defaultRenderRequestTimeoutSeconds stays under Cloud Run's default 300 second request timeout.
+	defaultRenderRequestTimeoutSeconds = 120
 )
 
 func main() {
-	logger := log.New(os.Stdout, "", log.LstdFlags|log.LUTC)
+	if len(os.Args) > 1 && os.Args[1] == workerCommand {
+		os.Exit(runWorker(os.Args[2:]))
+	}
 
-	if err := loadEnvFile(".env", logger); err != nil {
-		logger.Fatalf("ERROR: %v", err)
+	// The env file may set LOG_LEVEL and LOG_FORMAT, so it is loaded with a default logger first.
+	bootLogger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	if err := loadEnvFile(".env", bootLogger); err != nil {
+		fatal(bootLogger, "load env file", err)
 	}
+	logger, err := logging.New(os.Stdout, logging.Config{
+		Level:  os.Getenv("LOG_LEVEL"),
+		Format: os.Getenv("LOG_FORMAT"),
+	})
+	if err != nil {
+		fatal(bootLogger, "configure logging", err)
+	}
+	slog.SetDefault(logger)
 
 	apiKeys := parseAPIKeys(os.Getenv("API_KEYS"))
 	if len(apiKeys) == 0 {
-		logger.Fatal("missing API_KEYS environment variable")
+		fatal(logger, "missing API_KEYS environment variable", nil)
 	}
 
 	masterKeys := parseAPIKeys(os.Getenv("MASTER_API_KEYS"))
 	if len(masterKeys) == 0 {
-		logger.Fatal("missing MASTER_API_KEYS environment variable")
+		fatal(logger, "missing MASTER_API_KEYS environment variable", nil)
 	}
 
 	enableFirestore := parseBoolEnv("ENABLE_FIRESTORE_KEYS", true)
@@ -58,22 +76,21 @@ func main() {
 			firestoreProject = os.Getenv("GOOGLE_CLOUD_PROJECT")
 		}
 		if firestoreProject == "" {
-			logger.Fatal("missing FIRESTORE_PROJECT_ID environment variable")
+			fatal(logger, "missing FIRESTORE_PROJECT_ID environment variable", nil)
 		}
 		firestoreCollection := os.Getenv("FIRESTORE_COLLECTION")
 
 		ctx := context.Background()
-		var err error
 		firestoreClient, err = firestore.NewClient(ctx, firestoreProject)
 		if err != nil {
-			logger.Fatalf("ERROR: initialize firestore client: %v", err)
+			fatal(logger, "initialize firestore client", err)
 		}
 		defer firestoreClient.Close()
 
 		repo := auth.NewFirestoreRepository(firestoreClient, firestoreCollection)
 		keyService = auth.NewKeyService(repo, logger, nil, auth.ServiceConfig{})
 	} else {
-		logger.Println("INFO: Firestore-backed temporary key verification disabled")
+		logger.Info("Firestore-backed temporary key verification disabled")
 	}
 
 	port := os.Getenv("PORT")
@@ -81,18 +98,128 @@ func main() {
 		port = defaultPort
 	}
 
-	pdfService := service.NewPDFService(jpegQuality)
-	convertHandler := handler.NewConvertHandler(pdfService, logger, megabytesToBytes(maxUploadSizeMB))
+	workers, err := newWorkerPool(logger)
+	if err != nil {
+		fatal(logger, "start render workers", err)
+	}
+	if workers != nil {
+		defer workers.Close()
+	}
 
-	mux := http.NewServeMux()
-	mux.Handle("/convert", auth.APIKeyMiddleware(auth.APIKeyMiddlewareConfig{
+	resultCache, err := newResultCache(logger)
+	if err != nil {
+		fatal(logger, "configure result cache", err)
+	}
+
+	pdfService := service.NewPDFService(service.Config{
+		Quality:          parseIntEnv("OUTPUT_QUALITY", 0),
+		MinQuality:       parseIntEnv("OUTPUT_MIN_QUALITY", 0),
+		MaxQuality:       parseIntEnv("OUTPUT_MAX_QUALITY", 0),
+		DefaultDPI:       parseFloatEnv("RENDER_DEFAULT_DPI", 0),
+		MaxDPI:           parseFloatEnv("RENDER_MAX_DPI", 0),
+		MaxDimension:     parseIntEnv("RENDER_MAX_DIMENSION", 0),
+		MaxPixels:        parseIntEnv("RENDER_MAX_PIXELS", 0),
+		MaxPages:         parseIntEnv("RENDER_MAX_PAGES", 0),
+		MaxSheetPages:    parseIntEnv("CONTACT_SHEET_MAX_PAGES", 0),
+		MaxComposeImages: parseIntEnv("COMPOSE_MAX_IMAGES", 0),
+		PageTimeout:      time.Duration(parseIntEnv("RENDER_PAGE_TIMEOUT_SECONDS", 0)) * time.Second,
+		Workers:          workers,
+		Cache:            resultCache,
+	})
+	inputs, err := doctype.NewRegistry(parseListEnv("INPUT_TYPES"))
+	if err != nil {
+		fatal(logger, "configure input types", err)
+	}
+	logger.Info("accepting input", "types", inputs.String())
+
+	var fetcher handler.URLFetcher
+	if parseBoolEnv("ENABLE_URL_FETCH", true) {
+		urlFetcher, err := urlfetch.New(urlfetch.Config{
+			MaxBytes:        megabytesToBytes(maxUploadSizeMB),
+			Timeout:         time.Duration(parseIntEnv("URL_FETCH_TIMEOUT_SECONDS", 0)) * time.Second,
+			AllowedHosts:    parseListEnv("URL_FETCH_ALLOWED_HOSTS"),
+			AllowedNetworks: parseListEnv("URL_FETCH_ALLOWED_NETWORKS"),
+			Types:           inputs,
+		})
+		if err != nil {
+			fatal(logger, "configure url fetching", err)
+		}
+		fetcher = urlFetcher
+	}
+	// Synchronous renders and background jobs share one limiter. Jobs wait for a slot instead of being
+	// turned away when the queue is full.
+	renders := limiter.New(limiter.Config{
+		MaxConcurrent: parseIntEnv("RENDER_CONCURRENCY", 0),
+		QueueSize:     parseIntEnv("RENDER_QUEUE_SIZE", 0),
+		QueueTimeout:  time.Duration(parseIntEnv("RENDER_QUEUE_TIMEOUT_SECONDS", 0)) * time.Second,
+	})
+	renderLimits := renders.Config()
+	logger.Info("render limits configured",
+		"max_concurrent", renderLimits.MaxConcurrent,
+		"queue_size", renderLimits.QueueSize,
+		"queue_timeout", renderLimits.QueueTimeout.String(),
+	)
+
+	convertHandler := handler.NewConvertHandler(pdfService, fetcher, renders, inputs, logger, megabytesToBytes(maxUploadSizeMB))
+	contactSheetHandler := handler.NewContactSheetHandler(pdfService, renders, inputs, logger, megabytesToBytes(maxUploadSizeMB))
+	extractTextHandler := handler.NewExtractTextHandler(pdfService, renders, inputs, logger, megabytesToBytes(maxUploadSizeMB))
+	inspectHandler := handler.NewInspectHandler(pdfService, renders, inputs, logger, megabytesToBytes(maxUploadSizeMB))
+	composeHandler := handler.NewComposeHandler(pdfService, renders, logger, megabytesToBytes(maxUploadSizeMB))
+
+	requireAPIKey := auth.APIKeyMiddleware(auth.APIKeyMiddlewareConfig{
+		StaticKeys:     apiKeys,
+		KeyService:     keyService,
+		Logger:         logger,
+		FeatureEnabled: enableFirestore,
+	})
+	// Inspection renders nothing, so it checks temporary keys without spending their usage.
+	requireAPIKeyNoUsage := auth.APIKeyMiddleware(auth.APIKeyMiddlewareConfig{
 		StaticKeys:     apiKeys,
 		KeyService:     keyService,
 		Logger:         logger,
 		FeatureEnabled: enableFirestore,
-	})(convertHandler))
+		SkipUsage:      true,
+	})
+
+	renderDeadline := handler.RequestTimeout(time.Duration(parseIntEnv("RENDER_REQUEST_TIMEOUT_SECONDS", defaultRenderRequestTimeoutSeconds)) * time.Second)
 
-	adminHandler := buildAdminHandler(masterKeys, keyService, logger, enableFirestore)
+	mux := http.NewServeMux()
+	mux.Handle("/convert", requireAPIKey(renderDeadline(convertHandler)))
+	mux.Handle("/contact-sheet", requireAPIKey(renderDeadline(contactSheetHandler)))
+	mux.Handle("/extract/text", requireAPIKey(renderDeadline(extractTextHandler)))
+	mux.Handle("/inspect", requireAPIKeyNoUsage(renderDeadline(inspectHandler)))
+	mux.Handle("/compose", requireAPIKey(renderDeadline(composeHandler)))
+
+	publicBaseURL := strings.TrimSuffix(os.Getenv("PUBLIC_BASE_URL"), "/")
+	webhookGuard, err := urlfetch.NewGuard(parseListEnv("WEBHOOK_ALLOWED_HOSTS"), parseListEnv("WEBHOOK_ALLOWED_NETWORKS"))
+	if err != nil {
+		fatal(logger, "configure webhook callbacks", err)
+	}
+	webhooks := jobs.NewWebhookDispatcher(logger, jobs.WebhookConfig{
+		Guard:       webhookGuard,
+		MaxAttempts: parseIntEnv("WEBHOOK_MAX_ATTEMPTS", 0),
+		ResultURL: func(jobID string) string {
+			return publicBaseURL + "/jobs/" + jobID + "/result"
+		},
+	})
+
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	jobStore := jobs.NewMemoryStore(jobs.MemoryStoreConfig{
+		MaxResultBytes:      megabytesToBytes(int64(parseIntEnv("JOB_RESULT_MAX_MB", 0))),
+		MaxOwnerResultBytes: megabytesToBytes(int64(parseIntEnv("JOB_RESULT_MAX_MB_PER_KEY", 0))),
+	})
+	jobManager := jobs.NewManager(jobStore, logger, jobs.Config{
+		Workers:   parseIntEnv("JOB_WORKERS", 0),
+		QueueSize: parseIntEnv("JOB_QUEUE_SIZE", 0),
+		ResultTTL: time.Duration(parseIntEnv("JOB_RESULT_TTL_MINUTES", 0)) * time.Minute,
+		Notifier:  webhooks,
+	})
+	jobManager.Start(jobsCtx)
+	jobsHandler := requireAPIKey(handler.NewJobsHandler(pdfService, jobManager, renders, inputs, logger, megabytesToBytes(maxUploadSizeMB)))
+	mux.Handle("/jobs", jobsHandler)
+	mux.Handle("/jobs/", jobsHandler)
+
+	adminHandler := buildAdminHandler(masterKeys, keyService, webhooks, logger, enableFirestore)
 	mux.Handle("/admin/", adminHandler)
 	mux.Handle("/admin", adminHandler)
 
@@ -100,14 +227,15 @@ func main() {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("ok"))
 	})
-	mux.Handle("/debug/vars", expvar.Handler())
+	mux.Handle("/metrics", metrics.Handler())
 
 	server := &http.Server{
-		Addr:    ":" + port,
-		Handler: loggingMiddleware(logger)(mux),
+		Addr:     ":" + port,
+		Handler:  logging.Middleware(logger)(metrics.Middleware(mux)),
+		ErrorLog: slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
 	}
 
-	logger.Printf("INFO: starting server on port %s", port)
+	logger.Info("starting server", "port", port)
 
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
 	defer stop()
@@ -121,19 +249,23 @@ func main() {
 
 	select {
 	case <-ctx.Done():
-		logger.Println("INFO: shutdown signal received")
+		logger.Info("shutdown signal received")
 	case err := <-errCh:
-		logger.Fatalf("ERROR: server error: %v", err)
+		fatal(logger, "server error", err)
 	}
 
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
 
 	if err := server.Shutdown(shutdownCtx); err != nil {
-		logger.Printf("ERROR: graceful shutdown failed: %v", err)
+		logger.Error("graceful shutdown failed", "err", err)
 	} else {
-		logger.Println("INFO: server stopped gracefully")
+		logger.Info("server stopped gracefully")
 	}
+
+	stopJobs()
+	jobManager.Wait()
+	webhooks.Close()
 }
 
 func parseAPIKeys(raw string) []string {
@@ -153,6 +285,11 @@ func parseAPIKeys(raw string) []string {
 	return keys
 }
 
+// parseListEnv reads a comma-separated environment variable, dropping empty entries.
+func parseListEnv(key string) []string {
+	return parseAPIKeys(os.Getenv(key))
+}
+
 func parseBoolEnv(key string, defaultVal bool) bool {
 	raw := strings.TrimSpace(os.Getenv(key))
 	if raw == "" {
@@ -165,12 +302,37 @@ func parseBoolEnv(key string, defaultVal bool) bool {
 	return value
 }
 
+func parseIntEnv(key string, defaultVal int) int {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return defaultVal
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return defaultVal
+	}
+	return value
+}
+
+func parseFloatEnv(key string, defaultVal float64) float64 {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return defaultVal
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return defaultVal
+	}
+	return value
+}
+
 func megabytesToBytes(mb int64) int64 {
 	return mb * 1024 * 1024
 }
 
-func buildAdminHandler(masterKeys []string, keyService *auth.KeyService, logger *log.Logger, featureEnabled bool) http.Handler {
+func buildAdminHandler(masterKeys []string, keyService *auth.KeyService, deliveries handler.DeliveryLog, logger *slog.Logger, featureEnabled bool) http.Handler {
 	adminMux := http.NewServeMux()
+	handler.NewWebhookAdminHandler(deliveries, logger).Register(adminMux)
 	if featureEnabled && keyService != nil {
 		handler.NewKeyAdminHandler(keyService, logger).Register(adminMux)
 	} else {
@@ -184,22 +346,11 @@ func buildAdminHandler(masterKeys []string, keyService *auth.KeyService, logger
 	})(adminMux)
 }
 
-func loggingMiddleware(logger *log.Logger) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			start := time.Now()
-			rec := newStatusRecorder(w)
-			next.ServeHTTP(rec, r)
-			logger.Printf("INFO: method=%s path=%s status=%d duration=%s", r.Method, r.URL.Path, rec.status, time.Since(start))
-		})
-	}
-}
-
-func loadEnvFile(path string, logger *log.Logger) error {
+func loadEnvFile(path string, logger *slog.Logger) error {
 	file, err := os.Open(path)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			logger.Printf("INFO: env file %s not found, skipping", path)
+			logger.Info("env file not found, skipping", "path", path)
 			return nil
 		}
 		return fmt.Errorf("open env file %q: %w", path, err)
@@ -219,7 +370,7 @@ func loadEnvFile(path string, logger *log.Logger) error {
 		}
 		key, value, found := strings.Cut(line, "=")
 		if !found {
-			logger.Printf("WARN: skipping malformed env line %d in %s", lineNum, path)
+			logger.Warn("skipping malformed env line", "path", path, "line", lineNum)
 			continue
 		}
 		key = strings.TrimSpace(key)
@@ -238,20 +389,16 @@ func loadEnvFile(path string, logger *log.Logger) error {
 		return fmt.Errorf("read env file %q: %w", path, err)
 	}
 
-	logger.Printf("INFO: loaded environment from %s", path)
+	logger.Info("loaded environment", "path", path)
 	return nil
 }
 
-type statusRecorder struct {
-	http.ResponseWriter
-	status int
-}
-
-func newStatusRecorder(w http.ResponseWriter) *statusRecorder {
-	return &statusRecorder{ResponseWriter: w, status: http.StatusOK}
-}
-
-func (r *statusRecorder) WriteHeader(status int) {
-	r.status = status
-	r.ResponseWriter.WriteHeader(status)
+// fatal logs err, if any, and exits.
+func fatal(logger *slog.Logger, msg string, err error) {
+	if err != nil {
+		logger.Error(msg, "err", err)
+	} else {
+		logger.Error(msg)
+	}
+	os.Exit(1)
 }
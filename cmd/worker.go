@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"pdf2jpg/internal/logging"
+	"pdf2jpg/internal/service"
+)
+
+const (
+	// workerCommand is the subcommand that runs a sandboxed render worker on stdin and stdout.
+	workerCommand         = "worker"
+	defaultWorkerMemoryMB = 2048
+	defaultWorkerCPUSecs  = 120
+)
+
+// runWorker serves one document for the parent server's WorkerPool. stdout carries the protocol, so
+// nothing else may write to it; diagnostics go to stderr.
+func runWorker(args []string) int {
+	flags := flag.NewFlagSet(workerCommand, flag.ContinueOnError)
+	memoryMB := flags.Int("memory-mb", 0, "address space limit in MiB (0 for none)")
+	cpuSeconds := flags.Int("cpu-seconds", 0, "CPU time limit in seconds (0 for none)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	// Logs are always JSON so the server's log collector parses them like its own. The worker inherits
+	// LOG_LEVEL from the server, which has already rejected bad values; the zero Config cannot fail.
+	logger, err := logging.New(os.Stderr, logging.Config{Level: os.Getenv("LOG_LEVEL")})
+	if err != nil {
+		logger, _ = logging.New(os.Stderr, logging.Config{})
+		logger.Warn("configure logging", "err", err)
+	}
+
+	err = service.ServeWorker(os.Stdin, os.Stdout, service.WorkerLimits{MemoryMB: *memoryMB, CPUSeconds: *cpuSeconds})
+	if err != nil {
+		logger.Error("render worker", "err", err, "pid", os.Getpid())
+		return 1
+	}
+	return 0
+}
+
+// newWorkerPool starts the sandboxed render workers when RENDER_WORKERS is positive, re-running this
+// binary with the worker subcommand. It returns nil when rendering stays in-process.
+func newWorkerPool(logger *slog.Logger) (*service.WorkerPool, error) {
+	size := parseIntEnv("RENDER_WORKERS", 0)
+	if size <= 0 {
+		return nil, nil
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("locate executable for render workers: %w", err)
+	}
+	memoryMB := parseIntEnv("RENDER_WORKER_MEMORY_MB", defaultWorkerMemoryMB)
+	cpuSeconds := parseIntEnv("RENDER_WORKER_CPU_SECONDS", defaultWorkerCPUSecs)
+	logger.Info("rendering in sandboxed workers", "workers", size, "memory_mb", memoryMB, "cpu_seconds", cpuSeconds)
+	return service.NewWorkerPool(service.WorkerPoolConfig{
+		Command: exe,
+		Args:    []string{workerCommand, fmt.Sprintf("-memory-mb=%d", memoryMB), fmt.Sprintf("-cpu-seconds=%d", cpuSeconds)},
+		Size:    size,
+		Logger:  logger,
+	})
+}
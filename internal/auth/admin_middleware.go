@@ -2,7 +2,7 @@ package auth
 
 import (
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"strings"
@@ -20,7 +20,7 @@ const (
 // AdminMiddlewareConfig configures the admin authentication middleware.
 type AdminMiddlewareConfig struct {
 	MasterKeys []string
-	Logger     *log.Logger
+	Logger     *slog.Logger
 	RateLimit  rate.Limit
 	Burst      int
 }
@@ -28,7 +28,7 @@ type AdminMiddlewareConfig struct {
 func AdminAuthMiddleware(cfg AdminMiddlewareConfig) func(http.Handler) http.Handler {
 	logger := cfg.Logger
 	if logger == nil {
-		logger = log.Default()
+		logger = slog.Default()
 	}
 
 	masterSet := make(map[string]struct{}, len(cfg.MasterKeys))
@@ -51,7 +51,7 @@ func AdminAuthMiddleware(cfg AdminMiddlewareConfig) func(http.Handler) http.Hand
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ip := clientIP(r)
 			if !ipLimiter.Allow(ip) {
-				logger.Printf("WARN: admin rate limit exceeded ip=%s path=%s", ip, r.URL.Path)
+				logger.WarnContext(r.Context(), "admin rate limit exceeded", "ip", ip, "path", r.URL.Path)
 				writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "rate limit exceeded"})
 				return
 			}
@@ -63,7 +63,7 @@ func AdminAuthMiddleware(cfg AdminMiddlewareConfig) func(http.Handler) http.Hand
 			}
 
 			if _, ok := masterSet[adminKey]; !ok {
-				logger.Printf("WARN: invalid admin key ip=%s path=%s", ip, r.URL.Path)
+				logger.WarnContext(r.Context(), "invalid admin key", "ip", ip, "path", r.URL.Path)
 				writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
 				return
 			}
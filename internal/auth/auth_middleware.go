@@ -3,7 +3,7 @@ package auth
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"time"
@@ -19,16 +19,18 @@ const (
 type APIKeyMiddlewareConfig struct {
 	StaticKeys     []string
 	KeyService     *KeyService
-	Logger         *log.Logger
+	Logger         *slog.Logger
 	FeatureEnabled bool
 	RetryAfter     time.Duration
+	// SkipUsage validates temporary keys without consuming a use, for endpoints that render nothing.
+	SkipUsage bool
 }
 
 // APIKeyMiddleware validates the X-API-Key header against both static and Firestore backed keys.
 func APIKeyMiddleware(cfg APIKeyMiddlewareConfig) func(http.Handler) http.Handler {
 	logger := cfg.Logger
 	if logger == nil {
-		logger = log.Default()
+		logger = slog.Default()
 	}
 	staticSet := make(map[string]struct{}, len(cfg.StaticKeys))
 	for _, key := range cfg.StaticKeys {
@@ -43,7 +45,7 @@ func APIKeyMiddleware(cfg APIKeyMiddlewareConfig) func(http.Handler) http.Handle
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			apiKey := r.Header.Get(apiKeyHeader)
 			if apiKey == "" {
-				logger.Printf("WARN: missing api key method=%s path=%s", r.Method, r.URL.Path)
+				logger.WarnContext(r.Context(), "missing api key", "method", r.Method, "path", r.URL.Path)
 				writeJSONError(w, http.StatusUnauthorized, "unauthorized")
 				return
 			}
@@ -54,12 +56,16 @@ func APIKeyMiddleware(cfg APIKeyMiddlewareConfig) func(http.Handler) http.Handle
 			}
 
 			if !cfg.FeatureEnabled || cfg.KeyService == nil {
-				logger.Printf("WARN: unknown api key method=%s path=%s", r.Method, r.URL.Path)
+				logger.WarnContext(r.Context(), "unknown api key", "method", r.Method, "path", r.URL.Path)
 				writeJSONError(w, http.StatusUnauthorized, "unauthorized")
 				return
 			}
 
-			record, outcome, err := cfg.KeyService.ValidateAndConsume(r.Context(), apiKey)
+			validate := cfg.KeyService.ValidateAndConsume
+			if cfg.SkipUsage {
+				validate = cfg.KeyService.Validate
+			}
+			record, outcome, err := validate(r.Context(), apiKey)
 			switch outcome {
 			case validationOutcomeAuthorized:
 				ctx := withAPIKey(r.Context(), apiKey)
@@ -67,11 +73,11 @@ func APIKeyMiddleware(cfg APIKeyMiddlewareConfig) func(http.Handler) http.Handle
 				next.ServeHTTP(w, r.WithContext(ctx))
 				return
 			case validationOutcomeError:
-				logger.Printf("ERROR: firestore validation failure api_key_hash=%s err=%v", hashIdentifier(apiKey, apiKeyHashPrefixLength), err)
+				logger.ErrorContext(r.Context(), "firestore validation failure", "api_key_hash", hashIdentifier(apiKey, apiKeyHashPrefixLength), "err", err)
 				w.Header().Set("Retry-After", formatRetryAfter(retryAfter))
 				writeJSONError(w, outcome.httpStatus(), outcome.errorMessage())
 			default:
-				logger.Printf("WARN: inactive api key outcome=%s api_key_hash=%s", outcome, hashIdentifier(apiKey, apiKeyHashPrefixLength))
+				logger.WarnContext(r.Context(), "inactive api key", "outcome", string(outcome), "api_key_hash", hashIdentifier(apiKey, apiKeyHashPrefixLength))
 				writeJSONError(w, outcome.httpStatus(), outcome.errorMessage())
 			}
 		})
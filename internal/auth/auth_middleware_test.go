@@ -74,6 +74,47 @@ func TestAPIKeyMiddleware_TemporaryKeyAuthorized(t *testing.T) {
 	}
 }
 
+func TestAPIKeyMiddleware_SkipUsage(t *testing.T) {
+	repo := newMemoryRepository()
+	service := NewKeyService(repo, discardLogger, nil, ServiceConfig{})
+	resp, err := service.IssueTemporaryKey(context.Background(), IssueRequest{
+		Label:      "inspect",
+		UsageLimit: 1,
+		TTL:        time.Hour,
+		Operator:   "operator",
+	})
+	if err != nil {
+		t.Fatalf("issue key: %v", err)
+	}
+
+	handler := APIKeyMiddleware(APIKeyMiddlewareConfig{
+		KeyService:     service,
+		Logger:         discardLogger,
+		FeatureEnabled: true,
+		SkipUsage:      true,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := TemporaryKeyFromContext(r.Context()); !ok {
+			t.Error("expected temporary key in context")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/inspect", nil)
+		req.Header.Set(apiKeyHeader, resp.Key)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i+1, rec.Code)
+		}
+	}
+
+	record, err := service.Get(context.Background(), resp.Key)
+	if err != nil || record.RemainingUsage != 1 {
+		t.Fatalf("expected usage to be untouched, got %d (%v)", record.RemainingUsage, err)
+	}
+}
+
 func TestAPIKeyMiddleware_TemporaryKeyUnauthorized(t *testing.T) {
 	repo := newMemoryRepository()
 	service := NewKeyService(repo, discardLogger, nil, ServiceConfig{})
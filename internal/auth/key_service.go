@@ -7,7 +7,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 )
 
@@ -33,7 +33,7 @@ func (timeNowClock) Now() time.Time {
 // KeyService coordinates issuance, validation and lifecycle operations for temporary API keys.
 type KeyService struct {
 	repo    Repository
-	logger  *log.Logger
+	logger  *slog.Logger
 	metrics metricsRecorder
 	clock   clock
 	cache   *decisionCache
@@ -44,13 +44,13 @@ type ServiceConfig struct {
 	Clock clock
 }
 
-func NewKeyService(repo Repository, logger *log.Logger, metrics metricsRecorder, cfg ServiceConfig) *KeyService {
+func NewKeyService(repo Repository, logger *slog.Logger, metrics metricsRecorder, cfg ServiceConfig) *KeyService {
 	clk := cfg.Clock
 	if clk == nil {
 		clk = timeNowClock{}
 	}
 	if metrics == nil {
-		metrics = newExpvarMetrics()
+		metrics = promMetrics{}
 	}
 	return &KeyService{
 		repo:    repo,
@@ -100,11 +100,11 @@ func (s *KeyService) IssueTemporaryKey(ctx context.Context, req IssueRequest) (I
 	s.cache.Delete(rawKey)
 	s.metrics.IncKeyIssue("success", operatorHash)
 	if err := s.refreshActiveGauge(ctx); err != nil {
-		s.logger.Printf("WARN: refresh active keys gauge: %v", err)
+		s.logger.WarnContext(ctx, "refresh active keys gauge", "err", err)
 	}
 
 	keyHash := hashIdentifier(rawKey, apiKeyHashPrefixLength)
-	s.logger.Printf("INFO: event=api_key_issue api_key_hash=%s operator=%s label=%q usage_limit=%d ttl=%s", keyHash, operatorHash, req.Label, req.UsageLimit, req.TTL)
+	s.logger.InfoContext(ctx, "api key issued", "event", "api_key_issue", "api_key_hash", keyHash, "operator", operatorHash, "label", req.Label, "usage_limit", req.UsageLimit, "ttl", req.TTL.String())
 	return IssueResponse{Key: rawKey, Record: record}, nil
 }
 
@@ -123,9 +123,9 @@ func (s *KeyService) Revoke(ctx context.Context, key string, operator string) (A
 	}
 	s.cache.Set(key, validationOutcomeRevoked, negativeCacheTTL, s.clock.Now())
 	if err := s.refreshActiveGauge(ctx); err != nil {
-		s.logger.Printf("WARN: refresh active keys gauge: %v", err)
+		s.logger.WarnContext(ctx, "refresh active keys gauge", "err", err)
 	}
-	s.logger.Printf("INFO: event=api_key_revoke api_key_hash=%s operator=%s", hashIdentifier(key, apiKeyHashPrefixLength), hashIdentifier(operator, operatorHashPrefixLength))
+	s.logger.InfoContext(ctx, "api key revoked", "event", "api_key_revoke", "api_key_hash", hashIdentifier(key, apiKeyHashPrefixLength), "operator", hashIdentifier(operator, operatorHashPrefixLength))
 	return record, nil
 }
 
@@ -140,13 +140,40 @@ func (s *KeyService) CleanupExpired(ctx context.Context, limit int) (int, error)
 	}
 	if count > 0 {
 		if err := s.refreshActiveGauge(ctx); err != nil {
-			s.logger.Printf("WARN: refresh active keys gauge: %v", err)
+			s.logger.WarnContext(ctx, "refresh active keys gauge", "err", err)
 		}
 	}
 	return count, nil
 }
 
 func (s *KeyService) ValidateAndConsume(ctx context.Context, key string) (APIKey, validationOutcome, error) {
+	return s.validate(ctx, key, s.repo.Consume)
+}
+
+// Validate checks that key is active without consuming a use. Keys with no remaining usage are still
+// rejected as exhausted.
+func (s *KeyService) Validate(ctx context.Context, key string) (APIKey, validationOutcome, error) {
+	return s.validate(ctx, key, s.lookupActive)
+}
+
+func (s *KeyService) lookupActive(ctx context.Context, key string, now time.Time) (APIKey, error) {
+	record, err := s.repo.Get(ctx, key)
+	if err != nil {
+		return APIKey{}, err
+	}
+	switch record.Status(now) {
+	case StatusRevoked:
+		return APIKey{}, ErrKeyRevoked
+	case StatusExpired:
+		return APIKey{}, ErrKeyExpired
+	case StatusExhausted:
+		return APIKey{}, ErrKeyExhausted
+	}
+	return record, nil
+}
+
+// validate runs lookup unless a negative decision for key is cached, and caches negative outcomes.
+func (s *KeyService) validate(ctx context.Context, key string, lookup func(context.Context, string, time.Time) (APIKey, error)) (APIKey, validationOutcome, error) {
 	if outcome, ok := s.cache.Get(key, s.clock.Now()); ok {
 		if outcome == validationOutcomeAuthorized {
 			// We never cache positive decisions.
@@ -156,7 +183,7 @@ func (s *KeyService) ValidateAndConsume(ctx context.Context, key string) (APIKey
 		return APIKey{}, outcome, outcome.errEquivalent()
 	}
 
-	record, err := s.repo.Consume(ctx, key, s.clock.Now())
+	record, err := lookup(ctx, key, s.clock.Now())
 	if err == nil {
 		s.cache.Delete(key)
 		s.metrics.IncKeyValidation(validationOutcomeAuthorized)
@@ -171,9 +198,9 @@ func (s *KeyService) ValidateAndConsume(ctx context.Context, key string) (APIKey
 	s.cache.Set(key, outcome, ttl, s.clock.Now())
 	if errors.Is(err, ErrKeyExpired) {
 		if delErr := s.repo.Delete(ctx, key); delErr != nil {
-			s.logger.Printf("WARN: delete expired key: %v", delErr)
+			s.logger.WarnContext(ctx, "delete expired key", "err", delErr)
 		} else if err := s.refreshActiveGauge(ctx); err != nil {
-			s.logger.Printf("WARN: refresh active keys gauge: %v", err)
+			s.logger.WarnContext(ctx, "refresh active keys gauge", "err", err)
 		}
 	}
 	s.metrics.IncKeyValidation(outcome)
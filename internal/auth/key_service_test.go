@@ -3,14 +3,13 @@ package auth
 import (
 	"context"
 	"errors"
-	"io"
-	"log"
+	"log/slog"
 	"sync"
 	"testing"
 	"time"
 )
 
-var discardLogger = log.New(io.Discard, "", 0)
+var discardLogger = slog.New(slog.DiscardHandler)
 
 func TestKeyService_IssueAndValidate(t *testing.T) {
 	repo := newMemoryRepository()
@@ -74,6 +73,43 @@ func TestKeyService_ValidateCache(t *testing.T) {
 	}
 }
 
+func TestKeyService_ValidateWithoutConsume(t *testing.T) {
+	repo := newMemoryRepository()
+	clock := &stubClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	service := NewKeyService(repo, discardLogger, nil, ServiceConfig{Clock: clock})
+
+	resp, err := service.IssueTemporaryKey(context.Background(), IssueRequest{
+		Label:      "inspect",
+		UsageLimit: 1,
+		TTL:        time.Hour,
+		Operator:   "operator",
+	})
+	if err != nil {
+		t.Fatalf("IssueTemporaryKey() error = %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		record, outcome, err := service.Validate(context.Background(), resp.Key)
+		if err != nil || outcome != validationOutcomeAuthorized {
+			t.Fatalf("Validate() = %s, %v", outcome, err)
+		}
+		if record.RemainingUsage != 1 {
+			t.Fatalf("expected remaining usage 1, got %d", record.RemainingUsage)
+		}
+	}
+	if repo.consumeCalls[resp.Key] != 0 {
+		t.Fatalf("expected no consume calls, got %d", repo.consumeCalls[resp.Key])
+	}
+
+	if _, _, err := service.ValidateAndConsume(context.Background(), resp.Key); err != nil {
+		t.Fatalf("ValidateAndConsume() error = %v", err)
+	}
+	_, outcome, err := service.Validate(context.Background(), resp.Key)
+	if !errors.Is(err, ErrKeyExhausted) || outcome != validationOutcomeExhausted {
+		t.Fatalf("expected exhausted outcome, got %s (%v)", outcome, err)
+	}
+}
+
 func TestKeyService_Revoke(t *testing.T) {
 	repo := newMemoryRepository()
 	clock := &stubClock{now: time.Now().UTC()}
@@ -1,9 +1,10 @@
 package auth
 
 import (
-	"expvar"
-	"fmt"
-	"sync"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"pdf2jpg/internal/metrics"
 )
 
 // metricsRecorder centralises counter/gauge updates so the rest of the package stays testable.
@@ -13,66 +14,35 @@ type metricsRecorder interface {
 	SetTemporaryKeysActive(count int)
 }
 
-type expvarMetrics struct {
-	issueMap      *expvar.Map
-	validationMap *expvar.Map
-	activeGauge   *expvar.Int
-	mu            sync.Mutex
-}
-
-func newExpvarMetrics() *expvarMetrics {
-	return &expvarMetrics{
-		issueMap:      ensureExpvarMap("api_key_issue_total"),
-		validationMap: ensureExpvarMap("api_key_validation_total"),
-		activeGauge:   ensureExpvarInt("temporary_keys_active"),
-	}
-}
-
-func (m *expvarMetrics) IncKeyIssue(result, operator string) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	key := fmt.Sprintf(`{"result":"%s","operator":"%s"}`, result, operator)
-	current := getExpvarInt(m.issueMap, key)
-	current.Add(1)
-}
-
-func (m *expvarMetrics) IncKeyValidation(outcome validationOutcome) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	key := fmt.Sprintf(`{"outcome":"%s"}`, outcome)
-	current := getExpvarInt(m.validationMap, key)
-	current.Add(1)
-}
+var (
+	keyIssueTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Name:      "api_key_issue_total",
+		Help:      "Temporary API keys issued, by result and hashed operator.",
+	}, []string{"result", "operator"})
+	keyValidationTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Name:      "api_key_validation_total",
+		Help:      "Temporary API key checks, by outcome.",
+	}, []string{"outcome"})
+	temporaryKeysActive = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Name:      "temporary_keys_active",
+		Help:      "Temporary API keys that are neither revoked nor expired.",
+	})
+)
 
-func (m *expvarMetrics) SetTemporaryKeysActive(count int) {
-	m.activeGauge.Set(int64(count))
-}
+// promMetrics records into the process-wide collectors served on /metrics.
+type promMetrics struct{}
 
-func getExpvarInt(m *expvar.Map, key string) *expvar.Int {
-	if existing := m.Get(key); existing != nil {
-		if intVar, ok := existing.(*expvar.Int); ok {
-			return intVar
-		}
-	}
-	intVar := new(expvar.Int)
-	m.Set(key, intVar)
-	return intVar
+func (promMetrics) IncKeyIssue(result, operator string) {
+	keyIssueTotal.WithLabelValues(result, operator).Inc()
 }
 
-func ensureExpvarMap(name string) *expvar.Map {
-	if existing := expvar.Get(name); existing != nil {
-		if m, ok := existing.(*expvar.Map); ok {
-			return m
-		}
-	}
-	return expvar.NewMap(name)
+func (promMetrics) IncKeyValidation(outcome validationOutcome) {
+	keyValidationTotal.WithLabelValues(string(outcome)).Inc()
 }
 
-func ensureExpvarInt(name string) *expvar.Int {
-	if existing := expvar.Get(name); existing != nil {
-		if v, ok := existing.(*expvar.Int); ok {
-			return v
-		}
-	}
-	return expvar.NewInt(name)
+func (promMetrics) SetTemporaryKeysActive(count int) {
+	temporaryKeysActive.Set(float64(count))
 }
@@ -0,0 +1,247 @@
+// Package doctype describes the input formats MuPDF can render and which of them a deployment accepts.
+package doctype
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// SniffLen is the number of leading bytes Type.Matches needs to identify a file. PDF readers accept
+// the %PDF header anywhere in the first 1024 bytes.
+const SniffLen = 1024
+
+// ErrUnknownType is returned when a configured type name is not one of the built-in types.
+var ErrUnknownType = errors.New("unknown document type")
+
+// Type is an input format. Extensions and media types are lower case; the first extension names
+// temporary files, which is how MuPDF chooses its parser.
+type Type struct {
+	Name       string
+	Extensions []string
+	MediaTypes []string
+	magic      func(header []byte) bool
+}
+
+// Extension returns the canonical file extension, including the dot.
+func (t Type) Extension() string {
+	return t.Extensions[0]
+}
+
+// Matches reports whether header, the first SniffLen bytes of a file or fewer, looks like this type.
+func (t Type) Matches(header []byte) bool {
+	return t.magic(header)
+}
+
+var (
+	zipMagic  = []byte("PK\x03\x04")
+	epubMagic = []byte("mimetypeapplication/epub+zip")
+)
+
+func hasPrefix(prefixes ...string) func([]byte) bool {
+	return func(header []byte) bool {
+		for _, prefix := range prefixes {
+			if bytes.HasPrefix(header, []byte(prefix)) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// containsPDFHeader accepts leading junk before %PDF, such as a mail or HTTP preamble, as PDF
+// readers do.
+func containsPDFHeader(header []byte) bool {
+	return bytes.Contains(header, []byte("%PDF"))
+}
+
+// XPS and CBZ are plain ZIP archives; only EPUB is required to start with a recognisable entry.
+func isZIP(header []byte) bool {
+	return bytes.HasPrefix(header, zipMagic)
+}
+
+func isEPUB(header []byte) bool {
+	return isZIP(header) && len(header) >= 30+len(epubMagic) && bytes.Equal(header[30:30+len(epubMagic)], epubMagic)
+}
+
+// PDF is the default and historically only accepted input.
+var PDF = Type{
+	Name:       "pdf",
+	Extensions: []string{".pdf"},
+	MediaTypes: []string{"application/pdf"},
+	magic:      containsPDFHeader,
+}
+
+// builtin lists every type MuPDF is built to open, in the order used for messages.
+var builtin = []Type{
+	PDF,
+	{
+		Name:       "xps",
+		Extensions: []string{".xps", ".oxps"},
+		MediaTypes: []string{"application/vnd.ms-xpsdocument", "application/oxps"},
+		magic:      isZIP,
+	},
+	{
+		Name:       "epub",
+		Extensions: []string{".epub"},
+		MediaTypes: []string{"application/epub+zip"},
+		magic:      isEPUB,
+	},
+	{
+		Name:       "cbz",
+		Extensions: []string{".cbz"},
+		MediaTypes: []string{"application/vnd.comicbook+zip", "application/x-cbz"},
+		magic:      isZIP,
+	},
+	{
+		Name:       "tiff",
+		Extensions: []string{".tiff", ".tif"},
+		MediaTypes: []string{"image/tiff"},
+		magic:      hasPrefix("II*\x00", "MM\x00*"),
+	},
+	{
+		Name:       "png",
+		Extensions: []string{".png"},
+		MediaTypes: []string{"image/png"},
+		magic:      hasPrefix("\x89PNG\r\n\x1a\n"),
+	},
+	{
+		Name:       "jpeg",
+		Extensions: []string{".jpg", ".jpeg"},
+		MediaTypes: []string{"image/jpeg"},
+		magic:      hasPrefix("\xff\xd8\xff"),
+	},
+	{
+		Name:       "gif",
+		Extensions: []string{".gif"},
+		MediaTypes: []string{"image/gif"},
+		magic:      hasPrefix("GIF87a", "GIF89a"),
+	},
+	{
+		Name:       "bmp",
+		Extensions: []string{".bmp"},
+		MediaTypes: []string{"image/bmp"},
+		magic:      hasPrefix("BM"),
+	},
+}
+
+// Names returns the names of every built-in type.
+func Names() []string {
+	names := make([]string, len(builtin))
+	for i, t := range builtin {
+		names[i] = t.Name
+	}
+	return names
+}
+
+// IsDocumentMediaType reports whether mediaType belongs to any built-in type, allowed or not. Handlers
+// use it to tell raw document bodies apart from form submissions.
+func IsDocumentMediaType(mediaType string) bool {
+	_, ok := lookupMediaType(builtin, mediaType)
+	return ok
+}
+
+// Registry is the set of types a deployment accepts. A nil *Registry accepts PDF only.
+type Registry struct {
+	types []Type
+}
+
+var pdfOnly = &Registry{types: []Type{PDF}}
+
+// NewRegistry returns a registry accepting the named types, in the built-in order. An empty list
+// accepts PDF only.
+func NewRegistry(names []string) (*Registry, error) {
+	if len(names) == 0 {
+		return pdfOnly, nil
+	}
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := lookupName(name); !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownType, name)
+		}
+		wanted[name] = true
+	}
+	r := &Registry{}
+	for _, t := range builtin {
+		if wanted[t.Name] {
+			r.types = append(r.types, t)
+		}
+	}
+	return r, nil
+}
+
+func (r *Registry) list() []Type {
+	if r == nil {
+		return pdfOnly.types
+	}
+	return r.types
+}
+
+// Types returns the accepted types.
+func (r *Registry) Types() []Type {
+	return append([]Type(nil), r.list()...)
+}
+
+// ByFilename returns the accepted type for the extension of name.
+func (r *Registry) ByFilename(name string) (Type, bool) {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, t := range r.list() {
+		for _, candidate := range t.Extensions {
+			if ext == candidate {
+				return t, true
+			}
+		}
+	}
+	return Type{}, false
+}
+
+// ByMediaType returns the accepted type for mediaType.
+func (r *Registry) ByMediaType(mediaType string) (Type, bool) {
+	return lookupMediaType(r.list(), mediaType)
+}
+
+// MediaTypes returns every media type of the accepted types.
+func (r *Registry) MediaTypes() []string {
+	var mediaTypes []string
+	for _, t := range r.list() {
+		mediaTypes = append(mediaTypes, t.MediaTypes...)
+	}
+	return mediaTypes
+}
+
+// String lists the accepted type names for error messages, e.g. "pdf" or "pdf, xps or tiff".
+func (r *Registry) String() string {
+	types := r.list()
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = t.Name
+	}
+	if len(names) == 1 {
+		return names[0]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
+
+func lookupName(name string) (Type, bool) {
+	for _, t := range builtin {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return Type{}, false
+}
+
+func lookupMediaType(types []Type, mediaType string) (Type, bool) {
+	mediaType = strings.ToLower(mediaType)
+	for _, t := range types {
+		for _, candidate := range t.MediaTypes {
+			if mediaType == candidate {
+				return t, true
+			}
+		}
+	}
+	return Type{}, false
+}
@@ -0,0 +1,88 @@
+package doctype
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestNewRegistry(t *testing.T) {
+	r, err := NewRegistry([]string{" TIFF", "pdf", "xps"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := r.String(); got != "pdf, xps or tiff" {
+		t.Fatalf("expected built-in order, got %q", got)
+	}
+	if _, err := NewRegistry([]string{"pdf", "docx"}); !errors.Is(err, ErrUnknownType) {
+		t.Fatalf("expected ErrUnknownType, got %v", err)
+	}
+
+	var defaults *Registry
+	if defaults.String() != "pdf" {
+		t.Fatalf("expected a nil registry to accept pdf only, got %q", defaults.String())
+	}
+	if empty, _ := NewRegistry(nil); empty.String() != "pdf" {
+		t.Fatalf("expected an empty list to accept pdf only, got %q", empty.String())
+	}
+}
+
+func TestRegistryLookup(t *testing.T) {
+	r, _ := NewRegistry([]string{"pdf", "tiff", "cbz"})
+	for name, want := range map[string]string{"a.PDF": "pdf", "scan.tif": "tiff", "scan.tiff": "tiff", "comic.cbz": "cbz"} {
+		if got, ok := r.ByFilename(name); !ok || got.Name != want {
+			t.Fatalf("%s: expected %s, got %q", name, want, got.Name)
+		}
+	}
+	for _, name := range []string{"book.epub", "notes.txt", "pdf"} {
+		if _, ok := r.ByFilename(name); ok {
+			t.Fatalf("%s: expected no type", name)
+		}
+	}
+	if got, ok := r.ByMediaType("IMAGE/TIFF"); !ok || got.Name != "tiff" {
+		t.Fatalf("expected tiff, got %q", got.Name)
+	}
+	if _, ok := r.ByMediaType("application/epub+zip"); ok {
+		t.Fatal("expected epub to be rejected")
+	}
+	if !IsDocumentMediaType("application/epub+zip") || IsDocumentMediaType("application/json") {
+		t.Fatal("unexpected built-in media type lookup")
+	}
+}
+
+func TestTypeMatches(t *testing.T) {
+	epub := []byte("PK\x03\x04" + string(make([]byte, 26)) + "mimetypeapplication/epub+zip")
+	cases := map[string][]byte{
+		"pdf":  []byte("%PDF-1.7\n"),
+		"xps":  []byte("PK\x03\x04\x14\x00"),
+		"epub": epub,
+		"cbz":  []byte("PK\x03\x04\x14\x00"),
+		"tiff": []byte("MM\x00*\x00\x00\x00\x08"),
+		"png":  []byte("\x89PNG\r\n\x1a\n"),
+		"jpeg": []byte("\xff\xd8\xff\xe0"),
+		"gif":  []byte("GIF89a"),
+		"bmp":  []byte("BM6\x00"),
+	}
+	for name, header := range cases {
+		docType, ok := lookupName(name)
+		if !ok {
+			t.Fatalf("%s: not a built-in type", name)
+		}
+		if !docType.Matches(header) {
+			t.Fatalf("%s: expected its own header to match", name)
+		}
+		if docType.Matches([]byte("not a document")) {
+			t.Fatalf("%s: expected plain text not to match", name)
+		}
+	}
+	junk := append(bytes.Repeat([]byte{'\n'}, 900), "%PDF-1.4"...)
+	if !PDF.Matches(junk) {
+		t.Fatal("expected a pdf header after leading junk to match")
+	}
+	if docType, _ := lookupName("epub"); docType.Matches([]byte("PK\x03\x04\x14\x00")) {
+		t.Fatal("expected a zip without the epub mimetype entry to be rejected")
+	}
+	if len(Names()) != len(cases) {
+		t.Fatalf("expected a test header for every built-in type, have %v", Names())
+	}
+}
@@ -5,7 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
@@ -26,7 +26,7 @@ const (
 // KeyAdminHandler exposes admin operations for temporary API keys.
 type KeyAdminHandler struct {
 	service KeyManagementService
-	logger  *log.Logger
+	logger  *slog.Logger
 }
 
 type KeyManagementService interface {
@@ -36,7 +36,7 @@ type KeyManagementService interface {
 	CleanupExpired(ctx context.Context, limit int) (int, error)
 }
 
-func NewKeyAdminHandler(service KeyManagementService, logger *log.Logger) *KeyAdminHandler {
+func NewKeyAdminHandler(service KeyManagementService, logger *slog.Logger) *KeyAdminHandler {
 	return &KeyAdminHandler{
 		service: service,
 		logger:  logger,
@@ -110,7 +110,7 @@ func (h *KeyAdminHandler) issueKey(w http.ResponseWriter, r *http.Request) {
 		Operator:   operator,
 	})
 	if err != nil {
-		h.logger.Printf("ERROR: issue temporary key: %v", err)
+		h.logger.ErrorContext(r.Context(), "issue temporary key", "err", err)
 		writeAdminError(w, http.StatusInternalServerError, "failed to issue key")
 		return
 	}
@@ -133,7 +133,7 @@ func (h *KeyAdminHandler) getKey(w http.ResponseWriter, r *http.Request, key str
 		return
 	}
 	if err != nil {
-		h.logger.Printf("ERROR: fetch key status: %v", err)
+		h.logger.ErrorContext(r.Context(), "fetch key status", "err", err)
 		writeAdminError(w, http.StatusInternalServerError, "failed to fetch key")
 		return
 	}
@@ -162,7 +162,7 @@ func (h *KeyAdminHandler) revokeKey(w http.ResponseWriter, r *http.Request, key
 		return
 	}
 	if err != nil {
-		h.logger.Printf("ERROR: revoke key: %v", err)
+		h.logger.ErrorContext(r.Context(), "revoke key", "err", err)
 		writeAdminError(w, http.StatusInternalServerError, "failed to revoke key")
 		return
 	}
@@ -192,7 +192,7 @@ func (h *KeyAdminHandler) cleanup(w http.ResponseWriter, r *http.Request) {
 
 	count, err := h.service.CleanupExpired(r.Context(), limit)
 	if err != nil {
-		h.logger.Printf("ERROR: cleanup expired keys: %v", err)
+		h.logger.ErrorContext(r.Context(), "cleanup expired keys", "err", err)
 		writeAdminError(w, http.StatusInternalServerError, "cleanup failed")
 		return
 	}
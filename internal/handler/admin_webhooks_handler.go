@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"pdf2jpg/internal/jobs"
+)
+
+const (
+	defaultDeliveryLimit = 100
+	maxDeliveryLimit     = 500
+)
+
+// DeliveryLog exposes recorded webhook delivery attempts.
+type DeliveryLog interface {
+	Deliveries(jobID string, limit int) []jobs.Delivery
+}
+
+// WebhookAdminHandler exposes the webhook delivery log to operators.
+type WebhookAdminHandler struct {
+	log    DeliveryLog
+	logger *slog.Logger
+}
+
+func NewWebhookAdminHandler(deliveries DeliveryLog, logger *slog.Logger) *WebhookAdminHandler {
+	return &WebhookAdminHandler{
+		log:    deliveries,
+		logger: logger,
+	}
+}
+
+func (h *WebhookAdminHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/admin/webhooks/deliveries", h.listDeliveries)
+}
+
+func (h *WebhookAdminHandler) listDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAdminError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	limit := defaultDeliveryLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			limit = min(parsed, maxDeliveryLimit)
+		}
+	}
+
+	deliveries := h.log.Deliveries(r.URL.Query().Get("jobId"), limit)
+	if deliveries == nil {
+		deliveries = []jobs.Delivery{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"deliveries": deliveries})
+}
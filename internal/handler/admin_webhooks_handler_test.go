@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pdf2jpg/internal/jobs"
+)
+
+type stubDeliveryLog struct {
+	jobID string
+	limit int
+}
+
+func (s *stubDeliveryLog) Deliveries(jobID string, limit int) []jobs.Delivery {
+	s.jobID, s.limit = jobID, limit
+	return []jobs.Delivery{{ID: "d1", JobID: jobID, Attempt: 1, StatusCode: 502}}
+}
+
+func TestWebhookAdminHandler_ListDeliveries(t *testing.T) {
+	deliveries := &stubDeliveryLog{}
+	mux := http.NewServeMux()
+	NewWebhookAdminHandler(deliveries, discardLogger).Register(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/webhooks/deliveries?jobId=abc&limit=9999", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if deliveries.jobID != "abc" || deliveries.limit != maxDeliveryLimit {
+		t.Fatalf("unexpected query jobID=%q limit=%d", deliveries.jobID, deliveries.limit)
+	}
+	var resp struct {
+		Deliveries []jobs.Delivery `json:"deliveries"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || len(resp.Deliveries) != 1 {
+		t.Fatalf("unexpected body %s (%v)", rec.Body.String(), err)
+	}
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"pdf2jpg/internal/doctype"
+	"pdf2jpg/internal/service"
+	"pdf2jpg/internal/util"
+)
+
+const (
+	pageSizeField    = "pageSize"
+	orientationField = "orientation"
+	marginField      = "margin"
+)
+
+// composeInputs are the image types ComposeHandler embeds, regardless of INPUT_TYPES.
+var composeInputs, _ = doctype.NewRegistry([]string{"jpeg", "png"})
+
+// ImageComposer defines the PDF assembly behavior required by ComposeHandler.
+type ImageComposer interface {
+	ComposeImages(ctx context.Context, imagePaths []string, opts service.ComposeOptions, w io.Writer) error
+}
+
+// ComposeHandler handles POST /compose requests, which bundle uploaded images into one PDF.
+type ComposeHandler struct {
+	composer    ImageComposer
+	renders     RenderLimiter
+	logger      *slog.Logger
+	maxFileSize int64
+}
+
+// NewComposeHandler returns a configured ComposeHandler. maxFileSize bounds the whole request body. A
+// nil renders limiter lets every request compose at once.
+func NewComposeHandler(composer ImageComposer, renders RenderLimiter, logger *slog.Logger, maxFileSize int64) http.Handler {
+	return &ComposeHandler{
+		composer:    composer,
+		renders:     renders,
+		logger:      logger,
+		maxFileSize: maxFileSize,
+	}
+}
+
+func (h *ComposeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxFileSize)
+	if err := r.ParseMultipartForm(h.maxFileSize); err != nil {
+		handleMultipartError(r.Context(), w, h.logger, err)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	headers := r.MultipartForm.File[uploadField]
+	if len(headers) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "file field is required")
+		return
+	}
+
+	opts, err := parseComposeOptions(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Parts are saved in the order they were sent, which is the page order.
+	paths := make([]string, 0, len(headers))
+	defer func() {
+		for _, path := range paths {
+			util.RemoveFile(path)
+		}
+	}()
+	for _, header := range headers {
+		docType, ok := composeInputs.ByFilename(header.Filename)
+		if !ok {
+			writeJSONError(w, http.StatusBadRequest, "file must be a "+composeInputs.String())
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			h.logger.ErrorContext(r.Context(), "opening uploaded image", "err", err)
+			writeJSONError(w, http.StatusInternalServerError, "failed to process file")
+			return
+		}
+		upload := &pdfUpload{body: file, filename: header.Filename, docType: docType}
+		path, ok := upload.Save(r.Context(), w, h.logger)
+		upload.Close()
+		if !ok {
+			return
+		}
+		paths = append(paths, path)
+	}
+
+	release, ok := acquireRender(w, r, h.renders, h.logger)
+	if !ok {
+		return
+	}
+	defer release()
+
+	resp := newPageResponse(w, "application/pdf", outputBaseName(headers[0].Filename)+".pdf")
+	err = h.composer.ComposeImages(r.Context(), paths, opts, resp)
+	if err == nil {
+		return
+	}
+	if !resp.Started() {
+		handleConversionError(r.Context(), w, h.logger, err)
+		return
+	}
+	h.logger.ErrorContext(r.Context(), "sending composed pdf", "err", err)
+}
+
+// parseComposeOptions reads the page layout fields. Errors carry the client-facing message.
+func parseComposeOptions(r *http.Request) (service.ComposeOptions, error) {
+	var opts service.ComposeOptions
+	var err error
+
+	if opts.Paper, err = service.ParsePaperSize(r.FormValue(pageSizeField)); err != nil {
+		return opts, errors.New("invalid pageSize parameter")
+	}
+	if opts.Orientation, err = service.ParseOrientation(r.FormValue(orientationField)); err != nil {
+		return opts, errors.New("invalid orientation parameter")
+	}
+	if opts.Fit, err = service.ParseFitMode(r.FormValue(fitField)); err != nil {
+		return opts, errors.New("invalid fit parameter")
+	}
+	if raw := strings.TrimSpace(r.FormValue(marginField)); raw != "" {
+		if opts.Margin, err = strconv.ParseFloat(raw, 64); err != nil || opts.Margin < 0 {
+			return opts, errors.New("invalid margin parameter")
+		}
+	}
+	if raw := strings.TrimSpace(r.FormValue(dpiField)); raw != "" {
+		if opts.DPI, err = strconv.ParseFloat(raw, 64); err != nil || opts.DPI <= 0 {
+			return opts, errors.New("invalid dpi parameter")
+		}
+	}
+	return opts, nil
+}
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"pdf2jpg/internal/doctype"
+	"pdf2jpg/internal/service"
+	"pdf2jpg/internal/util"
+)
+
+const (
+	columnsField    = "columns"
+	thumbWidthField = "thumbWidth"
+	paddingField    = "padding"
+	backgroundField = "background"
+)
+
+// ContactSheetRenderer defines the compositing behavior required by ContactSheetHandler.
+type ContactSheetRenderer interface {
+	RenderContactSheet(ctx context.Context, pdfPath string, sel service.PageSelector, sheet service.ContactSheetOptions, opts service.ConvertOptions) ([]byte, error)
+}
+
+// ContactSheetHandler handles POST /contact-sheet requests.
+type ContactSheetHandler struct {
+	renderer    ContactSheetRenderer
+	renders     RenderLimiter
+	inputs      *doctype.Registry
+	logger      *slog.Logger
+	maxFileSize int64
+}
+
+// NewContactSheetHandler returns a configured ContactSheetHandler. A nil renders limiter lets every request render at
+// once and nil inputs accept PDF only.
+func NewContactSheetHandler(renderer ContactSheetRenderer, renders RenderLimiter, inputs *doctype.Registry, logger *slog.Logger, maxFileSize int64) http.Handler {
+	return &ContactSheetHandler{
+		renderer:    renderer,
+		renders:     renders,
+		inputs:      inputs,
+		logger:      logger,
+		maxFileSize: maxFileSize,
+	}
+}
+
+func (h *ContactSheetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	upload, ok := readPDFUpload(w, r, h.maxFileSize, h.inputs, h.logger)
+	if !ok {
+		return
+	}
+	defer upload.Close()
+
+	selector := service.AllPages()
+	if raw := strings.TrimSpace(r.FormValue(pagesField)); raw != "" {
+		var err error
+		if selector, err = service.ParsePageSelector(raw); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid pages parameter")
+			return
+		}
+	}
+
+	sheet, err := parseContactSheetOptions(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	opts, err := parseConvertOptions(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if opts.DPI > 0 || opts.Width > 0 || opts.Height > 0 {
+		writeJSONError(w, http.StatusBadRequest, "use thumbWidth to size contact sheets")
+		return
+	}
+
+	encoder, err := service.LookupEncoder(opts.Format)
+	if _, vector := encoder.(service.PageExporter); err != nil || vector {
+		writeJSONError(w, http.StatusBadRequest, "unsupported format")
+		return
+	}
+
+	tempPath, ok := upload.Save(r.Context(), w, h.logger)
+	if !ok {
+		return
+	}
+	defer util.RemoveFile(tempPath)
+
+	release, ok := acquireRender(w, r, h.renders, h.logger)
+	if !ok {
+		return
+	}
+	defer release()
+
+	data, err := h.renderer.RenderContactSheet(r.Context(), tempPath, selector, sheet, opts)
+	if err != nil {
+		handleConversionError(r.Context(), w, h.logger, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", encoder.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s-sheet%s"`, upload.BaseName(), encoder.Extension()))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(data); err != nil {
+		h.logger.ErrorContext(r.Context(), "sending contact sheet response", "err", err)
+	}
+}
+
+// parseContactSheetOptions reads the grid layout fields. Errors carry the client-facing message.
+func parseContactSheetOptions(r *http.Request) (service.ContactSheetOptions, error) {
+	var sheet service.ContactSheetOptions
+	var err error
+
+	if sheet.Columns, err = parsePositiveInt(r.FormValue(columnsField)); err != nil {
+		return sheet, fmt.Errorf("invalid %s parameter", columnsField)
+	}
+	if sheet.ThumbWidth, err = parsePositiveInt(r.FormValue(thumbWidthField)); err != nil {
+		return sheet, fmt.Errorf("invalid %s parameter", thumbWidthField)
+	}
+	if raw := strings.TrimSpace(r.FormValue(paddingField)); raw != "" {
+		padding, err := strconv.Atoi(raw)
+		if err != nil || padding < 0 {
+			return sheet, fmt.Errorf("invalid %s parameter", paddingField)
+		}
+		sheet.Padding = padding
+	} else {
+		sheet.Padding = -1
+	}
+	if raw := strings.TrimSpace(r.FormValue(backgroundField)); raw != "" {
+		if sheet.Background, err = service.ParseHexColor(raw); err != nil {
+			return sheet, fmt.Errorf("invalid %s parameter", backgroundField)
+		}
+	}
+
+	return sheet, nil
+}
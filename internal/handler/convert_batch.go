@@ -0,0 +1,348 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"pdf2jpg/internal/limiter"
+	"pdf2jpg/internal/util"
+)
+
+const (
+	outputMultipart = "multipart"
+
+	// maxBatchFiles caps how many files one /convert request may upload.
+	maxBatchFiles = 20
+	// maxBatchSpoolBytes caps the encoded pages one batch spools to disk, across all of its files.
+	maxBatchSpoolBytes = 512 << 20
+	// batchParallelism is how many files of one batch convert at once. Each still waits for a render
+	// slot, so a batch never renders more than the shared limiter allows.
+	batchParallelism = 4
+
+	batchArchiveName  = "batch"
+	batchManifestName = "manifest.json"
+)
+
+// errBatchTooLarge fails the batch file whose pages would take the batch past maxBatchSpoolBytes.
+var errBatchTooLarge = errors.New("batch output too large")
+
+// batchManifest is the first entry of every batch response and reports each file, in upload order.
+type batchManifest struct {
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Files     []batchFileResult `json:"files"`
+}
+
+// batchFileResult uses the status and error message the file would have had on its own.
+type batchFileResult struct {
+	File   string            `json:"file"`
+	Status int               `json:"status"`
+	Error  string            `json:"error,omitempty"`
+	Pages  []batchPageResult `json:"pages,omitempty"`
+}
+
+type batchPageResult struct {
+	Page int    `json:"page"`
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// batchFile is one upload of a batch and, once converted, its spooled pages.
+type batchFile struct {
+	header  *multipart.FileHeader
+	spool   *pageSpool
+	status  int
+	message string
+}
+
+// fail records the error response the file would have received and drops any pages already spooled.
+func (f *batchFile) fail(status int, message string) {
+	f.status, f.message = status, message
+	f.spool.Close()
+	f.spool = nil
+}
+
+// batchEntry is one page of the response body.
+type batchEntry struct {
+	name string
+	body io.Reader
+}
+
+// serveBatch converts every uploaded file with the same options and returns the pages of those that
+// succeeded, plus a manifest, as a ZIP or multipart/mixed response. A file that fails is reported in
+// the manifest rather than failing the request. Files convert in parallel into temporary spools, so
+// the response is only written once every file is done.
+func (h *ConvertHandler) serveBatch(w http.ResponseWriter, r *http.Request) {
+	headers := r.MultipartForm.File[uploadField]
+	if len(headers) > maxBatchFiles {
+		writeJSONError(w, http.StatusBadRequest, "too many files")
+		return
+	}
+
+	req, ok := parseRenderRequest(w, r)
+	if !ok {
+		return
+	}
+	output := strings.ToLower(strings.TrimSpace(r.FormValue(outputField)))
+	switch output {
+	case outputAuto:
+		output = outputZip
+	case outputZip, outputMultipart:
+	default:
+		writeJSONError(w, http.StatusBadRequest, "invalid output parameter")
+		return
+	}
+
+	files := make([]*batchFile, len(headers))
+	for i, header := range headers {
+		files[i] = &batchFile{header: header, status: http.StatusOK}
+	}
+	defer func() {
+		for _, f := range files {
+			f.spool.Close()
+		}
+	}()
+
+	ctx := r.Context()
+	var budget atomic.Int64
+	budget.Store(maxBatchSpoolBytes)
+	work := make(chan *batchFile)
+	var wg sync.WaitGroup
+	for i := 0; i < min(batchParallelism, len(files)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range work {
+				h.convertBatchFile(ctx, f, req, &budget)
+			}
+		}()
+	}
+	for _, f := range files {
+		work <- f
+	}
+	close(work)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		handleConversionError(ctx, w, h.logger, err)
+		return
+	}
+
+	manifest, entries := buildBatchManifest(files, req.encoder.Extension())
+	if output == outputMultipart {
+		h.writeBatchMultipart(ctx, w, manifest, entries, req.encoder.ContentType())
+		return
+	}
+	h.writeBatchArchive(ctx, w, manifest, entries)
+}
+
+// convertBatchFile saves, renders and spools one file of a batch, recording any failure on f. The
+// spooled pages count against budget, the bytes the batch may still spool.
+func (h *ConvertHandler) convertBatchFile(ctx context.Context, f *batchFile, req convertRequest, budget *atomic.Int64) {
+	docType, ok := h.inputs.ByFilename(f.header.Filename)
+	if !ok {
+		f.fail(http.StatusBadRequest, "file must be a "+h.inputs.String())
+		return
+	}
+
+	src, err := f.header.Open()
+	if err != nil {
+		h.logger.ErrorContext(ctx, "opening uploaded file", "err", err)
+		f.fail(http.StatusInternalServerError, "failed to process file")
+		return
+	}
+	tempPath, err := util.SaveUploadedFile(src, docType)
+	src.Close()
+	if errors.Is(err, util.ErrContentMismatch) {
+		f.fail(http.StatusBadRequest, "file content does not match its type")
+		return
+	}
+	if err != nil {
+		h.logger.ErrorContext(ctx, "saving uploaded file", "err", err)
+		f.fail(http.StatusInternalServerError, "failed to process file")
+		return
+	}
+	defer util.RemoveFile(tempPath)
+
+	if h.renders != nil {
+		release, err := h.renders.Acquire(ctx)
+		if err != nil {
+			if errors.Is(err, limiter.ErrQueueFull) || errors.Is(err, limiter.ErrQueueTimeout) {
+				h.logger.WarnContext(ctx, "skipping batch file", "err", err)
+				f.fail(http.StatusServiceUnavailable, "server busy")
+				return
+			}
+			f.fail(classifyConversionError(err))
+			return
+		}
+		defer release()
+	}
+
+	if f.spool, err = newPageSpool(budget); err != nil {
+		h.logger.ErrorContext(ctx, "creating batch spool", "err", err)
+		f.fail(http.StatusInternalServerError, "failed to process file")
+		return
+	}
+	if err := h.converter.StreamPages(ctx, tempPath, req.selector, req.opts, f.spool.Next); err != nil {
+		status, message := classifyConversionError(err)
+		if status == http.StatusInternalServerError {
+			h.logger.ErrorContext(ctx, "convert batch file", "err", err)
+		}
+		f.fail(status, message)
+	}
+}
+
+// buildBatchManifest names the spooled pages, in upload order, and summarises every file.
+func buildBatchManifest(files []*batchFile, ext string) (batchManifest, []batchEntry) {
+	manifest := batchManifest{Files: make([]batchFileResult, 0, len(files))}
+	var entries []batchEntry
+	names := make(map[string]int)
+	for _, f := range files {
+		result := batchFileResult{File: f.header.Filename, Status: f.status, Error: f.message}
+		if f.spool == nil {
+			manifest.Failed++
+			manifest.Files = append(manifest.Files, result)
+			continue
+		}
+		manifest.Succeeded++
+		baseName := outputBaseName(f.header.Filename)
+		for _, page := range f.spool.pages {
+			name := uniquePageName(names, baseName, page.page, ext)
+			result.Pages = append(result.Pages, batchPageResult{Page: page.page, Name: name, Size: page.size})
+			entries = append(entries, batchEntry{name: name, body: f.spool.reader(page)})
+		}
+		manifest.Files = append(manifest.Files, result)
+	}
+	return manifest, entries
+}
+
+// writeBatchArchive writes the manifest and then every page into a ZIP response.
+func (h *ConvertHandler) writeBatchArchive(ctx context.Context, w http.ResponseWriter, manifest batchManifest, entries []batchEntry) {
+	archive := newPageArchive(w, batchArchiveName, "")
+	err := func() error {
+		mw, err := archive.create(batchManifestName)
+		if err != nil {
+			return err
+		}
+		if err := json.NewEncoder(mw).Encode(manifest); err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			ew, err := archive.create(entry.name)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(ew, entry.body); err != nil {
+				return err
+			}
+		}
+		return archive.Close()
+	}()
+	if err != nil {
+		h.logger.ErrorContext(ctx, "streaming batch zip response", "err", err)
+	}
+}
+
+// writeBatchMultipart writes the manifest and then every page as parts of a multipart/mixed response.
+func (h *ConvertHandler) writeBatchMultipart(ctx context.Context, w http.ResponseWriter, manifest batchManifest, entries []batchEntry, contentType string) {
+	mw := multipart.NewWriter(w)
+	w.Header().Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+	w.WriteHeader(http.StatusOK)
+
+	err := func() error {
+		part, err := mw.CreatePart(batchPartHeader("application/json", batchManifestName))
+		if err != nil {
+			return err
+		}
+		if err := json.NewEncoder(part).Encode(manifest); err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			part, err := mw.CreatePart(batchPartHeader(contentType, entry.name))
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(part, entry.body); err != nil {
+				return err
+			}
+		}
+		return mw.Close()
+	}()
+	if err != nil {
+		h.logger.ErrorContext(ctx, "streaming batch multipart response", "err", err)
+	}
+}
+
+func batchPartHeader(contentType, filename string) textproto.MIMEHeader {
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	return header
+}
+
+// pageSpool keeps the encoded pages of one batch file in a temporary file until the response is
+// written, so a batch does not hold its output in memory.
+type pageSpool struct {
+	file *os.File
+	// budget is shared by the spools of one batch; writes past it fail with errBatchTooLarge.
+	budget *atomic.Int64
+	size   int64
+	pages  []spooledPage
+}
+
+type spooledPage struct {
+	page   int
+	offset int64
+	size   int64
+}
+
+func newPageSpool(budget *atomic.Int64) (*pageSpool, error) {
+	file, err := os.CreateTemp("", "pdf2jpg-batch-*")
+	if err != nil {
+		return nil, err
+	}
+	return &pageSpool{file: file, budget: budget}, nil
+}
+
+// Next starts a new page at the end of the spool.
+func (s *pageSpool) Next(page int) (io.Writer, error) {
+	s.pages = append(s.pages, spooledPage{page: page, offset: s.size})
+	return s, nil
+}
+
+func (s *pageSpool) Write(b []byte) (int, error) {
+	if s.budget.Add(-int64(len(b))) < 0 {
+		s.budget.Add(int64(len(b)))
+		return 0, fmt.Errorf("%w: limit %d bytes", errBatchTooLarge, maxBatchSpoolBytes)
+	}
+	n, err := s.file.Write(b)
+	s.budget.Add(int64(len(b) - n))
+	s.size += int64(n)
+	s.pages[len(s.pages)-1].size += int64(n)
+	return n, err
+}
+
+func (s *pageSpool) reader(p spooledPage) io.Reader {
+	return io.NewSectionReader(s.file, p.offset, p.size)
+}
+
+// Close removes the spool file and returns its bytes to the budget. It is safe to call on a nil spool.
+func (s *pageSpool) Close() {
+	if s == nil {
+		return
+	}
+	s.file.Close()
+	util.RemoveFile(s.file.Name())
+	s.budget.Add(s.size)
+	s.size = 0
+}
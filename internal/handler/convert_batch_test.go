@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPageSpool_SharesBatchBudget(t *testing.T) {
+	var budget atomic.Int64
+	budget.Store(10)
+	first, err := newPageSpool(&budget)
+	if err != nil {
+		t.Fatalf("new spool: %v", err)
+	}
+	second, err := newPageSpool(&budget)
+	if err != nil {
+		t.Fatalf("new spool: %v", err)
+	}
+	defer second.Close()
+
+	w, _ := first.Next(1)
+	if _, err := w.Write(make([]byte, 6)); err != nil {
+		t.Fatalf("write within budget: %v", err)
+	}
+	w, _ = second.Next(1)
+	_, err = w.Write(make([]byte, 6))
+	if !errors.Is(err, errBatchTooLarge) {
+		t.Fatalf("expected errBatchTooLarge past the batch budget, got %v", err)
+	}
+	if status, _ := classifyConversionError(err); status != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", status)
+	}
+
+	// A failed file's pages are dropped, so its bytes become available again.
+	first.Close()
+	if _, err := io.WriteString(w, "0123456789"); err != nil {
+		t.Fatalf("write after release: %v", err)
+	}
+}
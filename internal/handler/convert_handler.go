@@ -3,36 +3,57 @@ package handler
 import (
 	"context"
 	"encoding/json"
-	"errors"
-	"fmt"
-	"log"
-	"mime/multipart"
+	"log/slog"
 	"net/http"
-	"path/filepath"
 	"strings"
 
+	"pdf2jpg/internal/doctype"
 	"pdf2jpg/internal/service"
 	"pdf2jpg/internal/util"
 )
 
-const uploadField = "file"
+const (
+	uploadField = "file"
+	pagesField  = "pages"
+	outputField = "output"
+)
+
+const (
+	outputAuto  = ""
+	outputImage = "image"
+	outputZip   = "zip"
+)
 
-// PDFConverter defines the conversion behavior required by the handler.
+// PDFConverter defines the conversion behavior required by the handler. Pages are encoded straight
+// into the writers returned by next, so responses are never buffered whole.
 type PDFConverter interface {
-	ConvertFirstPage(ctx context.Context, pdfPath string) ([]byte, error)
+	StreamPages(ctx context.Context, pdfPath string, sel service.PageSelector, opts service.ConvertOptions, next service.PageWriterFunc) error
+}
+
+// resultKeyer is implemented by converters that can identify a result before rendering it. Responses
+// carry an ETag when it returns a key, and the digest is handed back so the file is hashed only once.
+type resultKeyer interface {
+	ResultKey(ctx context.Context, pdfPath string, sel service.PageSelector, opts service.ConvertOptions) (key, digest string, err error)
 }
 
 // ConvertHandler handles POST /convert requests.
 type ConvertHandler struct {
 	converter   PDFConverter
-	logger      *log.Logger
+	fetcher     URLFetcher
+	renders     RenderLimiter
+	inputs      *doctype.Registry
+	logger      *slog.Logger
 	maxFileSize int64
 }
 
-// NewConvertHandler returns a configured ConvertHandler.
-func NewConvertHandler(converter PDFConverter, logger *log.Logger, maxFileSize int64) http.Handler {
+// NewConvertHandler returns a configured ConvertHandler. A nil fetcher disables JSON {"url": ...} requests,
+// a nil renders limiter lets every request render at once and nil inputs accept PDF only.
+func NewConvertHandler(converter PDFConverter, fetcher URLFetcher, renders RenderLimiter, inputs *doctype.Registry, logger *slog.Logger, maxFileSize int64) http.Handler {
 	return &ConvertHandler{
 		converter:   converter,
+		fetcher:     fetcher,
+		renders:     renders,
+		inputs:      inputs,
 		logger:      logger,
 		maxFileSize: maxFileSize,
 	}
@@ -44,84 +65,219 @@ func (h *ConvertHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	r.Body = http.MaxBytesReader(w, r.Body, h.maxFileSize)
+	var upload *pdfUpload
+	var ok bool
+	switch mediaType := requestMediaType(r); {
+	case doctype.IsDocumentMediaType(mediaType):
+		upload, ok = readRawPDFUpload(w, r, h.maxFileSize, h.inputs)
+	case mediaType != "application/json":
+		if !parseUploadForm(w, r, h.maxFileSize, h.logger) {
+			return
+		}
+		if len(r.MultipartForm.File[uploadField]) > 1 {
+			h.serveBatch(w, r)
+			return
+		}
+		upload, ok = readPDFUpload(w, r, h.maxFileSize, h.inputs, h.logger)
+	case h.fetcher != nil:
+		upload, ok = readURLUpload(w, r, h.fetcher, h.inputs, h.logger)
+	default:
+		writeJSONError(w, http.StatusUnsupportedMediaType, "url input is disabled")
+		return
+	}
+	if !ok {
+		return
+	}
+	defer upload.Close()
 
-	if err := r.ParseMultipartForm(h.maxFileSize); err != nil {
-		h.handleMultipartError(w, err)
+	req, ok := parseConvertRequest(w, r)
+	if !ok {
 		return
 	}
 
-	file, header, err := r.FormFile(uploadField)
-	if err != nil {
-		h.logger.Printf("WARN: missing file field: %v", err)
-		writeJSONError(w, http.StatusBadRequest, "file field is required")
+	tempPath, ok := upload.Save(r.Context(), w, h.logger)
+	if !ok {
 		return
 	}
-	defer file.Close()
+	defer util.RemoveFile(tempPath)
 
-	if header.Size > 0 && header.Size > h.maxFileSize {
-		writeJSONError(w, http.StatusRequestEntityTooLarge, "file too large")
+	if etag := h.resultETag(r, tempPath, &req); etag != "" {
+		w.Header().Set("ETag", etag)
+		// The client already has this result, so answer before waiting for a render slot.
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	release, ok := acquireRender(w, r, h.renders, h.logger)
+	if !ok {
 		return
 	}
+	defer release()
+
+	baseName := upload.BaseName()
 
-	if !strings.HasSuffix(strings.ToLower(header.Filename), ".pdf") {
-		writeJSONError(w, http.StatusBadRequest, "file must be a pdf")
+	if req.output == outputZip {
+		h.writeArchive(w, r, tempPath, req.selector, req.opts, baseName, req.encoder.Extension())
 		return
 	}
 
-	tempPath, err := util.SaveUploadedFile(file, header.Filename)
+	h.writeImage(w, r, tempPath, req.selector, req.opts, baseName+req.encoder.Extension(), req.encoder.ContentType())
+}
+
+// convertRequest holds the validated conversion parameters shared by /convert and /jobs.
+type convertRequest struct {
+	selector service.PageSelector
+	opts     service.ConvertOptions
+	encoder  service.Encoder
+	// output is outputImage or outputZip; automatic selection is already resolved.
+	output string
+}
+
+// parseConvertRequest reads pages, output and rendering options from the parsed form. On failure it
+// writes the error response itself and returns false.
+func parseConvertRequest(w http.ResponseWriter, r *http.Request) (convertRequest, bool) {
+	req, ok := parseRenderRequest(w, r)
+	if !ok {
+		return req, false
+	}
+
+	req.output = strings.ToLower(strings.TrimSpace(r.FormValue(outputField)))
+	switch req.output {
+	case outputAuto:
+		req.output = outputImage
+		if !req.selector.IsSingle() {
+			req.output = outputZip
+		}
+	case outputImage:
+		if !req.selector.IsSingle() {
+			writeJSONError(w, http.StatusBadRequest, "pages must select a single page")
+			return req, false
+		}
+	case outputZip:
+	default:
+		writeJSONError(w, http.StatusBadRequest, "invalid output parameter")
+		return req, false
+	}
+
+	return req, true
+}
+
+// parseRenderRequest reads pages and rendering options, leaving output unset. On failure it writes the
+// error response itself and returns false.
+func parseRenderRequest(w http.ResponseWriter, r *http.Request) (convertRequest, bool) {
+	var req convertRequest
+	var err error
+
+	req.selector, err = service.ParsePageSelector(r.FormValue(pagesField))
 	if err != nil {
-		h.logger.Printf("ERROR: saving uploaded file: %v", err)
-		writeJSONError(w, http.StatusInternalServerError, "failed to process file")
-		return
+		writeJSONError(w, http.StatusBadRequest, "invalid pages parameter")
+		return req, false
 	}
-	defer util.RemoveFile(tempPath)
 
-	jpegBytes, err := h.converter.ConvertFirstPage(r.Context(), tempPath)
+	req.opts, err = parseConvertOptions(r)
 	if err != nil {
-		h.handleConversionError(w, err)
-		return
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return req, false
 	}
 
-	outputName := strings.TrimSuffix(filepath.Base(header.Filename), filepath.Ext(header.Filename)) + ".jpg"
+	req.encoder, err = service.LookupEncoder(req.opts.Format)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "unsupported format")
+		return req, false
+	}
+
+	return req, true
+}
+
+// resultETag returns the ETag for the response, or "" when the converter does not key its results. It
+// records the input digest in req so the conversion does not hash the file again. ZIP entries carry
+// their creation time, so archives only get a weak validator.
+func (h *ConvertHandler) resultETag(r *http.Request, pdfPath string, req *convertRequest) string {
+	keyer, ok := h.converter.(resultKeyer)
+	if !ok {
+		return ""
+	}
+	key, digest, err := keyer.ResultKey(r.Context(), pdfPath, req.selector, req.opts)
+	if err != nil || key == "" {
+		// Option errors are reported by the conversion itself.
+		return ""
+	}
+	req.opts.InputDigest = digest
+	if req.output == outputZip {
+		return `W/"` + key + `-zip"`
+	}
+	return `"` + key + `"`
+}
 
-	w.Header().Set("Content-Type", "image/jpeg")
-	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, outputName))
-	w.WriteHeader(http.StatusOK)
-	if _, err := w.Write(jpegBytes); err != nil {
-		h.logger.Printf("ERROR: sending jpeg response: %v", err)
+// etagMatches reports whether an If-None-Match header value lists etag. As RFC 9110 requires for
+// If-None-Match, the comparison is weak: W/ prefixes are ignored.
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
 	}
+	return false
 }
 
-func (h *ConvertHandler) handleMultipartError(w http.ResponseWriter, err error) {
-	var maxErr *http.MaxBytesError
-	if errors.As(err, &maxErr) {
-		writeJSONError(w, http.StatusRequestEntityTooLarge, "file too large")
+// writeImage streams a single page into the response body as it is encoded.
+func (h *ConvertHandler) writeImage(w http.ResponseWriter, r *http.Request, pdfPath string, selector service.PageSelector, opts service.ConvertOptions, filename, contentType string) {
+	resp := newPageResponse(w, contentType, filename)
+	err := h.converter.StreamPages(r.Context(), pdfPath, selector, opts, resp.Next)
+	if err == nil {
 		return
 	}
-
-	if errors.Is(err, multipart.ErrMessageTooLarge) {
-		writeJSONError(w, http.StatusRequestEntityTooLarge, "file too large")
+	if !resp.Started() {
+		handleConversionError(r.Context(), w, h.logger, err)
 		return
 	}
-
-	h.logger.Printf("WARN: multipart parse error: %v", err)
-	writeJSONError(w, http.StatusBadRequest, "invalid multipart form data")
+	h.logger.ErrorContext(r.Context(), "sending image response", "err", err)
 }
 
-func (h *ConvertHandler) handleConversionError(w http.ResponseWriter, err error) {
-	if errors.Is(err, service.ErrPDFHasNoPages) {
-		writeJSONError(w, http.StatusBadRequest, "pdf has no pages")
+// writeArchive streams every selected page into a ZIP response, one page at a time.
+func (h *ConvertHandler) writeArchive(w http.ResponseWriter, r *http.Request, pdfPath string, selector service.PageSelector, opts service.ConvertOptions, baseName, ext string) {
+	archive := newPageArchive(w, baseName, ext)
+	err := h.converter.StreamPages(r.Context(), pdfPath, selector, opts, archive.Next)
+	if err != nil {
+		if !archive.Started() {
+			handleConversionError(r.Context(), w, h.logger, err)
+			return
+		}
+		// The status line is already on the wire; leave the archive truncated so clients notice.
+		h.logger.ErrorContext(r.Context(), "streaming zip response", "err", err)
 		return
 	}
+	if err := archive.Close(); err != nil {
+		h.logger.ErrorContext(r.Context(), "finalising zip response", "err", err)
+	}
+}
 
-	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
-		writeJSONError(w, http.StatusRequestTimeout, "request canceled")
-		return
+// handleConversionError maps service errors to client responses. It is shared by every rendering endpoint.
+func handleConversionError(ctx context.Context, w http.ResponseWriter, logger *slog.Logger, err error) {
+	status, message := classifyConversionError(err)
+	// A validator set for the successful response must not describe the error body.
+	w.Header().Del("ETag")
+	if status == http.StatusInternalServerError {
+		logger.ErrorContext(ctx, "convert pages", "err", err)
 	}
+	writeJSONError(w, status, message)
+}
 
-	h.logger.Printf("ERROR: convert first page: %v", err)
-	writeJSONError(w, http.StatusInternalServerError, "failed to convert pdf")
+// classifyConversionError returns the HTTP status and client-facing message for a service error, and
+// counts the error under its class.
+func classifyConversionError(err error) (int, string) {
+	for _, c := range conversionErrorClasses {
+		if c.matches(err) {
+			conversionErrors.WithLabelValues(c.class).Inc()
+			return c.status, c.message
+		}
+	}
+	conversionErrors.WithLabelValues(internalErrorClass).Inc()
+	return http.StatusInternalServerError, "failed to convert pdf"
 }
 
 func writeJSONError(w http.ResponseWriter, status int, message string) {
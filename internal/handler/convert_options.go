@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"pdf2jpg/internal/service"
+)
+
+const (
+	dpiField    = "dpi"
+	widthField  = "width"
+	heightField = "height"
+	fitField    = "fit"
+	formatField = "format"
+
+	cropField      = "crop"
+	cropUnitsField = "cropUnits"
+	trimField      = "trim"
+	rotateField    = "rotate"
+
+	qualityField   = "quality"
+	chromaField    = "chroma"
+	grayscaleField = "grayscale"
+	maxBytesField  = "maxBytes"
+	passwordField  = "password"
+)
+
+// parseConvertOptions reads rendering options from the parsed form. Errors carry the client-facing message.
+func parseConvertOptions(r *http.Request) (service.ConvertOptions, error) {
+	var opts service.ConvertOptions
+
+	if raw := strings.TrimSpace(r.FormValue(dpiField)); raw != "" {
+		dpi, err := strconv.ParseFloat(raw, 64)
+		if err != nil || dpi <= 0 {
+			return opts, errors.New("invalid dpi parameter")
+		}
+		opts.DPI = dpi
+	}
+
+	var err error
+	if opts.Width, err = parsePositiveInt(r.FormValue(widthField)); err != nil {
+		return opts, errors.New("invalid width parameter")
+	}
+	if opts.Height, err = parsePositiveInt(r.FormValue(heightField)); err != nil {
+		return opts, errors.New("invalid height parameter")
+	}
+	if opts.DPI > 0 && (opts.Width > 0 || opts.Height > 0) {
+		return opts, errors.New("dpi cannot be combined with width or height")
+	}
+
+	if opts.Fit, err = service.ParseFitMode(r.FormValue(fitField)); err != nil {
+		return opts, errors.New("invalid fit parameter")
+	}
+
+	var relative bool
+	switch strings.ToLower(strings.TrimSpace(r.FormValue(cropUnitsField))) {
+	case "", "points":
+	case "fraction":
+		relative = true
+	default:
+		return opts, errors.New("invalid cropUnits parameter")
+	}
+	if raw := strings.TrimSpace(r.FormValue(cropField)); raw != "" {
+		if opts.Crop, err = service.ParseCrop(raw, relative); err != nil {
+			return opts, errors.New("invalid crop parameter")
+		}
+		opts.CropRelative = relative
+	}
+	if raw := strings.TrimSpace(r.FormValue(trimField)); raw != "" {
+		if opts.Trim, err = strconv.ParseBool(raw); err != nil {
+			return opts, errors.New("invalid trim parameter")
+		}
+	}
+	if opts.Rotate, err = service.ParseRotation(r.FormValue(rotateField)); err != nil {
+		return opts, errors.New("invalid rotate parameter")
+	}
+
+	if raw := strings.TrimSpace(r.FormValue(formatField)); raw != "" {
+		if opts.Format, err = service.ParseFormat(raw); err != nil {
+			return opts, errors.New("unsupported format")
+		}
+	} else {
+		opts.Format = negotiateFormat(r.Header.Get("Accept"))
+	}
+
+	if opts.Quality, err = parsePositiveInt(r.FormValue(qualityField)); err != nil || opts.Quality > 100 {
+		return opts, errors.New("invalid quality parameter")
+	}
+	if raw := strings.TrimSpace(r.FormValue(chromaField)); raw != "" {
+		if opts.Chroma, err = service.ParseChroma(raw); err != nil {
+			return opts, errors.New("invalid chroma parameter")
+		}
+	}
+	if raw := strings.TrimSpace(r.FormValue(grayscaleField)); raw != "" {
+		if opts.Grayscale, err = strconv.ParseBool(raw); err != nil {
+			return opts, errors.New("invalid grayscale parameter")
+		}
+	}
+	if opts.MaxBytes, err = parsePositiveInt(r.FormValue(maxBytesField)); err != nil {
+		return opts, errors.New("invalid maxBytes parameter")
+	}
+
+	// Passwords are used verbatim; surrounding spaces may be significant.
+	opts.Password = r.FormValue(passwordField)
+
+	return opts, nil
+}
+
+// parsePositiveInt returns 0 for an empty value.
+func parsePositiveInt(raw string) (int, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n <= 0 {
+		return 0, errors.New("not a positive integer")
+	}
+	return n, nil
+}
+
+func isVectorFormat(format service.Format) bool {
+	enc, err := service.LookupEncoder(format)
+	if err != nil {
+		return false
+	}
+	_, vector := enc.(service.PageExporter)
+	return vector
+}
+
+// negotiateFormat picks the most preferred registered image type from an Accept header,
+// falling back to JPEG so that generic clients keep working.
+func negotiateFormat(accept string) service.Format {
+	type mediaRange struct {
+		mime string
+		q    float64
+	}
+
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mime, params, _ := strings.Cut(part, ";")
+		mime = strings.ToLower(strings.TrimSpace(mime))
+		if mime == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.EqualFold(key, "q") {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, mediaRange{mime: mime, q: q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+
+	for _, mr := range ranges {
+		// Vector formats are only produced on explicit request: browsers list text/html first.
+		if format, ok := service.FormatForContentType(mr.mime); ok && !isVectorFormat(format) {
+			return format
+		}
+		if mr.mime == "image/*" || mr.mime == "*/*" {
+			break
+		}
+	}
+	return service.FormatJPEG
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pdf2jpg/internal/service"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	cases := map[string]service.Format{
+		"":                                      service.FormatJPEG,
+		"*/*":                                   service.FormatJPEG,
+		"image/png":                             service.FormatPNG,
+		"image/avif,image/webp;q=0.9,*/*;q=0.8": service.FormatAVIF,
+		"image/webp;q=0.5, image/png":           service.FormatPNG,
+		"image/gif, image/webp;q=0.1":           service.FormatWebP,
+		"image/avif;q=0, image/*":               service.FormatJPEG,
+		"application/json":                      service.FormatJPEG,
+		"image/svg+xml":                         service.FormatJPEG,
+		"text/html,image/webp;q=0.9":            service.FormatWebP,
+	}
+	for accept, want := range cases {
+		if got := negotiateFormat(accept); got != want {
+			t.Fatalf("negotiateFormat(%q): expected %s, got %s", accept, want, got)
+		}
+	}
+}
+
+func TestParseConvertOptions_Geometry(t *testing.T) {
+	parse := func(query string) (service.ConvertOptions, error) {
+		r := httptest.NewRequest(http.MethodPost, "/convert?"+query, nil)
+		return parseConvertOptions(r)
+	}
+
+	opts, err := parse("crop=72,72,144,36&rotate=90&trim=true")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.Crop != (service.Rect{X: 72, Y: 72, W: 144, H: 36}) || opts.CropRelative || opts.Rotate != 90 || !opts.Trim {
+		t.Fatalf("unexpected options %+v", opts)
+	}
+
+	opts, err = parse("crop=0,0.5,1,0.5&cropUnits=fraction")
+	if err != nil || !opts.CropRelative || opts.Crop.Y != 0.5 {
+		t.Fatalf("expected relative crop, got %+v (%v)", opts, err)
+	}
+
+	for query, want := range map[string]string{
+		"crop=1,2,3":                      "invalid crop parameter",
+		"crop=0,0,2,1&cropUnits=fraction": "invalid crop parameter",
+		"cropUnits=inches":                "invalid cropUnits parameter",
+		"rotate=45":                       "invalid rotate parameter",
+		"trim=maybe":                      "invalid trim parameter",
+	} {
+		if _, err := parse(query); err == nil || err.Error() != want {
+			t.Fatalf("%s: expected %q, got %v", query, want, err)
+		}
+	}
+}
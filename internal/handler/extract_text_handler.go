@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"pdf2jpg/internal/doctype"
+	"pdf2jpg/internal/service"
+	"pdf2jpg/internal/util"
+)
+
+const blocksField = "blocks"
+
+// TextExtractor defines the text extraction behavior required by ExtractTextHandler.
+type TextExtractor interface {
+	ExtractText(ctx context.Context, pdfPath string, sel service.PageSelector, password string, withBlocks bool) ([]service.PageText, error)
+}
+
+// ExtractTextHandler handles POST /extract/text requests.
+type ExtractTextHandler struct {
+	extractor   TextExtractor
+	renders     RenderLimiter
+	inputs      *doctype.Registry
+	logger      *slog.Logger
+	maxFileSize int64
+}
+
+// NewExtractTextHandler returns a configured ExtractTextHandler. A nil renders limiter lets every request render at
+// once and nil inputs accept PDF only.
+func NewExtractTextHandler(extractor TextExtractor, renders RenderLimiter, inputs *doctype.Registry, logger *slog.Logger, maxFileSize int64) http.Handler {
+	return &ExtractTextHandler{
+		extractor:   extractor,
+		renders:     renders,
+		inputs:      inputs,
+		logger:      logger,
+		maxFileSize: maxFileSize,
+	}
+}
+
+func (h *ExtractTextHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	upload, ok := readPDFUpload(w, r, h.maxFileSize, h.inputs, h.logger)
+	if !ok {
+		return
+	}
+	defer upload.Close()
+
+	selector := service.AllPages()
+	if raw := strings.TrimSpace(r.FormValue(pagesField)); raw != "" {
+		var err error
+		if selector, err = service.ParsePageSelector(raw); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid pages parameter")
+			return
+		}
+	}
+
+	var withBlocks bool
+	if raw := strings.TrimSpace(r.FormValue(blocksField)); raw != "" {
+		var err error
+		if withBlocks, err = strconv.ParseBool(raw); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid blocks parameter")
+			return
+		}
+	}
+
+	tempPath, ok := upload.Save(r.Context(), w, h.logger)
+	if !ok {
+		return
+	}
+	defer util.RemoveFile(tempPath)
+
+	release, ok := acquireRender(w, r, h.renders, h.logger)
+	if !ok {
+		return
+	}
+	defer release()
+
+	pages, err := h.extractor.ExtractText(r.Context(), tempPath, selector, r.FormValue(passwordField), withBlocks)
+	if err != nil {
+		handleConversionError(r.Context(), w, h.logger, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"pages": pages})
+}
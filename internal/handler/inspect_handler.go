@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"pdf2jpg/internal/doctype"
+	"pdf2jpg/internal/service"
+	"pdf2jpg/internal/util"
+)
+
+// DocumentInspector defines the metadata behavior required by InspectHandler.
+type DocumentInspector interface {
+	Inspect(ctx context.Context, pdfPath string, password string) (service.DocumentInfo, error)
+}
+
+// InspectHandler handles POST /inspect requests.
+type InspectHandler struct {
+	inspector   DocumentInspector
+	renders     RenderLimiter
+	inputs      *doctype.Registry
+	logger      *slog.Logger
+	maxFileSize int64
+}
+
+// NewInspectHandler returns a configured InspectHandler. Inspection loads every page, so it shares the
+// render limiter with the conversion endpoints. Nil inputs accept PDF only.
+func NewInspectHandler(inspector DocumentInspector, renders RenderLimiter, inputs *doctype.Registry, logger *slog.Logger, maxFileSize int64) http.Handler {
+	return &InspectHandler{
+		inspector:   inspector,
+		renders:     renders,
+		inputs:      inputs,
+		logger:      logger,
+		maxFileSize: maxFileSize,
+	}
+}
+
+func (h *InspectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	upload, ok := readPDFUpload(w, r, h.maxFileSize, h.inputs, h.logger)
+	if !ok {
+		return
+	}
+	defer upload.Close()
+
+	tempPath, ok := upload.Save(r.Context(), w, h.logger)
+	if !ok {
+		return
+	}
+	defer util.RemoveFile(tempPath)
+
+	release, ok := acquireRender(w, r, h.renders, h.logger)
+	if !ok {
+		return
+	}
+	defer release()
+
+	info, err := h.inspector.Inspect(r.Context(), tempPath, r.FormValue(passwordField))
+	if err != nil {
+		handleConversionError(r.Context(), w, h.logger, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, info)
+}
@@ -0,0 +1,306 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"pdf2jpg/internal/auth"
+	"pdf2jpg/internal/doctype"
+	"pdf2jpg/internal/jobs"
+	"pdf2jpg/internal/limiter"
+	"pdf2jpg/internal/service"
+	"pdf2jpg/internal/util"
+)
+
+const (
+	jobsPath            = "/jobs"
+	jobResultSuffix     = "/result"
+	jobQueueRetryAfter  = "10"
+	jobRenderRetryDelay = time.Second
+	ownerHashPrefixSize = 16
+	callbackURLField    = "callbackUrl"
+)
+
+// JobConverter defines the conversion behavior required to run jobs.
+type JobConverter interface {
+	CountPages(ctx context.Context, pdfPath string, sel service.PageSelector, password string) (int, error)
+	StreamPages(ctx context.Context, pdfPath string, sel service.PageSelector, opts service.ConvertOptions, next service.PageWriterFunc) error
+}
+
+// JobManager queues jobs and exposes their state.
+type JobManager interface {
+	Submit(ctx context.Context, owner string, run jobs.RunFunc, callback *jobs.Callback) (jobs.Job, error)
+	Get(ctx context.Context, id string) (jobs.Job, error)
+	Result(ctx context.Context, id string) (jobs.Result, error)
+}
+
+// JobsHandler handles POST /jobs, GET /jobs/{id} and GET /jobs/{id}/result.
+type JobsHandler struct {
+	converter   JobConverter
+	jobs        JobManager
+	renders     RenderLimiter
+	inputs      *doctype.Registry
+	logger      *slog.Logger
+	maxFileSize int64
+}
+
+// NewJobsHandler returns a configured JobsHandler. Mount it on both /jobs and /jobs/. Jobs render under
+// the same limiter as synchronous requests. Nil inputs accept PDF only.
+func NewJobsHandler(converter JobConverter, manager JobManager, renders RenderLimiter, inputs *doctype.Registry, logger *slog.Logger, maxFileSize int64) http.Handler {
+	return &JobsHandler{
+		converter:   converter,
+		jobs:        manager,
+		renders:     renders,
+		inputs:      inputs,
+		logger:      logger,
+		maxFileSize: maxFileSize,
+	}
+}
+
+func (h *JobsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, jobsPath), "/")
+	switch {
+	case rest == "":
+		if r.Method != http.MethodPost {
+			writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		h.submit(w, r)
+	case r.Method != http.MethodGet:
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+	case strings.HasSuffix(rest, jobResultSuffix):
+		h.result(w, r, strings.TrimSuffix(rest, jobResultSuffix))
+	case !strings.Contains(rest, "/"):
+		h.status(w, r, rest)
+	default:
+		writeJSONError(w, http.StatusNotFound, "job not found")
+	}
+}
+
+func (h *JobsHandler) submit(w http.ResponseWriter, r *http.Request) {
+	upload, ok := readPDFUpload(w, r, h.maxFileSize, h.inputs, h.logger)
+	if !ok {
+		return
+	}
+	defer upload.Close()
+
+	req, ok := parseConvertRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var callback *jobs.Callback
+	if raw := strings.TrimSpace(r.FormValue(callbackURLField)); raw != "" {
+		if err := jobs.ValidateCallbackURL(raw); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid callbackUrl parameter")
+			return
+		}
+		// Callbacks are signed with the submitting API key so each client can verify its own deliveries.
+		apiKey, _ := auth.APIKeyFromContext(r.Context())
+		callback = &jobs.Callback{URL: raw, Secret: apiKey}
+	}
+
+	tempPath, ok := upload.Save(r.Context(), w, h.logger)
+	if !ok {
+		return
+	}
+
+	job, err := h.jobs.Submit(r.Context(), jobOwner(r), h.runFunc(tempPath, upload.BaseName(), req), callback)
+	if err != nil {
+		util.RemoveFile(tempPath)
+		if errors.Is(err, jobs.ErrQueueFull) {
+			w.Header().Set("Retry-After", jobQueueRetryAfter)
+			writeJSONError(w, http.StatusServiceUnavailable, "job queue full")
+			return
+		}
+		h.logger.ErrorContext(r.Context(), "submit job", "err", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to submit job")
+		return
+	}
+
+	w.Header().Set("Location", jobsPath+"/"+job.ID)
+	writeJSON(w, http.StatusAccepted, jobResponse(job))
+}
+
+// runFunc converts the saved upload in the background. It owns pdfPath and removes it when done.
+func (h *JobsHandler) runFunc(pdfPath, baseName string, req convertRequest) jobs.RunFunc {
+	return func(ctx context.Context, progress jobs.Progress) (jobs.Result, error) {
+		defer util.RemoveFile(pdfPath)
+
+		release, err := waitForRender(ctx, h.renders)
+		if err != nil {
+			return jobs.Result{}, h.jobError(ctx, err)
+		}
+		defer release()
+
+		total, err := h.converter.CountPages(ctx, pdfPath, req.selector, req.opts.Password)
+		if err != nil {
+			return jobs.Result{}, h.jobError(ctx, err)
+		}
+		progress(0, total)
+
+		var buf bytes.Buffer
+		var archive *pageArchive
+		if req.output == outputZip {
+			archive = newPageArchiveWriter(&buf, baseName, req.encoder.Extension())
+		}
+		done := 0
+		next := func(page int) (io.Writer, error) {
+			// The previous page is fully encoded once the converter asks for the next writer.
+			if done > 0 {
+				progress(done, total)
+			}
+			done++
+			if archive != nil {
+				return archive.Next(page)
+			}
+			return &buf, nil
+		}
+
+		if err := h.converter.StreamPages(ctx, pdfPath, req.selector, req.opts, next); err != nil {
+			return jobs.Result{}, h.jobError(ctx, err)
+		}
+		progress(done, total)
+
+		if archive == nil {
+			return jobs.Result{
+				ContentType: req.encoder.ContentType(),
+				Filename:    baseName + req.encoder.Extension(),
+				Data:        buf.Bytes(),
+			}, nil
+		}
+		if err := archive.Close(); err != nil {
+			return jobs.Result{}, h.jobError(ctx, fmt.Errorf("finalise zip: %w", err))
+		}
+		return jobs.Result{
+			ContentType: "application/zip",
+			Filename:    baseName + ".zip",
+			Data:        buf.Bytes(),
+		}, nil
+	}
+}
+
+// waitForRender takes a render slot for a background job. Unlike synchronous requests, a job is not
+// turned away when the render queue is full or slow: it keeps retrying until a slot frees up or ctx
+// ends. A nil limiter never blocks.
+func waitForRender(ctx context.Context, renders RenderLimiter) (func(), error) {
+	if renders == nil {
+		return func() {}, nil
+	}
+	for {
+		release, err := renders.Acquire(ctx)
+		if !errors.Is(err, limiter.ErrQueueFull) && !errors.Is(err, limiter.ErrQueueTimeout) {
+			return release, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(jobRenderRetryDelay):
+		}
+	}
+}
+
+// jobError converts a service error into the client-facing message stored on the job.
+func (h *JobsHandler) jobError(ctx context.Context, err error) error {
+	status, message := classifyConversionError(err)
+	if status == http.StatusInternalServerError {
+		h.logger.ErrorContext(ctx, "run job", "err", err)
+	}
+	return errors.New(message)
+}
+
+func (h *JobsHandler) status(w http.ResponseWriter, r *http.Request, id string) {
+	job, ok := h.lookup(w, r, id)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, jobResponse(job))
+}
+
+func (h *JobsHandler) result(w http.ResponseWriter, r *http.Request, id string) {
+	job, ok := h.lookup(w, r, id)
+	if !ok {
+		return
+	}
+	switch job.Status {
+	case jobs.StatusSucceeded:
+	case jobs.StatusFailed:
+		writeJSONError(w, http.StatusConflict, "job failed: "+job.Error)
+		return
+	default:
+		writeJSONError(w, http.StatusConflict, "job not finished")
+		return
+	}
+
+	result, err := h.jobs.Result(r.Context(), id)
+	if errors.Is(err, jobs.ErrJobNotFound) || errors.Is(err, jobs.ErrResultNotReady) {
+		writeJSONError(w, http.StatusNotFound, "job not found")
+		return
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "fetch job result", "err", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to fetch job result")
+		return
+	}
+
+	setPageHeaders(w.Header(), result.ContentType, result.Filename)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(result.Data); err != nil {
+		h.logger.ErrorContext(r.Context(), "sending job result", "err", err)
+	}
+}
+
+// lookup fetches a job owned by the caller. Jobs belonging to other keys are reported as missing.
+func (h *JobsHandler) lookup(w http.ResponseWriter, r *http.Request, id string) (jobs.Job, bool) {
+	job, err := h.jobs.Get(r.Context(), id)
+	if errors.Is(err, jobs.ErrJobNotFound) || (err == nil && job.Owner != jobOwner(r)) {
+		writeJSONError(w, http.StatusNotFound, "job not found")
+		return jobs.Job{}, false
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "fetch job", "err", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to fetch job")
+		return jobs.Job{}, false
+	}
+	return job, true
+}
+
+// jobOwner identifies the caller by a hash of their API key so raw keys are never stored.
+func jobOwner(r *http.Request) string {
+	key, _ := auth.APIKeyFromContext(r.Context())
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])[:ownerHashPrefixSize]
+}
+
+func jobResponse(job jobs.Job) map[string]interface{} {
+	resp := map[string]interface{}{
+		"id":         job.ID,
+		"status":     job.Status,
+		"pagesDone":  job.PagesDone,
+		"pagesTotal": job.PagesTotal,
+		"createdAt":  job.CreatedAt.UTC().Format(time.RFC3339),
+		"updatedAt":  job.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+	if job.Error != "" {
+		resp["error"] = job.Error
+	}
+	if job.CallbackURL != "" {
+		resp["callbackUrl"] = job.CallbackURL
+	}
+	if !job.ExpiresAt.IsZero() {
+		resp["expiresAt"] = job.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	if job.Status == jobs.StatusSucceeded {
+		resp["resultUrl"] = jobsPath + "/" + job.ID + jobResultSuffix
+	}
+	return resp
+}
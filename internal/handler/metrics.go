@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"pdf2jpg/internal/metrics"
+	"pdf2jpg/internal/service"
+)
+
+// internalErrorClass labels conversion errors that match no known class.
+const internalErrorClass = "internal"
+
+var conversionErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Name:      "conversion_errors_total",
+	Help:      "Failed conversions, by error class.",
+}, []string{"class"})
+
+// conversionErrorClass maps the service errors matched by one class to the client response.
+type conversionErrorClass struct {
+	class   string
+	errs    []error
+	status  int
+	message string
+}
+
+func (c conversionErrorClass) matches(err error) bool {
+	for _, target := range c.errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// conversionErrorClasses is checked in order; the first match wins.
+var conversionErrorClasses = []conversionErrorClass{
+	{"encrypted", []error{service.ErrPDFEncrypted}, http.StatusUnauthorized, "pdf is encrypted"},
+	{"bad_password", []error{service.ErrPDFBadPassword}, http.StatusUnprocessableEntity, "incorrect pdf password"},
+	{"no_pages", []error{service.ErrPDFHasNoPages}, http.StatusBadRequest, "pdf has no pages"},
+	{"page_out_of_range", []error{service.ErrPageOutOfRange}, http.StatusBadRequest, "page out of range"},
+	{"invalid_options", []error{service.ErrInvalidRenderOptions}, http.StatusBadRequest, "invalid render options"},
+	{"unsupported_format", []error{service.ErrUnsupportedFormat}, http.StatusBadRequest, "unsupported format"},
+	{"unsupported_image", []error{service.ErrUnsupportedImage}, http.StatusBadRequest, "unsupported image"},
+	{"too_many_pages", []error{service.ErrTooManyPages}, http.StatusUnprocessableEntity, "too many pages selected"},
+	{"batch_too_large", []error{errBatchTooLarge}, http.StatusUnprocessableEntity, "batch output too large"},
+	{"limit_exceeded", []error{service.ErrRenderLimitExceeded}, http.StatusBadRequest, "requested size exceeds server limits"},
+	{"output_too_large", []error{service.ErrOutputTooLarge}, http.StatusUnprocessableEntity, "output cannot fit within maxBytes"},
+	{"worker_crashed", []error{service.ErrWorkerCrashed}, http.StatusUnprocessableEntity, "pdf could not be rendered"},
+	{"canceled", []error{context.Canceled, context.DeadlineExceeded}, http.StatusRequestTimeout, "request canceled"},
+}
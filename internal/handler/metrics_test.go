@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"pdf2jpg/internal/service"
+)
+
+func TestClassifyConversionError_CountsClass(t *testing.T) {
+	tests := []struct {
+		err     error
+		class   string
+		status  int
+		message string
+	}{
+		{fmt.Errorf("open: %w", service.ErrPDFEncrypted), "encrypted", http.StatusUnauthorized, "pdf is encrypted"},
+		{fmt.Errorf("page 3: %w", service.ErrOutputTooLarge), "output_too_large", http.StatusUnprocessableEntity, "output cannot fit within maxBytes"},
+		{fmt.Errorf("%w: %w", service.ErrTooManyPages, service.ErrRenderLimitExceeded), "too_many_pages", http.StatusUnprocessableEntity, "too many pages selected"},
+		{context.DeadlineExceeded, "canceled", http.StatusRequestTimeout, "request canceled"},
+		{errors.New("mupdf exploded"), internalErrorClass, http.StatusInternalServerError, "failed to convert pdf"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.class, func(t *testing.T) {
+			counter := conversionErrors.WithLabelValues(tc.class)
+			before := testutil.ToFloat64(counter)
+			status, message := classifyConversionError(tc.err)
+			if status != tc.status || message != tc.message {
+				t.Fatalf("expected %d %q, got %d %q", tc.status, tc.message, status, message)
+			}
+			if got := testutil.ToFloat64(counter) - before; got != 1 {
+				t.Fatalf("expected the %s counter to grow by 1, got %v", tc.class, got)
+			}
+		})
+	}
+}
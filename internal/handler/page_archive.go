@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// pageArchive streams rendered pages into a ZIP response. Headers are only sent once the first
+// page is ready so that failures before any output can still be reported as JSON errors.
+type pageArchive struct {
+	w io.Writer
+	// begin runs before the first entry is written; it is nil for archives that are not HTTP responses.
+	begin    func()
+	baseName string
+	ext      string
+	zw       *zip.Writer
+	names    map[string]int
+}
+
+func newPageArchive(w http.ResponseWriter, baseName, ext string) *pageArchive {
+	a := newPageArchiveWriter(w, baseName, ext)
+	a.begin = func() {
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, baseName))
+		w.WriteHeader(http.StatusOK)
+	}
+	return a
+}
+
+// newPageArchiveWriter builds an archive into an arbitrary writer, such as a job result buffer.
+func newPageArchiveWriter(w io.Writer, baseName, ext string) *pageArchive {
+	return &pageArchive{
+		w:        w,
+		baseName: baseName,
+		ext:      ext,
+		names:    make(map[string]int),
+	}
+}
+
+// Started reports whether the response headers have already been written.
+func (a *pageArchive) Started() bool {
+	return a.zw != nil
+}
+
+// Next opens the archive entry for the given 1-based page.
+func (a *pageArchive) Next(page int) (io.Writer, error) {
+	return a.create(uniquePageName(a.names, a.baseName, page, a.ext))
+}
+
+// create opens an entry with an exact name, starting the archive if needed.
+func (a *pageArchive) create(name string) (io.Writer, error) {
+	if a.zw == nil {
+		if a.begin != nil {
+			a.begin()
+		}
+		a.zw = zip.NewWriter(a.w)
+	}
+
+	// Encoded images are already compressed, so entries are stored rather than deflated.
+	return a.zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Store,
+		Modified: time.Now().UTC(),
+	})
+}
+
+// uniquePageName names a page file, such as "sample-p001.jpg", adding a counter when names has
+// already handed out the same name.
+func uniquePageName(names map[string]int, baseName string, page int, ext string) string {
+	name := fmt.Sprintf("%s-p%03d", baseName, page)
+	names[name]++
+	if n := names[name]; n > 1 {
+		name = fmt.Sprintf("%s-%d", name, n)
+	}
+	return name + ext
+}
+
+// Close writes the ZIP central directory.
+func (a *pageArchive) Close() error {
+	if a.zw == nil {
+		return nil
+	}
+	return a.zw.Close()
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"pdf2jpg/internal/service"
+)
+
+// pageResponse streams a single encoded page straight into the HTTP response. Like pageArchive, it
+// only sends headers with the first byte so that failures before any output are still reported as
+// JSON errors.
+type pageResponse struct {
+	w           http.ResponseWriter
+	contentType string
+	filename    string
+	started     bool
+}
+
+func newPageResponse(w http.ResponseWriter, contentType, filename string) *pageResponse {
+	return &pageResponse{w: w, contentType: contentType, filename: filename}
+}
+
+// Next returns the response itself; a single-page response has nothing to open per page.
+func (p *pageResponse) Next(int) (io.Writer, error) {
+	return p, nil
+}
+
+// Started reports whether the response headers have already been written.
+func (p *pageResponse) Started() bool {
+	return p.started
+}
+
+func (p *pageResponse) Write(b []byte) (int, error) {
+	if !p.started {
+		p.started = true
+		setPageHeaders(p.w.Header(), p.contentType, p.filename)
+		p.w.WriteHeader(http.StatusOK)
+	}
+	return p.w.Write(b)
+}
+
+// setPageHeaders sets the type and disposition of a single page or archive. SVG and HTML pages can
+// carry script and links, so they are offered as downloads and, if a browser renders them anyway,
+// sandboxed and never re-sniffed as another type.
+func setPageHeaders(header http.Header, contentType, filename string) {
+	disposition := "inline"
+	if contentType == "application/zip" || service.IsActiveContent(contentType) {
+		disposition = "attachment"
+	}
+	if service.IsActiveContent(contentType) {
+		header.Set("Content-Security-Policy", "sandbox")
+		header.Set("X-Content-Type-Options", "nosniff")
+	}
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Disposition", fmt.Sprintf(`%s; filename="%s"`, disposition, filename))
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"pdf2jpg/internal/limiter"
+)
+
+// renderRetryAfter is the Retry-After hint, in seconds, sent when the render queue turns a request away.
+const renderRetryAfter = "5"
+
+// RenderLimiter bounds how many requests render at once. Acquire returns the function that frees the slot.
+type RenderLimiter interface {
+	Acquire(ctx context.Context) (func(), error)
+}
+
+// acquireRender waits for a render slot. A nil limiter never blocks. On failure it writes the error
+// response itself and returns false.
+func acquireRender(w http.ResponseWriter, r *http.Request, renders RenderLimiter, logger *slog.Logger) (func(), bool) {
+	if renders == nil {
+		return func() {}, true
+	}
+	release, err := renders.Acquire(r.Context())
+	switch {
+	case err == nil:
+		return release, true
+	case errors.Is(err, limiter.ErrQueueFull):
+		logger.WarnContext(r.Context(), "rejecting request: render queue full", "path", r.URL.Path)
+		w.Header().Set("Retry-After", renderRetryAfter)
+		writeJSONError(w, http.StatusServiceUnavailable, "server busy")
+	case errors.Is(err, limiter.ErrQueueTimeout):
+		logger.WarnContext(r.Context(), "rejecting request: timed out waiting for a render slot", "path", r.URL.Path)
+		w.Header().Set("Retry-After", renderRetryAfter)
+		writeJSONError(w, http.StatusServiceUnavailable, "server busy")
+	default:
+		handleConversionError(r.Context(), w, logger, err)
+	}
+	return nil, false
+}
@@ -0,0 +1,23 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// RequestTimeout returns middleware that ends each request's context after timeout. Renders watch the
+// context, so a request that runs too long is aborted part-way through its page and answered with 408.
+// A non-positive timeout leaves requests unbounded.
+func RequestTimeout(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if timeout <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	var deadline time.Time
+	var ok bool
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, ok = r.Context().Deadline()
+	})
+
+	RequestTimeout(time.Minute)(inner).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/convert", nil))
+	if !ok || time.Until(deadline) > time.Minute {
+		t.Fatalf("expected a deadline within a minute, got %v (%v)", deadline, ok)
+	}
+
+	RequestTimeout(0)(inner).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/convert", nil))
+	if ok {
+		t.Fatalf("expected no deadline, got %v", deadline)
+	}
+}
@@ -0,0 +1,268 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"pdf2jpg/internal/doctype"
+	"pdf2jpg/internal/urlfetch"
+	"pdf2jpg/internal/util"
+)
+
+const (
+	urlField      = "url"
+	filenameField = "filename"
+	// defaultUploadBaseName names raw documents that arrive without a filename.
+	defaultUploadBaseName = "document"
+	// maxJSONBodySize caps JSON request bodies, which carry only a URL and options.
+	maxJSONBodySize = 64 << 10
+)
+
+// URLFetcher downloads documents referenced by JSON requests.
+type URLFetcher interface {
+	Fetch(ctx context.Context, rawURL string) (*urlfetch.Document, error)
+}
+
+// pdfUpload is a PDF or other accepted document, uploaded or fetched, that passed the checks shared by
+// every rendering endpoint.
+type pdfUpload struct {
+	body     io.ReadCloser
+	filename string
+	docType  doctype.Type
+}
+
+// readPDFUpload parses the multipart body and checks that the uploaded file has an accepted extension.
+// On failure it writes the error response itself and returns false.
+func readPDFUpload(w http.ResponseWriter, r *http.Request, maxFileSize int64, inputs *doctype.Registry, logger *slog.Logger) (*pdfUpload, bool) {
+	if !parseUploadForm(w, r, maxFileSize, logger) {
+		return nil, false
+	}
+
+	file, header, err := r.FormFile(uploadField)
+	if err != nil {
+		logger.WarnContext(r.Context(), "missing file field", "err", err)
+		writeJSONError(w, http.StatusBadRequest, "file field is required")
+		return nil, false
+	}
+
+	if header.Size > 0 && header.Size > maxFileSize {
+		file.Close()
+		writeJSONError(w, http.StatusRequestEntityTooLarge, "file too large")
+		return nil, false
+	}
+
+	docType, ok := inputs.ByFilename(header.Filename)
+	if !ok {
+		file.Close()
+		writeJSONError(w, http.StatusBadRequest, "file must be a "+inputs.String())
+		return nil, false
+	}
+
+	return &pdfUpload{body: file, filename: header.Filename, docType: docType}, true
+}
+
+// parseUploadForm parses a size-limited multipart body, unless the request was already parsed. On
+// failure it writes the error response itself and returns false.
+func parseUploadForm(w http.ResponseWriter, r *http.Request, maxFileSize int64, logger *slog.Logger) bool {
+	if r.MultipartForm != nil {
+		return true
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxFileSize)
+	if err := r.ParseMultipartForm(maxFileSize); err != nil {
+		handleMultipartError(r.Context(), w, logger, err)
+		return false
+	}
+	return true
+}
+
+// requestMediaType returns the lower-cased media type of the request body.
+func requestMediaType(r *http.Request) string {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType
+}
+
+// readRawPDFUpload accepts the request body itself as the document. The filename comes from the
+// filename query parameter or a Content-Disposition header, and options are read from the query
+// string. The type follows the filename's extension, or the Content-Type when there is no filename.
+// The body is size-limited here and sniffed for the type's magic bytes when saved. On failure it
+// writes the error response itself and returns false.
+func readRawPDFUpload(w http.ResponseWriter, r *http.Request, maxFileSize int64, inputs *doctype.Registry) (*pdfUpload, bool) {
+	if r.ContentLength > maxFileSize {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, "file too large")
+		return nil, false
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxFileSize)
+	r.Form = r.URL.Query()
+	r.PostForm = url.Values{}
+
+	filename := strings.TrimSpace(r.Form.Get(filenameField))
+	if filename == "" {
+		if _, params, err := mime.ParseMediaType(r.Header.Get("Content-Disposition")); err == nil {
+			filename = params["filename"]
+		}
+	}
+
+	var docType doctype.Type
+	var ok bool
+	if filename == "" {
+		if docType, ok = inputs.ByMediaType(requestMediaType(r)); ok {
+			filename = defaultUploadBaseName + docType.Extension()
+		}
+	} else {
+		docType, ok = inputs.ByFilename(filename)
+	}
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "file must be a "+inputs.String())
+		return nil, false
+	}
+
+	return &pdfUpload{body: r.Body, filename: filename, docType: docType}, true
+}
+
+// readURLUpload decodes a JSON body of the form {"url": "...", ...options} and starts fetching the
+// document. The remaining scalar fields are exposed through r.Form, with query parameters as
+// fallbacks, so that option parsing is shared with multipart requests. On failure it writes the
+// error response itself and returns false.
+func readURLUpload(w http.ResponseWriter, r *http.Request, fetcher URLFetcher, inputs *doctype.Registry, logger *slog.Logger) (*pdfUpload, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxJSONBodySize)
+
+	var fields map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, "request body too large")
+			return nil, false
+		}
+		writeJSONError(w, http.StatusBadRequest, "invalid json body")
+		return nil, false
+	}
+
+	form := url.Values{}
+	for key, values := range r.URL.Query() {
+		form[key] = values
+	}
+	for key, value := range fields {
+		switch v := value.(type) {
+		case string:
+			form.Set(key, v)
+		case float64:
+			form.Set(key, strconv.FormatFloat(v, 'f', -1, 64))
+		case bool:
+			form.Set(key, strconv.FormatBool(v))
+		case nil:
+		default:
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("invalid %s parameter", key))
+			return nil, false
+		}
+	}
+	r.Form = form
+	r.PostForm = form
+
+	rawURL := strings.TrimSpace(form.Get(urlField))
+	if rawURL == "" {
+		writeJSONError(w, http.StatusBadRequest, "url field is required")
+		return nil, false
+	}
+
+	doc, err := fetcher.Fetch(r.Context(), rawURL)
+	if err != nil {
+		if errors.Is(err, urlfetch.ErrUnexpectedContentType) {
+			writeJSONError(w, http.StatusBadRequest, "url must point to a "+inputs.String())
+			return nil, false
+		}
+		handleFetchError(r.Context(), w, logger, err)
+		return nil, false
+	}
+	return &pdfUpload{body: doc.Body, filename: doc.Filename, docType: doc.Type}, true
+}
+
+// Save copies the upload to a temporary file. The caller removes it with util.RemoveFile.
+func (u *pdfUpload) Save(ctx context.Context, w http.ResponseWriter, logger *slog.Logger) (string, bool) {
+	path, err := util.SaveUploadedFile(u.body, u.docType)
+	if err != nil {
+		if errors.Is(err, util.ErrContentMismatch) {
+			writeJSONError(w, http.StatusBadRequest, "file content does not match its type")
+			return "", false
+		}
+		// Raw bodies and fetched documents are still being received here, so transfer failures
+		// surface from the copy.
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, "file too large")
+			return "", false
+		}
+		if errors.Is(err, urlfetch.ErrTooLarge) || isTimeout(err) {
+			handleFetchError(ctx, w, logger, err)
+			return "", false
+		}
+		logger.ErrorContext(ctx, "saving uploaded file", "err", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to process file")
+		return "", false
+	}
+	return path, true
+}
+
+// BaseName is the uploaded file name without directory or extension, used to name outputs.
+func (u *pdfUpload) BaseName() string {
+	return outputBaseName(u.filename)
+}
+
+// outputBaseName strips the directory and extension from an uploaded file name.
+func outputBaseName(filename string) string {
+	return strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+}
+
+func (u *pdfUpload) Close() error {
+	return u.body.Close()
+}
+
+// handleFetchError maps URL fetch failures to client responses.
+func handleFetchError(ctx context.Context, w http.ResponseWriter, logger *slog.Logger, err error) {
+	switch {
+	case errors.Is(err, urlfetch.ErrInvalidURL):
+		writeJSONError(w, http.StatusBadRequest, "invalid url parameter")
+	case errors.Is(err, urlfetch.ErrHostNotAllowed), errors.Is(err, urlfetch.ErrBlockedAddress):
+		logger.WarnContext(ctx, "blocked url fetch", "err", err)
+		writeJSONError(w, http.StatusBadRequest, "url not allowed")
+	case errors.Is(err, urlfetch.ErrTooLarge):
+		writeJSONError(w, http.StatusRequestEntityTooLarge, "file too large")
+	case isTimeout(err):
+		writeJSONError(w, http.StatusGatewayTimeout, "timed out fetching url")
+	default:
+		logger.WarnContext(ctx, "url fetch failed", "err", err)
+		writeJSONError(w, http.StatusBadGateway, "failed to fetch url")
+	}
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+func handleMultipartError(ctx context.Context, w http.ResponseWriter, logger *slog.Logger, err error) {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, "file too large")
+		return
+	}
+
+	if errors.Is(err, multipart.ErrMessageTooLarge) {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, "file too large")
+		return
+	}
+
+	logger.WarnContext(ctx, "multipart parse error", "err", err)
+	writeJSONError(w, http.StatusBadRequest, "invalid multipart form data")
+}
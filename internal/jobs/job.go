@@ -0,0 +1,58 @@
+package jobs
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrJobNotFound indicates that the job does not exist or has expired.
+	ErrJobNotFound = errors.New("job not found")
+	// ErrResultNotReady indicates that the job has not produced a result (yet).
+	ErrResultNotReady = errors.New("job result not ready")
+	// ErrQueueFull is returned by Submit when every worker is busy and the queue is at capacity.
+	ErrQueueFull = errors.New("job queue full")
+	// ErrResultStorageFull is returned by Store.PutResult when the result would exceed the store's
+	// byte budget. Its message is shown to clients as the job's error.
+	ErrResultStorageFull = errors.New("job result storage full")
+)
+
+// Status is the lifecycle state of a job.
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
+
+// Job is the metadata tracked for an asynchronous conversion.
+type Job struct {
+	ID string
+	// Owner identifies the submitting client; only the owner may read the job.
+	Owner      string
+	Status     Status
+	PagesDone  int
+	PagesTotal int
+	// CallbackURL is notified when the job finishes; empty when the client polls instead.
+	CallbackURL string
+	// Error is a client-facing failure message, set when Status is StatusFailed.
+	Error     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// ExpiresAt is set when the job finishes; the job and its result are deleted after it.
+	ExpiresAt time.Time
+}
+
+// Finished reports whether the job reached a terminal state.
+func (j Job) Finished() bool {
+	return j.Status == StatusSucceeded || j.Status == StatusFailed
+}
+
+// Result is the output of a successful job.
+type Result struct {
+	ContentType string
+	Filename    string
+	Data        []byte
+}
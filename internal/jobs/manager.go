@@ -0,0 +1,276 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"pdf2jpg/internal/logging"
+)
+
+const (
+	defaultWorkers         = 2
+	defaultQueueSize       = 16
+	defaultResultTTL       = time.Hour
+	defaultCleanupInterval = time.Minute
+	defaultJobTimeout      = 15 * time.Minute
+	jobIDBytes             = 16
+)
+
+// Progress reports how many of the job's pages have been rendered.
+type Progress func(done, total int)
+
+// RunFunc performs the work of a job. It must release any resources it owns (such as temporary
+// files) before returning, including when ctx is already canceled. Errors returned by RunFunc are
+// shown to clients verbatim, so they must not leak internal details.
+type RunFunc func(ctx context.Context, progress Progress) (Result, error)
+
+// Config captures tunables for Manager. Zero fields fall back to defaults.
+type Config struct {
+	Workers         int
+	QueueSize       int
+	ResultTTL       time.Duration
+	CleanupInterval time.Duration
+	JobTimeout      time.Duration
+	// Notifier announces finished jobs that were submitted with a callback. Nil disables callbacks.
+	Notifier Notifier
+	Now      func() time.Time
+}
+
+func (c Config) withDefaults() Config {
+	if c.Workers <= 0 {
+		c.Workers = defaultWorkers
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = defaultQueueSize
+	}
+	if c.ResultTTL <= 0 {
+		c.ResultTTL = defaultResultTTL
+	}
+	if c.CleanupInterval <= 0 {
+		c.CleanupInterval = defaultCleanupInterval
+	}
+	if c.JobTimeout <= 0 {
+		c.JobTimeout = defaultJobTimeout
+	}
+	if c.Now == nil {
+		c.Now = func() time.Time { return time.Now().UTC() }
+	}
+	return c
+}
+
+type task struct {
+	id       string
+	run      RunFunc
+	callback *Callback
+	// requestID is the ID of the submitting request, so the job's log lines can be traced back to it.
+	requestID string
+}
+
+// Manager runs submitted jobs on a bounded worker pool and expires their results.
+type Manager struct {
+	store  Store
+	logger *slog.Logger
+	cfg    Config
+	queue  chan task
+	wg     sync.WaitGroup
+}
+
+func NewManager(store Store, logger *slog.Logger, cfg Config) *Manager {
+	cfg = cfg.withDefaults()
+	return &Manager{
+		store:  store,
+		logger: logger,
+		cfg:    cfg,
+		queue:  make(chan task, cfg.QueueSize),
+	}
+}
+
+// Start launches the workers and the cleanup loop. They stop when ctx is canceled; call Wait to
+// block until queued jobs have been drained.
+func (m *Manager) Start(ctx context.Context) {
+	for i := 0; i < m.cfg.Workers; i++ {
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			m.work(ctx)
+		}()
+	}
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		m.cleanupLoop(ctx)
+	}()
+}
+
+// Wait blocks until every worker has exited.
+func (m *Manager) Wait() {
+	m.wg.Wait()
+}
+
+// Submit records a new job for owner and queues run. When callback is non-nil the configured
+// Notifier is told once the job finishes. It returns ErrQueueFull instead of blocking.
+func (m *Manager) Submit(ctx context.Context, owner string, run RunFunc, callback *Callback) (Job, error) {
+	id, err := newJobID()
+	if err != nil {
+		return Job{}, fmt.Errorf("generate job id: %w", err)
+	}
+	now := m.cfg.Now()
+	job := Job{
+		ID:        id,
+		Owner:     owner,
+		Status:    StatusQueued,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if callback != nil {
+		job.CallbackURL = callback.URL
+	}
+	if err := m.store.Create(ctx, job); err != nil {
+		return Job{}, fmt.Errorf("persist job: %w", err)
+	}
+
+	select {
+	case m.queue <- task{id: id, run: run, callback: callback, requestID: logging.RequestID(ctx)}:
+		return job, nil
+	default:
+		if err := m.store.Delete(ctx, id); err != nil {
+			m.logger.WarnContext(ctx, "remove rejected job", "job_id", id, "err", err)
+		}
+		return Job{}, ErrQueueFull
+	}
+}
+
+// Get returns the job with id. Expired jobs are reported as ErrJobNotFound even before cleanup runs.
+func (m *Manager) Get(ctx context.Context, id string) (Job, error) {
+	job, err := m.store.Get(ctx, id)
+	if err != nil {
+		return Job{}, err
+	}
+	if !job.ExpiresAt.IsZero() && job.ExpiresAt.Before(m.cfg.Now()) {
+		return Job{}, ErrJobNotFound
+	}
+	return job, nil
+}
+
+// Result returns the output of a succeeded job.
+func (m *Manager) Result(ctx context.Context, id string) (Result, error) {
+	if _, err := m.Get(ctx, id); err != nil {
+		return Result{}, err
+	}
+	return m.store.Result(ctx, id)
+}
+
+func (m *Manager) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			m.drain()
+			return
+		case t := <-m.queue:
+			m.execute(ctx, t)
+		}
+	}
+}
+
+// drain fails jobs that were still queued at shutdown. Their RunFunc is invoked with a canceled
+// context so that it can release its resources.
+func (m *Manager) drain() {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	for {
+		select {
+		case t := <-m.queue:
+			m.execute(canceled, t)
+		default:
+			return
+		}
+	}
+}
+
+func (m *Manager) execute(ctx context.Context, t task) {
+	ctx = logging.WithRequestID(ctx, t.requestID)
+	// Store updates use a fresh context so that a canceled job can still record its outcome.
+	storeCtx := logging.WithRequestID(context.Background(), t.requestID)
+	if _, err := m.store.Update(storeCtx, t.id, func(j *Job) {
+		j.Status = StatusRunning
+		j.UpdatedAt = m.cfg.Now()
+	}); err != nil {
+		m.logger.WarnContext(storeCtx, "mark job running", "job_id", t.id, "err", err)
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, m.cfg.JobTimeout)
+	defer cancel()
+	result, err := t.run(runCtx, func(done, total int) {
+		if _, err := m.store.Update(storeCtx, t.id, func(j *Job) {
+			j.PagesDone, j.PagesTotal = done, total
+			j.UpdatedAt = m.cfg.Now()
+		}); err != nil {
+			m.logger.WarnContext(storeCtx, "update job progress", "job_id", t.id, "err", err)
+		}
+	})
+
+	if err == nil {
+		switch putErr := m.store.PutResult(storeCtx, t.id, result); {
+		case putErr == nil:
+		case errors.Is(putErr, ErrResultStorageFull):
+			err = putErr
+			m.logger.WarnContext(storeCtx, "discard job result", "job_id", t.id, "bytes", len(result.Data), "err", putErr)
+		default:
+			err = errors.New("failed to store result")
+			m.logger.ErrorContext(storeCtx, "store job result", "job_id", t.id, "err", putErr)
+		}
+	}
+
+	now := m.cfg.Now()
+	finished, updateErr := m.store.Update(storeCtx, t.id, func(j *Job) {
+		j.UpdatedAt = now
+		j.ExpiresAt = now.Add(m.cfg.ResultTTL)
+		if err != nil {
+			j.Status = StatusFailed
+			j.Error = err.Error()
+			return
+		}
+		j.Status = StatusSucceeded
+	})
+	if updateErr != nil {
+		m.logger.WarnContext(storeCtx, "finish job", "job_id", t.id, "err", updateErr)
+		return
+	}
+	if t.callback != nil && m.cfg.Notifier != nil {
+		m.cfg.Notifier.Notify(finished, *t.callback)
+	}
+}
+
+func (m *Manager) cleanupLoop(ctx context.Context) {
+	ticker := time.NewTicker(m.cfg.CleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			count, err := m.store.DeleteExpired(ctx, m.cfg.Now())
+			if err != nil {
+				m.logger.Warn("delete expired jobs", "err", err)
+				continue
+			}
+			if count > 0 {
+				m.logger.Info("deleted expired jobs", "count", count)
+			}
+		}
+	}
+}
+
+func newJobID() (string, error) {
+	buf := make([]byte, jobIDBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
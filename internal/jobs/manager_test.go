@@ -0,0 +1,196 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"pdf2jpg/internal/logging"
+)
+
+var discardLogger = slog.New(slog.DiscardHandler)
+
+func waitFor(t *testing.T, m *Manager, id string, status Status) Job {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := m.Get(context.Background(), id)
+		if err != nil {
+			t.Fatalf("get job: %v", err)
+		}
+		if job.Status == status {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not reach %s", id, status)
+	return Job{}
+}
+
+func TestManager_RunsJobAndStoresResult(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := NewManager(NewMemoryStore(MemoryStoreConfig{}), discardLogger, Config{Workers: 1})
+	m.Start(ctx)
+	defer func() { cancel(); m.Wait() }()
+
+	job, err := m.Submit(ctx, "owner", func(_ context.Context, progress Progress) (Result, error) {
+		progress(1, 2)
+		progress(2, 2)
+		return Result{ContentType: "image/jpeg", Data: []byte("jpeg")}, nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if job.Status != StatusQueued {
+		t.Fatalf("expected queued, got %s", job.Status)
+	}
+
+	done := waitFor(t, m, job.ID, StatusSucceeded)
+	if done.PagesDone != 2 || done.PagesTotal != 2 || done.ExpiresAt.IsZero() {
+		t.Fatalf("unexpected finished job %+v", done)
+	}
+	result, err := m.Result(ctx, job.ID)
+	if err != nil || string(result.Data) != "jpeg" {
+		t.Fatalf("unexpected result %q (%v)", result.Data, err)
+	}
+}
+
+func TestManager_RecordsFailure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := NewManager(NewMemoryStore(MemoryStoreConfig{}), discardLogger, Config{Workers: 1})
+	m.Start(ctx)
+	defer func() { cancel(); m.Wait() }()
+
+	job, _ := m.Submit(ctx, "owner", func(context.Context, Progress) (Result, error) {
+		return Result{}, errors.New("page out of range")
+	}, nil)
+	failed := waitFor(t, m, job.ID, StatusFailed)
+	if failed.Error != "page out of range" {
+		t.Fatalf("unexpected error %q", failed.Error)
+	}
+	if _, err := m.Result(ctx, job.ID); !errors.Is(err, ErrResultNotReady) {
+		t.Fatalf("expected ErrResultNotReady, got %v", err)
+	}
+}
+
+func TestManager_RunsWithSubmittingRequestID(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := NewManager(NewMemoryStore(MemoryStoreConfig{}), discardLogger, Config{Workers: 1})
+	m.Start(ctx)
+	defer func() { cancel(); m.Wait() }()
+
+	seen := make(chan string, 1)
+	job, err := m.Submit(logging.WithRequestID(ctx, "req-42"), "owner", func(ctx context.Context, _ Progress) (Result, error) {
+		seen <- logging.RequestID(ctx)
+		return Result{}, nil
+	}, nil)
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	waitFor(t, m, job.ID, StatusSucceeded)
+	if id := <-seen; id != "req-42" {
+		t.Fatalf("expected the job to run with request ID req-42, got %q", id)
+	}
+}
+
+func TestManager_QueueFull(t *testing.T) {
+	// Workers are not started, so the single queue slot fills immediately.
+	store := NewMemoryStore(MemoryStoreConfig{})
+	m := NewManager(store, discardLogger, Config{QueueSize: 1})
+	noop := func(context.Context, Progress) (Result, error) { return Result{}, nil }
+
+	if _, err := m.Submit(context.Background(), "owner", noop, nil); err != nil {
+		t.Fatalf("first submit: %v", err)
+	}
+	if _, err := m.Submit(context.Background(), "owner", noop, nil); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+	if len(store.jobs) != 1 {
+		t.Fatalf("expected rejected job to be removed, have %d jobs", len(store.jobs))
+	}
+}
+
+func TestManager_ExpiresResults(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore(MemoryStoreConfig{})
+	m := NewManager(store, discardLogger, Config{ResultTTL: time.Minute, Now: func() time.Time { return now }})
+
+	job, _ := m.Submit(context.Background(), "owner", func(context.Context, Progress) (Result, error) {
+		return Result{Data: []byte("x")}, nil
+	}, nil)
+	m.execute(context.Background(), <-m.queue)
+
+	now = now.Add(2 * time.Minute)
+	if _, err := m.Get(context.Background(), job.ID); !errors.Is(err, ErrJobNotFound) {
+		t.Fatalf("expected expired job to be hidden, got %v", err)
+	}
+	if n, _ := store.DeleteExpired(context.Background(), now); n != 1 {
+		t.Fatalf("expected 1 expired job deleted, got %d", n)
+	}
+}
+
+func TestManager_ResultBudget(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore(MemoryStoreConfig{MaxResultBytes: 10, MaxOwnerResultBytes: 6})
+	m := NewManager(store, discardLogger, Config{ResultTTL: time.Minute, Now: func() time.Time { return now }})
+	run := func(owner string, size int) Job {
+		t.Helper()
+		job, err := m.Submit(context.Background(), owner, func(context.Context, Progress) (Result, error) {
+			return Result{Data: make([]byte, size)}, nil
+		}, nil)
+		if err != nil {
+			t.Fatalf("submit: %v", err)
+		}
+		m.execute(context.Background(), <-m.queue)
+		finished, _ := store.Get(context.Background(), job.ID)
+		return finished
+	}
+
+	if job := run("a", 5); job.Status != StatusSucceeded {
+		t.Fatalf("expected first result to fit, got %+v", job)
+	}
+	if job := run("a", 2); job.Status != StatusFailed || !strings.Contains(job.Error, "for this api key") {
+		t.Fatalf("expected the owner's budget to be exceeded, got %+v", job)
+	}
+	if job := run("b", 5); job.Status != StatusSucceeded {
+		t.Fatalf("expected another owner's result to fit, got %+v", job)
+	}
+	if job := run("c", 1); job.Status != StatusFailed || job.Error != ErrResultStorageFull.Error() {
+		t.Fatalf("expected the total budget to be exceeded, got %+v", job)
+	}
+
+	// Expired results give their bytes back.
+	now = now.Add(2 * time.Minute)
+	if _, err := store.DeleteExpired(context.Background(), now); err != nil {
+		t.Fatalf("delete expired: %v", err)
+	}
+	if job := run("a", 6); job.Status != StatusSucceeded {
+		t.Fatalf("expected the budget to be freed, got %+v", job)
+	}
+}
+
+func TestManager_DrainsQueueOnShutdown(t *testing.T) {
+	m := NewManager(NewMemoryStore(MemoryStoreConfig{}), discardLogger, Config{Workers: 1})
+	released := make(chan struct{}, 1)
+	job, _ := m.Submit(context.Background(), "owner", func(ctx context.Context, _ Progress) (Result, error) {
+		released <- struct{}{}
+		return Result{}, ctx.Err()
+	}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	m.Start(ctx)
+	m.Wait()
+
+	select {
+	case <-released:
+	default:
+		t.Fatal("expected queued job to run with a canceled context")
+	}
+	if got, _ := m.store.Get(context.Background(), job.ID); got.Status != StatusFailed {
+		t.Fatalf("expected failed job, got %s", got.Status)
+	}
+}
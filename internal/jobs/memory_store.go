@@ -0,0 +1,161 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxResultBytes      = 256 << 20
+	defaultMaxOwnerResultBytes = 64 << 20
+)
+
+// MemoryStoreConfig captures tunables for MemoryStore. Zero fields fall back to defaults.
+type MemoryStoreConfig struct {
+	// MaxResultBytes bounds the combined size of the results held until they expire.
+	MaxResultBytes int64
+	// MaxOwnerResultBytes bounds the results held for any one owner, so one client cannot use up
+	// MaxResultBytes for everyone.
+	MaxOwnerResultBytes int64
+}
+
+func (c MemoryStoreConfig) withDefaults() MemoryStoreConfig {
+	if c.MaxResultBytes <= 0 {
+		c.MaxResultBytes = defaultMaxResultBytes
+	}
+	if c.MaxOwnerResultBytes <= 0 {
+		c.MaxOwnerResultBytes = defaultMaxOwnerResultBytes
+	}
+	return c
+}
+
+// MemoryStore keeps jobs in process memory. Jobs are lost on restart, so it suits single-instance
+// deployments and tests. Results count against a byte budget until the job is deleted.
+type MemoryStore struct {
+	cfg MemoryStoreConfig
+
+	mu          sync.Mutex
+	jobs        map[string]Job
+	results     map[string]Result
+	resultBytes int64
+	ownerBytes  map[string]int64
+}
+
+func NewMemoryStore(cfg MemoryStoreConfig) *MemoryStore {
+	return &MemoryStore{
+		cfg:        cfg.withDefaults(),
+		jobs:       make(map[string]Job),
+		results:    make(map[string]Result),
+		ownerBytes: make(map[string]int64),
+	}
+}
+
+func (s *MemoryStore) Create(_ context.Context, job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.jobs[job.ID]; exists {
+		return fmt.Errorf("job %s already exists", job.ID)
+	}
+	s.jobs[job.ID] = job
+	return nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, id string) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+	return job, nil
+}
+
+func (s *MemoryStore) Update(_ context.Context, id string, fn func(*Job)) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+	fn(&job)
+	s.jobs[id] = job
+	return job, nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleteLocked(id)
+	return nil
+}
+
+// PutResult stores result, or fails with ErrResultStorageFull when it would take the total or the
+// owner's results over budget.
+func (s *MemoryStore) PutResult(_ context.Context, id string, result Result) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return ErrJobNotFound
+	}
+	s.dropResultLocked(id)
+	size := int64(len(result.Data))
+	if s.resultBytes+size > s.cfg.MaxResultBytes {
+		return ErrResultStorageFull
+	}
+	if s.ownerBytes[job.Owner]+size > s.cfg.MaxOwnerResultBytes {
+		return fmt.Errorf("%w for this api key", ErrResultStorageFull)
+	}
+	s.results[id] = result
+	s.resultBytes += size
+	s.ownerBytes[job.Owner] += size
+	return nil
+}
+
+func (s *MemoryStore) Result(_ context.Context, id string) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[id]; !ok {
+		return Result{}, ErrJobNotFound
+	}
+	result, ok := s.results[id]
+	if !ok {
+		return Result{}, ErrResultNotReady
+	}
+	return result, nil
+}
+
+func (s *MemoryStore) DeleteExpired(_ context.Context, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for id, job := range s.jobs {
+		if !job.ExpiresAt.IsZero() && job.ExpiresAt.Before(now) {
+			s.deleteLocked(id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *MemoryStore) deleteLocked(id string) {
+	s.dropResultLocked(id)
+	delete(s.jobs, id)
+}
+
+// dropResultLocked removes the job's result, if any, and returns its bytes to the budget.
+func (s *MemoryStore) dropResultLocked(id string) {
+	result, ok := s.results[id]
+	if !ok {
+		return
+	}
+	size := int64(len(result.Data))
+	owner := s.jobs[id].Owner
+	s.resultBytes -= size
+	if s.ownerBytes[owner] -= size; s.ownerBytes[owner] <= 0 {
+		delete(s.ownerBytes, owner)
+	}
+	delete(s.results, id)
+}
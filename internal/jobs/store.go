@@ -0,0 +1,19 @@
+package jobs
+
+import (
+	"context"
+	"time"
+)
+
+// Store persists jobs and their results. Implementations must be safe for concurrent use.
+type Store interface {
+	Create(ctx context.Context, job Job) error
+	Get(ctx context.Context, id string) (Job, error)
+	// Update applies fn to the stored job atomically and returns the updated copy.
+	Update(ctx context.Context, id string, fn func(*Job)) (Job, error)
+	Delete(ctx context.Context, id string) error
+	PutResult(ctx context.Context, id string, result Result) error
+	Result(ctx context.Context, id string) (Result, error)
+	// DeleteExpired removes finished jobs whose ExpiresAt is before now, returning how many were removed.
+	DeleteExpired(ctx context.Context, now time.Time) (int, error)
+}
@@ -0,0 +1,296 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"pdf2jpg/internal/urlfetch"
+)
+
+const (
+	// SignatureHeader carries "sha256=<hex>" over "<timestamp>.<body>", keyed with the caller's API key.
+	SignatureHeader = "X-PDF2JPG-Signature"
+	// TimestampHeader carries the Unix time the delivery attempt was signed at.
+	TimestampHeader = "X-PDF2JPG-Timestamp"
+	// DeliveryHeader carries a delivery ID that stays the same across retries.
+	DeliveryHeader = "X-PDF2JPG-Delivery"
+
+	defaultWebhookAttempts = 5
+	defaultWebhookBackoff  = time.Second
+	defaultWebhookTimeout  = 10 * time.Second
+	defaultDeliveryLogSize = 500
+)
+
+// ErrInvalidCallbackURL is returned by ValidateCallbackURL for unusable callback targets.
+var ErrInvalidCallbackURL = errors.New("invalid callback url")
+
+// Callback is where and how to announce a finished job.
+type Callback struct {
+	URL string
+	// Secret is the HMAC key; callers use the API key that submitted the job.
+	Secret string
+}
+
+// Notifier is told about every finished job that registered a callback.
+type Notifier interface {
+	Notify(job Job, cb Callback)
+}
+
+// ValidateCallbackURL accepts absolute http(s) URLs without credentials.
+func ValidateCallbackURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || u.User != nil {
+		return fmt.Errorf("%w: %q", ErrInvalidCallbackURL, raw)
+	}
+	return nil
+}
+
+// WebhookPayload is the JSON body POSTed to callback URLs.
+type WebhookPayload struct {
+	JobID      string    `json:"jobId"`
+	Status     Status    `json:"status"`
+	PagesDone  int       `json:"pagesDone"`
+	PagesTotal int       `json:"pagesTotal"`
+	ResultURL  string    `json:"resultUrl,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	FinishedAt time.Time `json:"finishedAt"`
+}
+
+// Delivery is one attempt to call a webhook, kept for the admin delivery log.
+type Delivery struct {
+	ID         string    `json:"id"`
+	JobID      string    `json:"jobId"`
+	URL        string    `json:"url"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Delivered  bool      `json:"delivered"`
+	At         time.Time `json:"at"`
+	DurationMS int64     `json:"durationMs"`
+
+	// refused marks attempts the guard blocked, which retrying cannot fix.
+	refused bool
+}
+
+// WebhookConfig captures tunables for WebhookDispatcher. Zero fields fall back to defaults.
+type WebhookConfig struct {
+	// Guard restricts callback hosts and addresses. Nil refuses internal addresses with no host
+	// allowlist.
+	Guard *urlfetch.Guard
+	// Client defaults to one built by Guard, which does not follow redirects.
+	Client         *http.Client
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	Timeout        time.Duration
+	LogSize        int
+	// ResultURL builds the result location included in payloads.
+	ResultURL func(jobID string) string
+	Now       func() time.Time
+}
+
+func (c WebhookConfig) withDefaults() WebhookConfig {
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = defaultWebhookAttempts
+	}
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = defaultWebhookBackoff
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaultWebhookTimeout
+	}
+	if c.Guard == nil {
+		c.Guard, _ = urlfetch.NewGuard(nil, nil)
+	}
+	if c.Client == nil {
+		// A redirect would send the signed payload somewhere the callback URL did not name.
+		c.Client = c.Guard.Client(c.Timeout, 0)
+	}
+	if c.LogSize <= 0 {
+		c.LogSize = defaultDeliveryLogSize
+	}
+	if c.ResultURL == nil {
+		c.ResultURL = func(string) string { return "" }
+	}
+	if c.Now == nil {
+		c.Now = func() time.Time { return time.Now().UTC() }
+	}
+	return c
+}
+
+// WebhookDispatcher delivers signed job notifications in the background, retrying failed attempts
+// with exponential backoff, and keeps a bounded log of every attempt.
+type WebhookDispatcher struct {
+	cfg    WebhookConfig
+	logger *slog.Logger
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu  sync.Mutex
+	log []Delivery
+}
+
+func NewWebhookDispatcher(logger *slog.Logger, cfg WebhookConfig) *WebhookDispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &WebhookDispatcher{
+		cfg:    cfg.withDefaults(),
+		logger: logger,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Notify schedules delivery of job to cb and returns immediately.
+func (d *WebhookDispatcher) Notify(job Job, cb Callback) {
+	payload := WebhookPayload{
+		JobID:      job.ID,
+		Status:     job.Status,
+		PagesDone:  job.PagesDone,
+		PagesTotal: job.PagesTotal,
+		Error:      job.Error,
+		FinishedAt: job.UpdatedAt,
+	}
+	if job.Status == StatusSucceeded {
+		payload.ResultURL = d.cfg.ResultURL(job.ID)
+	}
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		d.deliver(cb, payload)
+	}()
+}
+
+// Close abandons pending retries and waits for in-flight attempts to finish.
+func (d *WebhookDispatcher) Close() {
+	d.cancel()
+	d.wg.Wait()
+}
+
+// Deliveries returns up to limit logged attempts, newest first. An empty jobID returns all jobs.
+func (d *WebhookDispatcher) Deliveries(jobID string, limit int) []Delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var out []Delivery
+	for i := len(d.log) - 1; i >= 0 && (limit <= 0 || len(out) < limit); i-- {
+		if jobID == "" || d.log[i].JobID == jobID {
+			out = append(out, d.log[i])
+		}
+	}
+	return out
+}
+
+func (d *WebhookDispatcher) deliver(cb Callback, payload WebhookPayload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		d.logger.Error("encode webhook payload", "job_id", payload.JobID, "err", err)
+		return
+	}
+	deliveryID, err := newJobID()
+	if err != nil {
+		d.logger.Error("generate webhook delivery id", "job_id", payload.JobID, "err", err)
+		return
+	}
+
+	backoff := d.cfg.InitialBackoff
+	for attempt := 1; attempt <= d.cfg.MaxAttempts; attempt++ {
+		entry := d.attempt(cb, deliveryID, body)
+		entry.JobID = payload.JobID
+		entry.Attempt = attempt
+		d.record(entry)
+
+		if entry.Delivered {
+			return
+		}
+		if entry.refused || !retryableStatus(entry.StatusCode) || attempt == d.cfg.MaxAttempts {
+			d.logger.Warn("webhook delivery failed", "job_id", payload.JobID, "delivery_id", deliveryID, "attempts", attempt, "status", entry.StatusCode, "err", entry.Error)
+			return
+		}
+		select {
+		case <-d.ctx.Done():
+			d.logger.Warn("webhook retries abandoned at shutdown", "job_id", payload.JobID, "delivery_id", deliveryID)
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (d *WebhookDispatcher) attempt(cb Callback, deliveryID string, body []byte) Delivery {
+	start := d.cfg.Now()
+	entry := Delivery{ID: deliveryID, URL: cb.URL, At: start}
+
+	// In-flight attempts are allowed to finish at shutdown; only pending retries are abandoned.
+	ctx, cancel := context.WithTimeout(context.Background(), d.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cb.URL, bytes.NewReader(body))
+	if err == nil {
+		err = d.cfg.Guard.CheckURL(req.URL)
+	}
+	if err != nil {
+		entry.Error = err.Error()
+		entry.refused = true
+		return entry
+	}
+	timestamp := strconv.FormatInt(start.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(DeliveryHeader, deliveryID)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, "sha256="+SignPayload(cb.Secret, timestamp, body))
+
+	resp, err := d.cfg.Client.Do(req)
+	entry.DurationMS = d.cfg.Now().Sub(start).Milliseconds()
+	if err != nil {
+		entry.Error = err.Error()
+		entry.refused = errors.Is(err, urlfetch.ErrBlockedAddress) || errors.Is(err, urlfetch.ErrHostNotAllowed)
+		return entry
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	entry.StatusCode = resp.StatusCode
+	entry.Delivered = resp.StatusCode >= 200 && resp.StatusCode < 300
+	return entry
+}
+
+func (d *WebhookDispatcher) record(entry Delivery) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.log) >= d.cfg.LogSize {
+		d.log = append(d.log[:0], d.log[1:]...)
+	}
+	d.log = append(d.log, entry)
+}
+
+// SignPayload returns the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with secret.
+func SignPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryableStatus reports whether a failed attempt may succeed later. Transport errors (status 0),
+// timeouts, rate limiting and server errors are retried; other client errors are not.
+func retryableStatus(status int) bool {
+	switch {
+	case status == 0, status == http.StatusRequestTimeout, status == http.StatusTooManyRequests:
+		return true
+	default:
+		return status >= 500
+	}
+}
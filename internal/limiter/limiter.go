@@ -0,0 +1,137 @@
+// Package limiter bounds how many expensive operations, such as page renders, run at once.
+package limiter
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const defaultQueueTimeout = 30 * time.Second
+
+var (
+	// ErrQueueFull is returned when every slot is busy and the wait queue is already at capacity.
+	ErrQueueFull = errors.New("render queue full")
+	// ErrQueueTimeout is returned when a caller waited QueueTimeout without getting a slot.
+	ErrQueueTimeout = errors.New("timed out waiting for a render slot")
+)
+
+// Config captures tunables for Limiter. Zero fields fall back to defaults.
+type Config struct {
+	// MaxConcurrent is how many callers may hold a slot at once. It defaults to GOMAXPROCS.
+	MaxConcurrent int
+	// QueueSize is how many callers may wait for a slot. It defaults to four per slot.
+	QueueSize int
+	// QueueTimeout is how long a caller waits for a slot before giving up.
+	QueueTimeout time.Duration
+}
+
+func (c Config) withDefaults() Config {
+	if c.MaxConcurrent <= 0 {
+		c.MaxConcurrent = runtime.GOMAXPROCS(0)
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = 4 * c.MaxConcurrent
+	}
+	if c.QueueTimeout <= 0 {
+		c.QueueTimeout = defaultQueueTimeout
+	}
+	return c
+}
+
+// Limiter is a counting semaphore with a bounded, time-limited wait queue.
+type Limiter struct {
+	cfg     Config
+	slots   chan struct{}
+	metrics metricsRecorder
+
+	mu       sync.Mutex
+	waiting  int
+	inFlight int
+}
+
+// New returns a Limiter that publishes its queue depth and wait times as Prometheus metrics.
+func New(cfg Config) *Limiter {
+	return newLimiter(cfg, promMetrics{})
+}
+
+func newLimiter(cfg Config, metrics metricsRecorder) *Limiter {
+	cfg = cfg.withDefaults()
+	return &Limiter{
+		cfg:     cfg,
+		slots:   make(chan struct{}, cfg.MaxConcurrent),
+		metrics: metrics,
+	}
+}
+
+// Config returns the effective configuration, with defaults applied.
+func (l *Limiter) Config() Config {
+	return l.cfg
+}
+
+// Acquire waits for a free slot and returns the function that gives it back. It fails fast with
+// ErrQueueFull when the queue is at capacity, with ErrQueueTimeout after QueueTimeout, or with the
+// context's error when ctx ends first.
+func (l *Limiter) Acquire(ctx context.Context) (func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	select {
+	case l.slots <- struct{}{}:
+		l.metrics.ObserveWait(0)
+		return l.acquired(), nil
+	default:
+	}
+
+	l.mu.Lock()
+	if l.waiting >= l.cfg.QueueSize {
+		l.mu.Unlock()
+		l.metrics.IncRejected(rejectQueueFull)
+		return nil, ErrQueueFull
+	}
+	l.waiting++
+	l.metrics.SetQueueDepth(l.waiting)
+	l.mu.Unlock()
+	defer func() {
+		l.mu.Lock()
+		l.waiting--
+		l.metrics.SetQueueDepth(l.waiting)
+		l.mu.Unlock()
+	}()
+
+	start := time.Now()
+	timer := time.NewTimer(l.cfg.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		l.metrics.ObserveWait(time.Since(start))
+		return l.acquired(), nil
+	case <-timer.C:
+		l.metrics.IncRejected(rejectTimeout)
+		return nil, ErrQueueTimeout
+	case <-ctx.Done():
+		l.metrics.IncRejected(rejectCanceled)
+		return nil, ctx.Err()
+	}
+}
+
+// acquired records a newly taken slot and returns its idempotent release function.
+func (l *Limiter) acquired() func() {
+	l.mu.Lock()
+	l.inFlight++
+	l.metrics.SetInFlight(l.inFlight)
+	l.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			l.inFlight--
+			l.metrics.SetInFlight(l.inFlight)
+			l.mu.Unlock()
+			<-l.slots
+		})
+	}
+}
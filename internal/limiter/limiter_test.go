@@ -0,0 +1,173 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeMetrics struct {
+	mu       sync.Mutex
+	depth    []int
+	inFlight int
+	waits    []time.Duration
+	rejected map[string]int
+}
+
+func newFakeMetrics() *fakeMetrics {
+	return &fakeMetrics{rejected: map[string]int{}}
+}
+
+func (m *fakeMetrics) SetQueueDepth(depth int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.depth = append(m.depth, depth)
+}
+
+func (m *fakeMetrics) SetInFlight(count int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight = count
+}
+
+func (m *fakeMetrics) ObserveWait(wait time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.waits = append(m.waits, wait)
+}
+
+func (m *fakeMetrics) IncRejected(reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rejected[reason]++
+}
+
+func TestLimiter_QueueFull(t *testing.T) {
+	metrics := newFakeMetrics()
+	l := newLimiter(Config{MaxConcurrent: 1, QueueSize: 1, QueueTimeout: time.Minute}, metrics)
+
+	release, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	queued := make(chan error, 1)
+	go func() {
+		release, err := l.Acquire(context.Background())
+		if err == nil {
+			release()
+		}
+		queued <- err
+	}()
+	waitFor(t, func() bool { return l.queued() == 1 })
+
+	if _, err := l.Acquire(context.Background()); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+
+	release()
+	release() // releasing twice must not free a second slot
+	if err := <-queued; err != nil {
+		t.Fatalf("expected the queued caller to get the slot, got %v", err)
+	}
+
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	if metrics.rejected[rejectQueueFull] != 1 || len(metrics.waits) != 2 || metrics.inFlight != 0 {
+		t.Fatalf("unexpected metrics %+v", metrics)
+	}
+	if depth := metrics.depth[len(metrics.depth)-1]; depth != 0 {
+		t.Fatalf("expected the queue to drain, got depth %d", depth)
+	}
+}
+
+func TestLimiter_QueueTimeout(t *testing.T) {
+	metrics := newFakeMetrics()
+	l := newLimiter(Config{MaxConcurrent: 1, QueueTimeout: 20 * time.Millisecond}, metrics)
+
+	release, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer release()
+
+	if _, err := l.Acquire(context.Background()); !errors.Is(err, ErrQueueTimeout) {
+		t.Fatalf("expected ErrQueueTimeout, got %v", err)
+	}
+	if metrics.rejected[rejectTimeout] != 1 {
+		t.Fatalf("expected a timeout to be counted, got %v", metrics.rejected)
+	}
+}
+
+func TestLimiter_ContextCanceled(t *testing.T) {
+	l := newLimiter(Config{MaxConcurrent: 1}, newFakeMetrics())
+	release, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestLimiter_BoundsConcurrency(t *testing.T) {
+	l := newLimiter(Config{MaxConcurrent: 2, QueueSize: 10}, newFakeMetrics())
+
+	var mu sync.Mutex
+	var running, peak int
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := l.Acquire(context.Background())
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			defer release()
+			mu.Lock()
+			running++
+			peak = max(peak, running)
+			mu.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if peak > 2 {
+		t.Fatalf("expected at most 2 concurrent holders, saw %d", peak)
+	}
+}
+
+func TestConfig_Defaults(t *testing.T) {
+	cfg := Config{MaxConcurrent: 3}.withDefaults()
+	if cfg.QueueSize != 12 || cfg.QueueTimeout != defaultQueueTimeout {
+		t.Fatalf("unexpected defaults %+v", cfg)
+	}
+}
+
+func (l *Limiter) queued() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.waiting
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
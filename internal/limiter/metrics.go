@@ -0,0 +1,68 @@
+package limiter
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"pdf2jpg/internal/metrics"
+)
+
+const (
+	rejectQueueFull = "queue_full"
+	rejectTimeout   = "timeout"
+	rejectCanceled  = "canceled"
+)
+
+// metricsRecorder centralises gauge and counter updates so the limiter stays testable.
+type metricsRecorder interface {
+	SetQueueDepth(depth int)
+	SetInFlight(count int)
+	ObserveWait(wait time.Duration)
+	IncRejected(reason string)
+}
+
+var (
+	queueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Name:      "render_queue_depth",
+		Help:      "Requests waiting for a render slot.",
+	})
+	inFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Name:      "render_in_flight",
+		Help:      "Requests holding a render slot.",
+	})
+	queueWait = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Name:      "render_queue_wait_seconds",
+		Help:      "Time requests waited before getting a render slot.",
+		Buckets:   []float64{0.001, 0.01, 0.1, 0.5, 1, 2.5, 5, 10, 30, 60},
+	})
+	rejectedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Name:      "render_rejected_total",
+		Help:      "Requests that never got a render slot, by reason.",
+	}, []string{"reason"})
+)
+
+// promMetrics records into the process-wide collectors served on /metrics. Every limiter in the
+// process shares them.
+type promMetrics struct{}
+
+func (promMetrics) SetQueueDepth(depth int) {
+	queueDepth.Set(float64(depth))
+}
+
+func (promMetrics) SetInFlight(count int) {
+	inFlight.Set(float64(count))
+}
+
+func (promMetrics) ObserveWait(wait time.Duration) {
+	queueWait.Observe(wait.Seconds())
+}
+
+func (promMetrics) IncRejected(reason string) {
+	rejectedTotal.WithLabelValues(reason).Inc()
+}
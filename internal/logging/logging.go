@@ -0,0 +1,94 @@
+// Package logging builds the service's structured logger and tags every line logged while serving a
+// request with that request's ID.
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// ErrInvalidConfig is returned by New for an unknown level or format.
+var ErrInvalidConfig = errors.New("invalid logging config")
+
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// requestIDKey is the log attribute that carries the request ID.
+const requestIDKey = "request_id"
+
+// Config selects verbosity and output format. Zero fields fall back to info and JSON.
+type Config struct {
+	// Level is debug, info, warn or error.
+	Level string
+	// Format is json, one object per line, or text, slog's key=value format for local development.
+	Format string
+}
+
+// New returns a logger writing to w. Lines logged with a context carrying a request ID, through
+// the *Context methods, include it as request_id.
+func New(w io.Writer, cfg Config) (*slog.Logger, error) {
+	var level slog.Level
+	switch strings.ToLower(strings.TrimSpace(cfg.Level)) {
+	case "", "info":
+		level = slog.LevelInfo
+	case "debug":
+		level = slog.LevelDebug
+	case "warn", "warning":
+		level = slog.LevelWarn
+	case "error":
+		level = slog.LevelError
+	default:
+		return nil, fmt.Errorf("%w: unknown level %q", ErrInvalidConfig, cfg.Level)
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(strings.TrimSpace(cfg.Format)) {
+	case "", FormatJSON:
+		handler = slog.NewJSONHandler(w, opts)
+	case FormatText:
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("%w: unknown format %q", ErrInvalidConfig, cfg.Format)
+	}
+	return slog.New(contextHandler{handler}), nil
+}
+
+// contextHandler adds the request ID from the record's context to every record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String(requestIDKey, id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+type requestIDContextKey struct{}
+
+// WithRequestID returns a copy of ctx carrying id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, id)
+}
+
+// RequestID returns the request ID carried by ctx, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
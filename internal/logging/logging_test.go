@@ -0,0 +1,135 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// decodeLines parses JSON log output, one object per line.
+func decodeLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	for _, raw := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if raw == "" {
+			continue
+		}
+		var line map[string]any
+		if err := json.Unmarshal([]byte(raw), &line); err != nil {
+			t.Fatalf("decode log line %q: %v", raw, err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestNew_LevelAndRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, Config{Level: "WARN"})
+	if err != nil {
+		t.Fatalf("new logger: %v", err)
+	}
+
+	ctx := WithRequestID(context.Background(), "abc-123")
+	logger.InfoContext(ctx, "dropped")
+	logger.WarnContext(ctx, "kept", "page", 2)
+	logger.With("component", "test").Error("no request")
+
+	lines := decodeLines(t, &buf)
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d: %s", len(lines), buf.String())
+	}
+	if lines[0]["msg"] != "kept" || lines[0]["request_id"] != "abc-123" || lines[0]["page"] != float64(2) {
+		t.Fatalf("unexpected first line %v", lines[0])
+	}
+	if _, ok := lines[1]["request_id"]; ok || lines[1]["component"] != "test" {
+		t.Fatalf("unexpected second line %v", lines[1])
+	}
+}
+
+func TestNew_TextFormat(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, Config{Level: "debug", Format: "text"})
+	if err != nil {
+		t.Fatalf("new logger: %v", err)
+	}
+	logger.DebugContext(WithRequestID(context.Background(), "r1"), "hello")
+	if out := buf.String(); !strings.Contains(out, "msg=hello") || !strings.Contains(out, "request_id=r1") {
+		t.Fatalf("unexpected text output %q", out)
+	}
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	for _, cfg := range []Config{{Level: "verbose"}, {Format: "xml"}} {
+		if _, err := New(&bytes.Buffer{}, cfg); !errors.Is(err, ErrInvalidConfig) {
+			t.Fatalf("%+v: expected ErrInvalidConfig, got %v", cfg, err)
+		}
+	}
+}
+
+func TestMiddleware_RequestID(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		propagate bool
+	}{
+		{name: "propagated", header: "3f2a-client.trace_01", propagate: true},
+		{name: "generated when missing"},
+		{name: "generated when malformed", header: "bad id\n"},
+		{name: "generated when too long", header: strings.Repeat("a", maxRequestIDLength+1)},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger, err := New(&buf, Config{})
+			if err != nil {
+				t.Fatalf("new logger: %v", err)
+			}
+			var seen string
+			handler := Middleware(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = RequestID(r.Context())
+				logger.InfoContext(r.Context(), "handling")
+				w.WriteHeader(http.StatusTeapot)
+				_, _ = w.Write([]byte("hello"))
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/convert", nil)
+			if tc.header != "" {
+				req.Header.Set(RequestIDHeader, tc.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			id := rec.Header().Get(RequestIDHeader)
+			if tc.propagate && id != tc.header {
+				t.Fatalf("expected propagated id %q, got %q", tc.header, id)
+			}
+			if !tc.propagate && (len(id) != 32 || id == tc.header) {
+				t.Fatalf("expected a generated id, got %q", id)
+			}
+			if seen != id {
+				t.Fatalf("handler saw id %q, response carried %q", seen, id)
+			}
+
+			lines := decodeLines(t, &buf)
+			if len(lines) != 2 {
+				t.Fatalf("expected 2 lines, got %d: %s", len(lines), buf.String())
+			}
+			for _, line := range lines {
+				if line["request_id"] != id {
+					t.Fatalf("expected request_id %q on %v", id, line)
+				}
+			}
+			access := lines[1]
+			if access["msg"] != "request completed" || access["status"] != float64(http.StatusTeapot) ||
+				access["bytes"] != float64(5) || access["path"] != "/convert" || access["method"] != http.MethodGet {
+				t.Fatalf("unexpected access log %v", access)
+			}
+		})
+	}
+}
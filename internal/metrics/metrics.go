@@ -0,0 +1,101 @@
+// Package metrics exposes the service's Prometheus metrics and instruments HTTP traffic. The other
+// packages register their own collectors on prometheus.DefaultRegisterer under Namespace.
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace prefixes every metric name.
+const Namespace = "pdf2jpg"
+
+// unmatchedRoute labels requests that matched no ServeMux pattern, so that arbitrary paths cannot
+// create label values.
+const unmatchedRoute = "unmatched"
+
+var (
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time to serve HTTP requests, by route pattern and status code.",
+		Buckets:   []float64{0.005, 0.025, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"route", "status"})
+	requestBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "http_request_bytes_total",
+		Help:      "Request body bytes read, by route pattern.",
+	}, []string{"route"})
+	responseBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "http_response_bytes_total",
+		Help:      "Response body bytes written, by route pattern.",
+	}, []string{"route"})
+)
+
+// Handler serves every registered metric in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Middleware records the duration, status and body sizes of every request served by mux. It must
+// wrap the ServeMux directly: the route label is the pattern the mux matched, which it stores on the
+// request it was given.
+func Middleware(mux http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		body := &countingBody{ReadCloser: r.Body}
+		r.Body = body
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		mux.ServeHTTP(rec, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = unmatchedRoute
+		}
+		requestDuration.WithLabelValues(route, strconv.Itoa(rec.status)).Observe(time.Since(start).Seconds())
+		requestBytes.WithLabelValues(route).Add(float64(body.n))
+		responseBytes.WithLabelValues(route).Add(float64(rec.n))
+	})
+}
+
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+// responseRecorder captures the status code and body size.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	n      int64
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(b)
+	r.n += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
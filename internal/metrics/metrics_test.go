@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("scrape: unexpected status %d", rec.Code)
+	}
+	return rec.Body.String()
+}
+
+func TestMiddleware_RecordsByRoute(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /test-upload/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("created"))
+	})
+	handler := Middleware(mux)
+
+	for _, id := range []string{"a", "b"} {
+		req := httptest.NewRequest(http.MethodPost, "/test-upload/"+id, strings.NewReader("12345"))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	// Unknown paths share one label value however many there are.
+	for _, path := range []string{"/no-such-page", "/another-missing-page"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	out := scrape(t)
+	for _, want := range []string{
+		`pdf2jpg_http_request_duration_seconds_count{route="POST /test-upload/{id}",status="201"} 2`,
+		`pdf2jpg_http_request_bytes_total{route="POST /test-upload/{id}"} 10`,
+		`pdf2jpg_http_response_bytes_total{route="POST /test-upload/{id}"} 14`,
+		`pdf2jpg_http_request_duration_seconds_count{route="unmatched",status="404"} 2`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in scrape output:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "no-such-page") {
+		t.Fatalf("unmatched path leaked into labels")
+	}
+}
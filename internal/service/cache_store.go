@@ -0,0 +1,275 @@
+package service
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrCacheMiss is returned by CacheStore.Get when the key is not cached.
+var ErrCacheMiss = errors.New("cache miss")
+
+// CacheStore holds encoded conversion results keyed by opaque hex strings. Implementations must be
+// safe for concurrent use and may drop entries at any time.
+type CacheStore interface {
+	// Get returns the cached bytes for key, or ErrCacheMiss.
+	Get(key string) ([]byte, error)
+	// Put stores data under key. Entries that can never fit the store are silently skipped.
+	Put(key string, data []byte) error
+}
+
+// lruEntry is one cached value. data is only kept by in-memory stores.
+type lruEntry struct {
+	key  string
+	size int64
+	data []byte
+}
+
+// lruIndex tracks entry sizes in recency order and decides what to evict to stay within maxBytes.
+// It is not safe for concurrent use; stores guard it with their own mutex.
+type lruIndex struct {
+	maxBytes int64
+	size     int64
+	order    *list.List
+	items    map[string]*list.Element
+}
+
+func newLRUIndex(maxBytes int64) *lruIndex {
+	return &lruIndex{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// get returns the entry for key and marks it as most recently used.
+func (l *lruIndex) get(key string) (*lruEntry, bool) {
+	elem, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	l.order.MoveToFront(elem)
+	return elem.Value.(*lruEntry), true
+}
+
+// add inserts or replaces entry and returns the entries evicted to make room for it.
+func (l *lruIndex) add(entry *lruEntry) []*lruEntry {
+	if existing, ok := l.items[entry.key]; ok {
+		l.size -= existing.Value.(*lruEntry).size
+		l.order.Remove(existing)
+	}
+	l.items[entry.key] = l.order.PushFront(entry)
+	l.size += entry.size
+
+	var evicted []*lruEntry
+	for l.size > l.maxBytes {
+		oldest := l.order.Back()
+		victim := oldest.Value.(*lruEntry)
+		l.order.Remove(oldest)
+		delete(l.items, victim.key)
+		l.size -= victim.size
+		evicted = append(evicted, victim)
+	}
+	return evicted
+}
+
+// remove drops key from the index if present.
+func (l *lruIndex) remove(key string) {
+	if elem, ok := l.items[key]; ok {
+		l.size -= elem.Value.(*lruEntry).size
+		l.order.Remove(elem)
+		delete(l.items, key)
+	}
+}
+
+// MemoryCache is an in-process LRU CacheStore bounded by the total size of its values.
+type MemoryCache struct {
+	mu    sync.Mutex
+	index *lruIndex
+}
+
+// NewMemoryCache returns an empty MemoryCache that holds at most maxBytes of values.
+func NewMemoryCache(maxBytes int64) *MemoryCache {
+	return &MemoryCache{index: newLRUIndex(maxBytes)}
+}
+
+// Get returns the cached bytes for key. Callers must not modify the returned slice.
+func (c *MemoryCache) Get(key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.index.get(key)
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+	return entry.data, nil
+}
+
+// Put stores a copy of data, evicting the least recently used entries to stay within budget.
+func (c *MemoryCache) Put(key string, data []byte) error {
+	size := int64(len(data))
+	if size > c.index.maxBytes {
+		return nil
+	}
+	entry := &lruEntry{key: key, size: size, data: append([]byte(nil), data...)}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.index.add(entry)
+	return nil
+}
+
+// DiskCache is a CacheStore that keeps one file per entry in a local directory. Recency is tracked in
+// memory and seeded from file modification times, so a restarted server keeps its warm entries.
+type DiskCache struct {
+	dir   string
+	mu    sync.Mutex
+	index *lruIndex
+}
+
+// diskCacheTempPrefix marks partially written entries so they are never indexed or served.
+const diskCacheTempPrefix = ".tmp-"
+
+// NewDiskCache opens or creates a cache in dir holding at most maxBytes of files. Existing entries are
+// indexed oldest first, and any that no longer fit the budget are removed. Files whose names are not
+// cache keys are left alone, so pointing dir at a shared directory never deletes anything else.
+func NewDiskCache(dir string, maxBytes int64) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create cache dir: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read cache dir: %w", err)
+	}
+
+	type existing struct {
+		key     string
+		size    int64
+		modTime time.Time
+	}
+	var files []existing
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			continue
+		}
+		if strings.HasPrefix(name, diskCacheTempPrefix) {
+			_ = os.Remove(filepath.Join(dir, name))
+			continue
+		}
+		if !isCacheKey(name) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, existing{key: name, size: info.Size(), modTime: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	c := &DiskCache{dir: dir, index: newLRUIndex(maxBytes)}
+	for _, f := range files {
+		c.removeFiles(c.index.add(&lruEntry{key: f.key, size: f.size}))
+	}
+	return c, nil
+}
+
+// Get reads the entry for key from disk and refreshes its recency.
+func (c *DiskCache) Get(key string) ([]byte, error) {
+	path, err := c.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	_, ok := c.index.get(key)
+	c.mu.Unlock()
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		// Evicted by a concurrent Put, or removed behind our back.
+		c.mu.Lock()
+		c.index.remove(key)
+		c.mu.Unlock()
+		return nil, ErrCacheMiss
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read cache entry: %w", err)
+	}
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return data, nil
+}
+
+// Put writes data to a temporary file and renames it into place, so readers never see partial entries.
+func (c *DiskCache) Put(key string, data []byte) error {
+	path, err := c.path(key)
+	if err != nil {
+		return err
+	}
+	size := int64(len(data))
+	if size > c.index.maxBytes {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(c.dir, diskCacheTempPrefix+"*")
+	if err != nil {
+		return fmt.Errorf("create cache entry: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write cache entry: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("commit cache entry: %w", err)
+	}
+	c.removeFiles(c.index.add(&lruEntry{key: key, size: size}))
+	return nil
+}
+
+func (c *DiskCache) removeFiles(evicted []*lruEntry) {
+	for _, entry := range evicted {
+		_ = os.Remove(filepath.Join(c.dir, entry.key))
+	}
+}
+
+// path maps key to its file. Only cache keys are accepted, so no entry can escape the cache directory
+// or take the name of a file the cache does not own.
+func (c *DiskCache) path(key string) (string, error) {
+	if !isCacheKey(key) {
+		return "", fmt.Errorf("invalid cache key %q", key)
+	}
+	return filepath.Join(c.dir, key), nil
+}
+
+// isCacheKey reports whether name has the form of the keys cacheKey produces: 64 lowercase hex digits.
+func isCacheKey(name string) bool {
+	if len(name) != 2*sha256.Size {
+		return false
+	}
+	for _, r := range name {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewMemoryCache(10)
+	mustPut(t, c, "a", []byte("aaaa"))
+	mustPut(t, c, "b", []byte("bbbb"))
+	if _, err := c.Get("a"); err != nil {
+		t.Fatalf("get a: %v", err)
+	}
+	mustPut(t, c, "c", []byte("cccc"))
+
+	if _, err := c.Get("b"); !errors.Is(err, ErrCacheMiss) {
+		t.Fatalf("expected b to be evicted, got %v", err)
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, err := c.Get(key); err != nil {
+			t.Fatalf("expected %s to stay cached, got %v", key, err)
+		}
+	}
+
+	mustPut(t, c, "huge", make([]byte, 11))
+	if _, err := c.Get("huge"); !errors.Is(err, ErrCacheMiss) {
+		t.Fatalf("expected an entry over budget to be skipped, got %v", err)
+	}
+}
+
+func TestMemoryCache_CopiesValues(t *testing.T) {
+	c := NewMemoryCache(10)
+	data := []byte("page")
+	mustPut(t, c, "k", data)
+	data[0] = 'X'
+	if got, _ := c.Get("k"); string(got) != "page" {
+		t.Fatalf("expected the cache to keep its own copy, got %q", got)
+	}
+}
+
+func TestDiskCache_EvictsAndReloads(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewDiskCache(dir, 10)
+	if err != nil {
+		t.Fatalf("new disk cache: %v", err)
+	}
+	a, b, k := cacheKey("a"), cacheKey("b"), cacheKey("c")
+	mustPut(t, c, a, []byte("aaaa"))
+	mustPut(t, c, b, []byte("bbbb"))
+	mustPut(t, c, k, []byte("cccc"))
+
+	if _, err := os.Stat(filepath.Join(dir, a)); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected the evicted file to be removed, got %v", err)
+	}
+	if got, err := c.Get(k); err != nil || !bytes.Equal(got, []byte("cccc")) {
+		t.Fatalf("unexpected entry %q (%v)", got, err)
+	}
+
+	// A leftover partial write is discarded rather than indexed.
+	if err := os.WriteFile(filepath.Join(dir, diskCacheTempPrefix+"x"), []byte("partial"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := NewDiskCache(dir, 10)
+	if err != nil {
+		t.Fatalf("reopen disk cache: %v", err)
+	}
+	if got, err := reopened.Get(b); err != nil || !bytes.Equal(got, []byte("bbbb")) {
+		t.Fatalf("expected b to survive a restart, got %q (%v)", got, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, diskCacheTempPrefix+"x")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected the partial file to be removed, got %v", err)
+	}
+}
+
+func TestDiskCache_LeavesForeignFiles(t *testing.T) {
+	dir := t.TempDir()
+	// Older than any entry and over budget on its own, so it would be the first to go if indexed.
+	foreign := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(foreign, []byte("not a cache entry"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(foreign, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewDiskCache(dir, 10)
+	if err != nil {
+		t.Fatalf("new disk cache: %v", err)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		mustPut(t, c, cacheKey(key), []byte("xxxx"))
+	}
+	if _, err := NewDiskCache(dir, 4); err != nil {
+		t.Fatalf("reopen disk cache: %v", err)
+	}
+	if data, err := os.ReadFile(foreign); err != nil || string(data) != "not a cache entry" {
+		t.Fatalf("expected the foreign file to survive eviction, got %q (%v)", data, err)
+	}
+}
+
+func TestDiskCache_RejectsUnsafeKeys(t *testing.T) {
+	c, err := NewDiskCache(t.TempDir(), 10)
+	if err != nil {
+		t.Fatalf("new disk cache: %v", err)
+	}
+	for _, key := range []string{"", "../escape", ".hidden", `a\b`, "notes.txt", strings.ToUpper(cacheKey("a"))} {
+		if err := c.Put(key, []byte("x")); err == nil {
+			t.Fatalf("%q: expected an error", key)
+		}
+	}
+}
+
+func mustPut(t *testing.T, c CacheStore, key string, data []byte) {
+	t.Helper()
+	if err := c.Put(key, data); err != nil {
+		t.Fatalf("put %s: %v", key, err)
+	}
+}
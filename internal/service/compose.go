@@ -0,0 +1,375 @@
+package service
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultMaxComposeImages = 100
+	// maxPageSizePoints is the largest page side most PDF readers accept (200 inches).
+	maxPageSizePoints = 14400
+)
+
+// ErrUnsupportedImage is returned when a compose input is not a readable JPEG or PNG.
+var ErrUnsupportedImage = errors.New("unsupported image")
+
+// PaperSize is a page size in points. The zero value sizes each page to its image.
+type PaperSize struct {
+	W float64
+	H float64
+}
+
+// namedPaperSizes are portrait ISO and US paper sizes in points.
+var namedPaperSizes = map[string]PaperSize{
+	"a3":     {W: 841.89, H: 1190.55},
+	"a4":     {W: 595.28, H: 841.89},
+	"a5":     {W: 419.53, H: 595.28},
+	"letter": {W: 612, H: 792},
+	"legal":  {W: 612, H: 1008},
+}
+
+// ParsePaperSize parses "auto", a paper name (a3, a4, a5, letter, legal) or "WxH" in points. An empty
+// string selects auto.
+func ParsePaperSize(raw string) (PaperSize, error) {
+	name := strings.ToLower(strings.TrimSpace(raw))
+	if name == "" || name == "auto" {
+		return PaperSize{}, nil
+	}
+	if size, ok := namedPaperSizes[name]; ok {
+		return size, nil
+	}
+	wRaw, hRaw, ok := strings.Cut(name, "x")
+	if !ok {
+		return PaperSize{}, fmt.Errorf("%w: unknown page size %q", ErrInvalidRenderOptions, raw)
+	}
+	w, errW := strconv.ParseFloat(strings.TrimSpace(wRaw), 64)
+	h, errH := strconv.ParseFloat(strings.TrimSpace(hRaw), 64)
+	size := PaperSize{W: w, H: h}
+	if errW != nil || errH != nil || !size.valid() {
+		return PaperSize{}, fmt.Errorf("%w: page size %q", ErrInvalidRenderOptions, raw)
+	}
+	return size, nil
+}
+
+func (p PaperSize) valid() bool {
+	return p.W > 0 && p.H > 0 && p.W <= maxPageSizePoints && p.H <= maxPageSizePoints
+}
+
+// Orientation turns fixed page sizes to portrait or landscape.
+type Orientation string
+
+const (
+	// OrientationAuto follows each image: landscape pages for wide images, portrait otherwise.
+	OrientationAuto      Orientation = "auto"
+	OrientationPortrait  Orientation = "portrait"
+	OrientationLandscape Orientation = "landscape"
+)
+
+// ParseOrientation parses an orientation name. An empty string selects OrientationAuto.
+func ParseOrientation(raw string) (Orientation, error) {
+	switch o := Orientation(strings.ToLower(strings.TrimSpace(raw))); o {
+	case "", OrientationAuto:
+		return OrientationAuto, nil
+	case OrientationPortrait, OrientationLandscape:
+		return o, nil
+	default:
+		return "", fmt.Errorf("%w: unknown orientation %q", ErrInvalidRenderOptions, raw)
+	}
+}
+
+// ComposeOptions controls how ComposeImages lays images out on pages.
+type ComposeOptions struct {
+	Paper       PaperSize
+	Orientation Orientation
+	// Fit places each image inside the page less its margins; it is ignored for auto-sized pages.
+	Fit FitMode
+	// Margin is the blank border around each image, in points.
+	Margin float64
+	// DPI converts image pixels to points for auto-sized pages; zero uses Config.DefaultDPI.
+	DPI float64
+}
+
+// composeImage is an input that passed the format and size checks.
+type composeImage struct {
+	path   string
+	format string
+	width  int
+	height int
+	gray   bool
+}
+
+// ComposeImages writes a PDF into w with one page per image, in order. Inputs must be JPEG or PNG.
+// JPEGs are embedded as they are; other images are decoded, flattened onto white and compressed.
+// Image headers are checked up front, and the PDF is spooled to a temporary file and only copied into
+// w once every image has been embedded, so a bad input never produces partial output.
+func (s *PDFService) ComposeImages(ctx context.Context, imagePaths []string, opts ComposeOptions, w io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(imagePaths) == 0 {
+		return fmt.Errorf("%w: no images", ErrInvalidRenderOptions)
+	}
+	if len(imagePaths) > s.cfg.MaxComposeImages {
+		return fmt.Errorf("%w: %d images, limit %d", ErrRenderLimitExceeded, len(imagePaths), s.cfg.MaxComposeImages)
+	}
+	if err := s.validateComposeOptions(opts); err != nil {
+		return err
+	}
+
+	images := make([]composeImage, len(imagePaths))
+	for i, path := range imagePaths {
+		img, err := s.probeImage(path)
+		if err != nil {
+			return fmt.Errorf("image %d: %w", i+1, err)
+		}
+		// Fixed paper sizes were checked with the options; auto-sized pages depend on each image.
+		if opts.Paper == (PaperSize{}) {
+			if pageW, pageH, _, _ := s.composeLayout(img, opts); !(PaperSize{W: pageW, H: pageH}).valid() {
+				return fmt.Errorf("image %d: %w: page size %gx%g", i+1, ErrInvalidRenderOptions, pageW, pageH)
+			}
+		}
+		images[i] = img
+	}
+
+	// PNG bodies are only decoded while their page is written, so a corrupt one fails part-way through.
+	spool, err := os.CreateTemp("", "pdf2jpg-compose-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	if err := s.writeComposedPDF(ctx, spool, images, opts); err != nil {
+		return err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err = io.Copy(w, spool)
+	return err
+}
+
+// writeComposedPDF writes the PDF for checked images into w.
+func (s *PDFService) writeComposedPDF(ctx context.Context, w io.Writer, images []composeImage, opts ComposeOptions) error {
+	// Objects 1 and 2 are the catalog and page tree; each page then takes three: page, contents, image.
+	pw := newPDFWriter(w)
+	kids := make([]string, len(images))
+	for i, img := range images {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		pageNum := 3 + 3*i
+		kids[i] = fmt.Sprintf("%d 0 R", pageNum)
+		if err := s.writeComposedPage(pw, pageNum, img, opts); err != nil {
+			return fmt.Errorf("image %d: %w", i+1, err)
+		}
+	}
+	pw.object(2, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+	pw.object(1, "<< /Type /Catalog /Pages 2 0 R >>")
+	return pw.finish(1, 3+3*len(images))
+}
+
+func (s *PDFService) validateComposeOptions(opts ComposeOptions) error {
+	if _, err := ParseFitMode(string(opts.Fit)); err != nil {
+		return err
+	}
+	if _, err := ParseOrientation(string(opts.Orientation)); err != nil {
+		return err
+	}
+	if opts.Margin < 0 || math.IsNaN(opts.Margin) || math.IsInf(opts.Margin, 0) || opts.DPI < 0 || math.IsNaN(opts.DPI) || math.IsInf(opts.DPI, 0) {
+		return fmt.Errorf("%w: margin or dpi out of range", ErrInvalidRenderOptions)
+	}
+	if opts.Paper == (PaperSize{}) {
+		return nil
+	}
+	if !opts.Paper.valid() {
+		return fmt.Errorf("%w: page size %gx%g", ErrInvalidRenderOptions, opts.Paper.W, opts.Paper.H)
+	}
+	if 2*opts.Margin >= min(opts.Paper.W, opts.Paper.H) {
+		return fmt.Errorf("%w: margin %g leaves no room on the page", ErrInvalidRenderOptions, opts.Margin)
+	}
+	return nil
+}
+
+// probeImage reads just the image header and applies the format and pixel checks.
+func (s *PDFService) probeImage(path string) (composeImage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return composeImage{}, err
+	}
+	defer f.Close()
+
+	cfg, format, err := image.DecodeConfig(f)
+	if err != nil || (format != "jpeg" && format != "png") {
+		return composeImage{}, ErrUnsupportedImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return composeImage{}, ErrUnsupportedImage
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > int64(s.cfg.MaxPixels) {
+		return composeImage{}, fmt.Errorf("%w: %dx%d pixels", ErrRenderLimitExceeded, cfg.Width, cfg.Height)
+	}
+	img := composeImage{path: path, format: format, width: cfg.Width, height: cfg.Height}
+	switch cfg.ColorModel {
+	case color.GrayModel:
+		img.gray = true
+	case color.YCbCrModel:
+	default:
+		// CMYK and other JPEG variants are re-encoded rather than passed through.
+		if format == "jpeg" {
+			img.format = "jpeg-decoded"
+		}
+	}
+	return img, nil
+}
+
+// writeComposedPage writes the page object pageNum, its content stream and its image.
+func (s *PDFService) writeComposedPage(pw *pdfWriter, pageNum int, img composeImage, opts ComposeOptions) error {
+	contentNum, imageNum := pageNum+1, pageNum+2
+	if err := s.writeImageObject(pw, imageNum, img); err != nil {
+		return err
+	}
+
+	pageW, pageH, placement, clip := s.composeLayout(img, opts)
+	var content bytes.Buffer
+	content.WriteString("q\n")
+	if clip {
+		fmt.Fprintf(&content, "%s %s %s %s re W n\n", pdfNum(opts.Margin), pdfNum(opts.Margin), pdfNum(pageW-2*opts.Margin), pdfNum(pageH-2*opts.Margin))
+	}
+	fmt.Fprintf(&content, "%s 0 0 %s %s %s cm\n/Im0 Do\nQ\n", pdfNum(placement.W), pdfNum(placement.H), pdfNum(placement.X), pdfNum(placement.Y))
+	pw.stream(contentNum, "", bytes.NewReader(content.Bytes()), int64(content.Len()))
+
+	pw.object(pageNum, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /XObject << /Im0 %d 0 R >> >> /Contents %d 0 R >>",
+		pdfNum(pageW), pdfNum(pageH), imageNum, contentNum))
+	return pw.err
+}
+
+// composeLayout returns the page size and where the image goes on it, in points from the bottom-left
+// corner, and whether the image must be clipped to the margins.
+func (s *PDFService) composeLayout(img composeImage, opts ComposeOptions) (float64, float64, Rect, bool) {
+	margin := opts.Margin
+	if opts.Paper == (PaperSize{}) {
+		dpi := opts.DPI
+		if dpi == 0 {
+			dpi = s.cfg.DefaultDPI
+		}
+		w := float64(img.width) * pointsPerInch / dpi
+		h := float64(img.height) * pointsPerInch / dpi
+		return w + 2*margin, h + 2*margin, Rect{X: margin, Y: margin, W: w, H: h}, false
+	}
+
+	pageW, pageH := opts.Paper.W, opts.Paper.H
+	orientation, _ := ParseOrientation(string(opts.Orientation))
+	landscape := orientation == OrientationLandscape || (orientation == OrientationAuto && img.width > img.height)
+	if landscape != (pageW > pageH) {
+		pageW, pageH = pageH, pageW
+	}
+
+	boxW, boxH := pageW-2*margin, pageH-2*margin
+	fit, _ := ParseFitMode(string(opts.Fit))
+	if fit == FitExact {
+		return pageW, pageH, Rect{X: margin, Y: margin, W: boxW, H: boxH}, false
+	}
+	scaleX, scaleY := boxW/float64(img.width), boxH/float64(img.height)
+	scale := min(scaleX, scaleY)
+	if fit == FitFill {
+		scale = max(scaleX, scaleY)
+	}
+	w, h := float64(img.width)*scale, float64(img.height)*scale
+	return pageW, pageH, Rect{X: margin + (boxW-w)/2, Y: margin + (boxH-h)/2, W: w, H: h}, fit == FitFill
+}
+
+// writeImageObject embeds img as an image XObject.
+func (s *PDFService) writeImageObject(pw *pdfWriter, num int, img composeImage) error {
+	colorSpace := "/DeviceRGB"
+	if img.gray {
+		colorSpace = "/DeviceGray"
+	}
+	dict := fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent 8", img.width, img.height, colorSpace)
+
+	f, err := os.Open(img.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if img.format == "jpeg" {
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		pw.stream(num, dict+" /Filter /DCTDecode", f, info.Size())
+		return pw.err
+	}
+
+	decoded, _, err := image.Decode(f)
+	if err != nil {
+		return ErrUnsupportedImage
+	}
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	if img.gray {
+		if gray, ok := decoded.(*image.Gray); ok && gray.Stride == img.width {
+			_, err = zw.Write(gray.Pix)
+		} else {
+			err = writeGraySamples(zw, decoded)
+		}
+	} else {
+		err = writeRGBSamples(zw, decoded)
+	}
+	if err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	pw.stream(num, dict+" /Filter /FlateDecode", &compressed, int64(compressed.Len()))
+	return pw.err
+}
+
+// writeRGBSamples writes img as 8-bit RGB rows, flattening any transparency onto white.
+func writeRGBSamples(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	flat := image.NewRGBA(bounds)
+	draw.Draw(flat, bounds, image.White, image.Point{}, draw.Src)
+	draw.Draw(flat, bounds, img, bounds.Min, draw.Over)
+
+	row := make([]byte, 3*bounds.Dx())
+	for y := 0; y < bounds.Dy(); y++ {
+		pix := flat.Pix[y*flat.Stride:]
+		for x := 0; x < bounds.Dx(); x++ {
+			copy(row[3*x:3*x+3], pix[4*x:4*x+3])
+		}
+		if _, err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeGraySamples writes img as 8-bit luminance rows.
+func writeGraySamples(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	row := make([]byte, bounds.Dx())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			row[x-bounds.Min.X] = color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y
+		}
+		if _, err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
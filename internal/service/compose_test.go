@@ -0,0 +1,242 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestImage encodes img into dir/name with the encoder matching the extension.
+func writeTestImage(t *testing.T, dir, name string, img image.Image) string {
+	t.Helper()
+	var buf bytes.Buffer
+	var err error
+	switch filepath.Ext(name) {
+	case ".jpg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95})
+	case ".png":
+		err = png.Encode(&buf, img)
+	case ".gif":
+		err = gif.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatalf("encode %s: %v", name, err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+func filledImage(w, h int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// composeAndOpen composes paths into a PDF file and opens it with MuPDF.
+func composeAndOpen(t *testing.T, svc *PDFService, paths []string, opts ComposeOptions) Document {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := svc.ComposeImages(context.Background(), paths, opts, &buf); err != nil {
+		t.Fatalf("compose: %v", err)
+	}
+	out := filepath.Join(t.TempDir(), "out.pdf")
+	if err := os.WriteFile(out, buf.Bytes(), 0o600); err != nil {
+		t.Fatalf("write pdf: %v", err)
+	}
+	doc, err := openFitzDocument(out)
+	if err != nil {
+		t.Fatalf("open composed pdf: %v", err)
+	}
+	t.Cleanup(func() { doc.Close() })
+	return doc
+}
+
+func assertColor(t *testing.T, img image.Image, x, y int, want color.RGBA) {
+	t.Helper()
+	r, g, b, _ := img.At(x, y).RGBA()
+	near := func(got uint32, want uint8) bool { return int(got>>8)-int(want) < 24 && int(want)-int(got>>8) < 24 }
+	if !near(r, want.R) || !near(g, want.G) || !near(b, want.B) {
+		t.Fatalf("pixel (%d,%d): expected %v, got %v", x, y, want, img.At(x, y))
+	}
+}
+
+func TestComposeImages_AutoSize(t *testing.T) {
+	dir := t.TempDir()
+	red := color.RGBA{R: 220, A: 255}
+
+	gray := image.NewGray(image.Rect(0, 0, 40, 80))
+	for i := range gray.Pix {
+		gray.Pix[i] = 60
+	}
+	// The left half of the PNG is transparent and must come out white.
+	alpha := filledImage(60, 30, red)
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 30; x++ {
+			alpha.Set(x, y, color.RGBA{})
+		}
+	}
+	paths := []string{
+		writeTestImage(t, dir, "photo.jpg", filledImage(120, 60, red)),
+		writeTestImage(t, dir, "scan.png", gray),
+		writeTestImage(t, dir, "logo.png", alpha),
+	}
+
+	svc := NewPDFService(Config{})
+	doc := composeAndOpen(t, svc, paths, ComposeOptions{DPI: 72, Margin: 10})
+	if doc.NumPage() != 3 {
+		t.Fatalf("expected 3 pages, got %d", doc.NumPage())
+	}
+	for i, want := range []image.Point{{140, 80}, {60, 100}, {80, 50}} {
+		bounds, err := doc.Bound(i)
+		if err != nil {
+			t.Fatalf("bound page %d: %v", i+1, err)
+		}
+		if bounds.Size() != want {
+			t.Fatalf("page %d: expected %v points, got %v", i+1, want, bounds.Size())
+		}
+	}
+
+	first, err := doc.ImageDPI(0, 72)
+	if err != nil {
+		t.Fatalf("render page 1: %v", err)
+	}
+	assertColor(t, first, 2, 2, color.RGBA{R: 255, G: 255, B: 255})
+	assertColor(t, first, 70, 40, red)
+
+	second, err := doc.ImageDPI(1, 72)
+	if err != nil {
+		t.Fatalf("render page 2: %v", err)
+	}
+	assertColor(t, second, 30, 50, color.RGBA{R: 60, G: 60, B: 60})
+
+	third, err := doc.ImageDPI(2, 72)
+	if err != nil {
+		t.Fatalf("render page 3: %v", err)
+	}
+	assertColor(t, third, 20, 25, color.RGBA{R: 255, G: 255, B: 255})
+	assertColor(t, third, 60, 25, red)
+}
+
+func TestComposeImages_PaperSize(t *testing.T) {
+	dir := t.TempDir()
+	wide := writeTestImage(t, dir, "wide.png", filledImage(300, 100, color.RGBA{B: 200, A: 255}))
+	a4, _ := ParsePaperSize("a4")
+
+	tests := []struct {
+		name string
+		opts ComposeOptions
+		want image.Point
+	}{
+		{name: "auto orientation turns the page", opts: ComposeOptions{Paper: a4}, want: image.Pt(842, 595)},
+		{name: "portrait", opts: ComposeOptions{Paper: a4, Orientation: OrientationPortrait, Fit: FitFill}, want: image.Pt(595, 842)},
+		{name: "custom", opts: ComposeOptions{Paper: PaperSize{W: 200, H: 100}, Fit: FitExact, Margin: 5}, want: image.Pt(200, 100)},
+	}
+	svc := NewPDFService(Config{})
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			doc := composeAndOpen(t, svc, []string{wide}, tc.opts)
+			bounds, err := doc.Bound(0)
+			if err != nil {
+				t.Fatalf("bound: %v", err)
+			}
+			if got := bounds.Size(); got.X-tc.want.X > 1 || tc.want.X-got.X > 1 || got.Y-tc.want.Y > 1 || tc.want.Y-got.Y > 1 {
+				t.Fatalf("expected about %v points, got %v", tc.want, got)
+			}
+		})
+	}
+
+	// Contained on a portrait A4 page, the wide image leaves white bands above and below it.
+	doc := composeAndOpen(t, svc, []string{wide}, ComposeOptions{Paper: a4, Orientation: OrientationPortrait})
+	img, err := doc.ImageDPI(0, 72)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	assertColor(t, img, 297, 100, color.RGBA{R: 255, G: 255, B: 255})
+	assertColor(t, img, 297, 421, color.RGBA{B: 200})
+}
+
+func TestComposeImages_Errors(t *testing.T) {
+	dir := t.TempDir()
+	jpg := writeTestImage(t, dir, "ok.jpg", filledImage(10, 10, color.White))
+	gifPath := writeTestImage(t, dir, "anim.gif", image.NewPaletted(image.Rect(0, 0, 10, 10), color.Palette{color.Black, color.White}))
+	big := writeTestImage(t, dir, "big.png", image.NewGray(image.Rect(0, 0, 100, 100)))
+	// The header still decodes, so the truncated body only fails once the page is written.
+	truncated := writeTestImage(t, dir, "truncated.png", filledImage(50, 50, color.RGBA{R: 200, A: 255}))
+	if info, err := os.Stat(truncated); err != nil || os.Truncate(truncated, info.Size()/2) != nil {
+		t.Fatalf("truncate png: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		cfg   Config
+		paths []string
+		opts  ComposeOptions
+		want  error
+	}{
+		{name: "no images", want: ErrInvalidRenderOptions},
+		{name: "unsupported format", paths: []string{jpg, gifPath}, want: ErrUnsupportedImage},
+		{name: "too many images", cfg: Config{MaxComposeImages: 1}, paths: []string{jpg, jpg}, want: ErrRenderLimitExceeded},
+		{name: "too many pixels", cfg: Config{MaxPixels: 5000}, paths: []string{jpg, big}, want: ErrRenderLimitExceeded},
+		{name: "margin fills the page", paths: []string{jpg}, opts: ComposeOptions{Paper: PaperSize{W: 100, H: 100}, Margin: 50}, want: ErrInvalidRenderOptions},
+		{name: "bad fit", paths: []string{jpg}, opts: ComposeOptions{Fit: "stretch"}, want: ErrInvalidRenderOptions},
+		{name: "auto page too large at low dpi", paths: []string{jpg}, opts: ComposeOptions{DPI: 0.01}, want: ErrInvalidRenderOptions},
+		{name: "truncated png after a good page", paths: []string{jpg, truncated}, want: ErrUnsupportedImage},
+		{name: "auto page margin too large", paths: []string{jpg}, opts: ComposeOptions{Margin: maxPageSizePoints}, want: ErrInvalidRenderOptions},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := NewPDFService(tc.cfg).ComposeImages(context.Background(), tc.paths, tc.opts, &buf)
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, err)
+			}
+			if buf.Len() != 0 {
+				t.Fatalf("expected no output on error, got %d bytes", buf.Len())
+			}
+		})
+	}
+}
+
+func TestParsePaperSize(t *testing.T) {
+	if size, err := ParsePaperSize(" Letter "); err != nil || size != (PaperSize{W: 612, H: 792}) {
+		t.Fatalf("unexpected letter size %v (%v)", size, err)
+	}
+	if size, err := ParsePaperSize("300x200.5"); err != nil || size != (PaperSize{W: 300, H: 200.5}) {
+		t.Fatalf("unexpected custom size %v (%v)", size, err)
+	}
+	if size, err := ParsePaperSize("auto"); err != nil || size != (PaperSize{}) {
+		t.Fatalf("unexpected auto size %v (%v)", size, err)
+	}
+	for _, raw := range []string{"b5", "0x100", "100x", "20000x100", "axb"} {
+		if _, err := ParsePaperSize(raw); !errors.Is(err, ErrInvalidRenderOptions) {
+			t.Fatalf("%q: expected ErrInvalidRenderOptions, got %v", raw, err)
+		}
+	}
+}
+
+func TestParseOrientation(t *testing.T) {
+	if o, err := ParseOrientation(""); err != nil || o != OrientationAuto {
+		t.Fatalf("unexpected default orientation %q (%v)", o, err)
+	}
+	if o, err := ParseOrientation("Landscape"); err != nil || o != OrientationLandscape {
+		t.Fatalf("unexpected orientation %q (%v)", o, err)
+	}
+	if _, err := ParseOrientation("sideways"); !errors.Is(err, ErrInvalidRenderOptions) {
+		t.Fatalf("expected ErrInvalidRenderOptions, got %v", err)
+	}
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultSheetColumns    = 4
+	defaultSheetThumbWidth = 200
+	defaultSheetPadding    = 8
+	defaultMaxSheetPages   = 100
+	maxSheetColumns        = 50
+)
+
+// ContactSheetOptions controls the grid layout of RenderContactSheet. Zero Columns and ThumbWidth use defaults.
+type ContactSheetOptions struct {
+	Columns    int
+	ThumbWidth int
+	// Padding is the gap around every thumbnail in pixels; zero is allowed, a negative value selects the default.
+	Padding int
+	// Background fills the gaps between thumbnails; the zero value is treated as white.
+	Background color.RGBA
+}
+
+func (o ContactSheetOptions) withDefaults() ContactSheetOptions {
+	if o.Columns <= 0 {
+		o.Columns = defaultSheetColumns
+	}
+	if o.ThumbWidth <= 0 {
+		o.ThumbWidth = defaultSheetThumbWidth
+	}
+	if o.Padding < 0 {
+		o.Padding = defaultSheetPadding
+	}
+	if o.Background == (color.RGBA{}) {
+		o.Background = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	}
+	return o
+}
+
+// ParseHexColor parses "#RRGGBB", "RRGGBB" or the short "#RGB" form into an opaque colour.
+func ParseHexColor(raw string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(raw), "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("%w: invalid colour %q", ErrInvalidRenderOptions, raw)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("%w: invalid colour %q", ErrInvalidRenderOptions, raw)
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}, nil
+}
+
+// sheetCell is one thumbnail slot in the grid.
+type sheetCell struct {
+	index  int
+	layout pageLayout
+}
+
+// RenderContactSheet tiles the pages chosen by sel into a single grid image, each thumbnail labelled
+// with its page number, and encodes it with opts. Only the encoding, crop, trim and rotate fields of
+// opts are used.
+// At most Config.MaxSheetPages pages are included; later pages in the selection are dropped, and
+// repeated pages appear once.
+func (s *PDFService) RenderContactSheet(ctx context.Context, pdfPath string, sel PageSelector, sheet ContactSheetOptions, opts ConvertOptions) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sheet = sheet.withDefaults()
+	if sheet.Columns > maxSheetColumns {
+		return nil, fmt.Errorf("%w: %d columns above maximum %d", ErrRenderLimitExceeded, sheet.Columns, maxSheetColumns)
+	}
+	if sheet.ThumbWidth > s.cfg.MaxDimension || sheet.Padding > s.cfg.MaxDimension {
+		return nil, fmt.Errorf("%w: thumbnail width or padding above maximum dimension %d", ErrRenderLimitExceeded, s.cfg.MaxDimension)
+	}
+	encodeOpts := ConvertOptions{Format: opts.Format, Quality: opts.Quality, Chroma: opts.Chroma, Grayscale: opts.Grayscale, MaxBytes: opts.MaxBytes}
+	if err := s.cfg.validateOptions(encodeOpts); err != nil {
+		return nil, err
+	}
+	encoder, err := LookupEncoder(opts.Format)
+	if err != nil {
+		return nil, err
+	}
+	if _, vector := encoder.(PageExporter); vector {
+		return nil, fmt.Errorf("%w: contact sheets are raster images", ErrUnsupportedFormat)
+	}
+
+	doc, err := s.openPDF(pdfPath, opts.Password)
+	if err != nil {
+		return nil, err
+	}
+	defer doc.Close()
+
+	if doc.NumPage() == 0 {
+		return nil, ErrPDFHasNoPages
+	}
+	indexes, _, err := sel.resolve(doc.NumPage(), s.cfg.MaxSheetPages)
+	if err != nil {
+		return nil, err
+	}
+
+	// Lay the grid out first so oversized sheets fail before anything is rendered.
+	thumbOpts := ConvertOptions{Width: sheet.ThumbWidth, Crop: opts.Crop, CropRelative: opts.CropRelative, Trim: opts.Trim, Rotate: opts.Rotate}
+	if err := s.cfg.validateOptions(thumbOpts); err != nil {
+		return nil, err
+	}
+	cells := make([]sheetCell, len(indexes))
+	cellHeight := 0
+	for i, idx := range indexes {
+		var layout pageLayout
+		err := s.withPageBudget(ctx, doc, idx, func() (err error) {
+			layout, err = s.layoutPage(doc, idx, thumbOpts)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		cells[i] = sheetCell{index: idx, layout: layout}
+		cellHeight = max(cellHeight, layout.plan.height)
+	}
+
+	scale := labelScale(sheet.ThumbWidth)
+	labelHeight := (glyphHeight + 2) * scale
+	columns := min(sheet.Columns, len(cells))
+	rows := (len(cells) + columns - 1) / columns
+	width := columns*sheet.ThumbWidth + (columns+1)*sheet.Padding
+	height := rows*(cellHeight+labelHeight) + (rows+1)*sheet.Padding
+	if err := s.cfg.checkSize(width, height); err != nil {
+		return nil, err
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(sheet.Background), image.Point{}, draw.Src)
+	ink := labelColor(sheet.Background)
+
+	for i, cell := range cells {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var thumb image.Image
+		err := s.renderPageTimed(ctx, doc, cell.index, thumbnailFormat, func() (err error) {
+			thumb, err = s.drawPage(doc, cell.index, cell.layout)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		x := sheet.Padding + (i%columns)*(sheet.ThumbWidth+sheet.Padding)
+		y := sheet.Padding + (i/columns)*(cellHeight+labelHeight+sheet.Padding)
+		tb := thumb.Bounds()
+		draw.Draw(canvas, image.Rect(x, y, x+tb.Dx(), y+tb.Dy()), thumb, tb.Min, draw.Over)
+		drawNumber(canvas, strconv.Itoa(cell.index+1), x+sheet.ThumbWidth/2, y+cellHeight+scale, scale, ink)
+	}
+
+	var buf bytes.Buffer
+	if err := s.encodePage(&buf, encoder, canvas, encodeOpts); err != nil {
+		return nil, fmt.Errorf("encode contact sheet: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// labelColor picks black or white, whichever contrasts more with bg.
+func labelColor(bg color.RGBA) color.RGBA {
+	luma := (299*int(bg.R) + 587*int(bg.G) + 114*int(bg.B)) / 1000
+	if luma < 128 {
+		return color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	}
+	return color.RGBA{A: 255}
+}
+
+const (
+	glyphWidth  = 3
+	glyphHeight = 5
+)
+
+// digitGlyphs is a 3x5 bitmap font for page numbers, one bit per pixel, row-major from the top left.
+// The standard library has no font rasteriser and digits are all a contact sheet needs.
+var digitGlyphs = [10]uint16{
+	0b111_101_101_101_111,
+	0b010_110_010_010_111,
+	0b111_001_111_100_111,
+	0b111_001_111_001_111,
+	0b101_101_111_001_001,
+	0b111_100_111_001_111,
+	0b111_100_111_101_111,
+	0b111_001_001_001_001,
+	0b111_101_111_101_111,
+	0b111_101_111_001_111,
+}
+
+// labelScale sizes the digit font relative to the thumbnail width.
+func labelScale(thumbWidth int) int {
+	return max(1, thumbWidth/80)
+}
+
+// drawNumber renders the decimal digits in text centred horizontally on centerX, with top edge at top.
+func drawNumber(dst draw.Image, text string, centerX, top, scale int, ink color.Color) {
+	advance := (glyphWidth + 1) * scale
+	x := centerX - (len(text)*advance-scale)/2
+	for _, ch := range text {
+		glyph := digitGlyphs[ch-'0']
+		for row := 0; row < glyphHeight; row++ {
+			for col := 0; col < glyphWidth; col++ {
+				bit := glyphWidth*glyphHeight - 1 - (row*glyphWidth + col)
+				if glyph&(1<<bit) == 0 {
+					continue
+				}
+				px := x + col*scale
+				py := top + row*scale
+				draw.Draw(dst, image.Rect(px, py, px+scale, py+scale), image.NewUniform(ink), image.Point{}, draw.Src)
+			}
+		}
+		x += advance
+	}
+}
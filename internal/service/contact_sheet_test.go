@@ -0,0 +1,59 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestRenderContactSheet_Layout(t *testing.T) {
+	restore := SetDocumentOpenerForTest(func(string) (Document, error) {
+		return &stubDocument{pages: 3, img: image.NewRGBA(image.Rect(0, 0, 10, 10))}, nil
+	})
+	defer restore()
+
+	svc := NewPDFService(Config{})
+	sheet := ContactSheetOptions{Columns: 2, ThumbWidth: 80, Padding: 4, Background: color.RGBA{R: 10, G: 20, B: 30, A: 255}}
+	data, err := svc.RenderContactSheet(context.Background(), "ignored", AllPages(), sheet, ConvertOptions{Format: FormatPNG})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode png: %v", err)
+	}
+
+	// Letter pages at 80px wide are 104px tall, plus a 7px label strip.
+	if got, want := img.Bounds().Size(), image.Pt(2*80+3*4, 2*(104+7)+3*4); got != want {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if r, g, b, _ := img.At(0, 0).RGBA(); r>>8 != 10 || g>>8 != 20 || b>>8 != 30 {
+		t.Fatalf("expected background colour in the corner, got %v", img.At(0, 0))
+	}
+}
+
+func TestRenderContactSheet_TooLarge(t *testing.T) {
+	restore := SetDocumentOpenerForTest(func(string) (Document, error) {
+		return &stubDocument{pages: 4, img: image.NewRGBA(image.Rect(0, 0, 10, 10))}, nil
+	})
+	defer restore()
+
+	svc := NewPDFService(Config{MaxDimension: 500})
+	_, err := svc.RenderContactSheet(context.Background(), "ignored", AllPages(), ContactSheetOptions{Columns: 4, ThumbWidth: 200}, ConvertOptions{})
+	if !errors.Is(err, ErrRenderLimitExceeded) {
+		t.Fatalf("expected ErrRenderLimitExceeded, got %v", err)
+	}
+}
+
+func TestParseHexColor(t *testing.T) {
+	if c, err := ParseHexColor("#0f8"); err != nil || c != (color.RGBA{R: 0x00, G: 0xff, B: 0x88, A: 255}) {
+		t.Fatalf("unexpected colour %v (%v)", c, err)
+	}
+	if _, err := ParseHexColor("#12345g"); !errors.Is(err, ErrInvalidRenderOptions) {
+		t.Fatalf("expected ErrInvalidRenderOptions, got %v", err)
+	}
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrUnsupportedFormat is returned when no encoder is registered for the requested format.
+	ErrUnsupportedFormat = errors.New("unsupported output format")
+	// ErrOutputTooLarge is returned when a page cannot be encoded within ConvertOptions.MaxBytes.
+	ErrOutputTooLarge = errors.New("encoded output exceeds max bytes")
+)
+
+// Format identifies an output image encoding.
+type Format string
+
+const (
+	FormatJPEG Format = "jpeg"
+	FormatPNG  Format = "png"
+	FormatWebP Format = "webp"
+	FormatAVIF Format = "avif"
+)
+
+// Chroma selects the chroma subsampling used by encoders that expose it.
+type Chroma string
+
+const (
+	Chroma420 Chroma = "420"
+	Chroma422 Chroma = "422"
+	Chroma444 Chroma = "444"
+)
+
+// ParseChroma parses a subsampling name such as "444" or "4:4:4". An empty string selects Chroma420.
+func ParseChroma(raw string) (Chroma, error) {
+	switch c := Chroma(strings.ReplaceAll(strings.TrimSpace(raw), ":", "")); c {
+	case "":
+		return Chroma420, nil
+	case Chroma420, Chroma422, Chroma444:
+		return c, nil
+	default:
+		return "", fmt.Errorf("%w: unknown chroma subsampling %q", ErrInvalidRenderOptions, raw)
+	}
+}
+
+func (c Chroma) ratio() image.YCbCrSubsampleRatio {
+	switch c {
+	case Chroma444:
+		return image.YCbCrSubsampleRatio444
+	case Chroma422:
+		return image.YCbCrSubsampleRatio422
+	default:
+		return image.YCbCrSubsampleRatio420
+	}
+}
+
+// EncodeOptions carries per-request encoder settings. Encoders ignore options they do not support;
+// requests for a chroma subsampling are rejected up front unless the encoder is a ChromaSubsampler.
+type EncodeOptions struct {
+	Quality int
+	Chroma  Chroma
+}
+
+// Encoder writes rendered pages in a single output format.
+type Encoder interface {
+	ContentType() string
+	// Extension returns the file extension including the leading dot.
+	Extension() string
+	// Lossy reports whether Quality affects the output; only lossy encoders are retried under a byte budget.
+	Lossy() bool
+	Encode(w io.Writer, img image.Image, opts EncodeOptions) error
+}
+
+var (
+	encodersMu sync.RWMutex
+	encoders   = map[Format]Encoder{}
+)
+
+// RegisterEncoder makes enc available under format, replacing any previous registration.
+// It is intended to be called from init functions.
+func RegisterEncoder(format Format, enc Encoder) {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+	encoders[format] = enc
+}
+
+// LookupEncoder returns the encoder registered for format. An empty format selects JPEG.
+func LookupEncoder(format Format) (Encoder, error) {
+	if format == "" {
+		format = FormatJPEG
+	}
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+	enc, ok := encoders[format]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, format)
+	}
+	return enc, nil
+}
+
+// ChromaSubsampler is implemented by encoders that honour EncodeOptions.Chroma. Only AVIF does: the
+// standard library JPEG encoder always uses 4:2:0, and the other formats have no such setting.
+type ChromaSubsampler interface {
+	Encoder
+	// SubsamplesChroma marks the encoder; it is never called.
+	SubsamplesChroma()
+}
+
+// RegisteredFormats returns every format with a registered encoder, sorted by name.
+func RegisteredFormats() []Format {
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+	formats := make([]Format, 0, len(encoders))
+	for f := range encoders {
+		formats = append(formats, f)
+	}
+	sort.Slice(formats, func(i, j int) bool { return formats[i] < formats[j] })
+	return formats
+}
+
+// ParseFormat accepts a format name or common file extension such as "jpg".
+func ParseFormat(raw string) (Format, error) {
+	name := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(raw)), ".")
+	if name == "jpg" {
+		name = string(FormatJPEG)
+	}
+	format := Format(name)
+	if _, err := LookupEncoder(format); err != nil {
+		return "", err
+	}
+	return format, nil
+}
+
+// FormatForContentType returns the registered format whose encoder produces contentType.
+func FormatForContentType(contentType string) (Format, bool) {
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+	for format, enc := range encoders {
+		if enc.ContentType() == contentType {
+			return format, true
+		}
+	}
+	return "", false
+}
+
+// encodeWithin encodes img at the highest quality in [minQuality, opts.Quality] whose output fits
+// in maxBytes. Encoded size grows with quality, so a binary search keeps the number of attempts small.
+func encodeWithin(enc Encoder, img image.Image, opts EncodeOptions, minQuality, maxBytes int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := enc.Encode(&buf, img, opts); err != nil {
+		return nil, err
+	}
+	if buf.Len() <= maxBytes {
+		return buf.Bytes(), nil
+	}
+	smallest := buf.Len()
+	if !enc.Lossy() {
+		return nil, fmt.Errorf("%w: %d bytes, limit %d", ErrOutputTooLarge, smallest, maxBytes)
+	}
+
+	var best []byte
+	lo, hi := minQuality, opts.Quality-1
+	for lo <= hi {
+		attempt := opts
+		attempt.Quality = (lo + hi) / 2
+		buf.Reset()
+		if err := enc.Encode(&buf, img, attempt); err != nil {
+			return nil, err
+		}
+		smallest = min(smallest, buf.Len())
+		if buf.Len() <= maxBytes {
+			best = bytes.Clone(buf.Bytes())
+			lo = attempt.Quality + 1
+		} else {
+			hi = attempt.Quality - 1
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("%w: %d bytes at minimum quality, limit %d", ErrOutputTooLarge, smallest, maxBytes)
+	}
+	return best, nil
+}
+
+func init() {
+	RegisterEncoder(FormatJPEG, jpegEncoder{})
+	RegisterEncoder(FormatPNG, pngEncoder{})
+}
+
+// jpegEncoder uses image/jpeg, which always writes baseline 4:2:0 (or single-channel for *image.Gray).
+type jpegEncoder struct{}
+
+func (jpegEncoder) ContentType() string { return "image/jpeg" }
+func (jpegEncoder) Extension() string   { return ".jpg" }
+func (jpegEncoder) Lossy() bool         { return true }
+
+func (jpegEncoder) Encode(w io.Writer, img image.Image, opts EncodeOptions) error {
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: opts.Quality})
+}
+
+type pngEncoder struct{}
+
+func (pngEncoder) ContentType() string { return "image/png" }
+func (pngEncoder) Extension() string   { return ".png" }
+func (pngEncoder) Lossy() bool         { return false }
+
+func (pngEncoder) Encode(w io.Writer, img image.Image, _ EncodeOptions) error {
+	return png.Encode(w, img)
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"image"
+	"io"
+
+	"github.com/gen2brain/avif"
+)
+
+func init() {
+	RegisterEncoder(FormatAVIF, avifEncoder{})
+}
+
+// avifEncoder uses the WASM build of libavif, so it needs neither cgo nor system libraries.
+type avifEncoder struct{}
+
+func (avifEncoder) ContentType() string { return "image/avif" }
+func (avifEncoder) Extension() string   { return ".avif" }
+func (avifEncoder) Lossy() bool         { return true }
+func (avifEncoder) SubsamplesChroma()   {}
+
+func (avifEncoder) Encode(w io.Writer, img image.Image, opts EncodeOptions) error {
+	return avif.Encode(w, img, avif.Options{
+		Quality:           opts.Quality,
+		QualityAlpha:      opts.Quality,
+		Speed:             avif.DefaultSpeed,
+		ChromaSubsampling: opts.Chroma.ratio(),
+	})
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestEncoders_ProduceFormatSignature(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 32), G: uint8(y * 32), A: 255})
+		}
+	}
+
+	cases := []struct {
+		format    Format
+		signature func([]byte) bool
+	}{
+		{FormatJPEG, func(b []byte) bool { return bytes.HasPrefix(b, []byte{0xFF, 0xD8}) }},
+		{FormatPNG, func(b []byte) bool { return bytes.HasPrefix(b, []byte("\x89PNG")) }},
+		{FormatWebP, func(b []byte) bool { return len(b) > 12 && string(b[8:12]) == "WEBP" }},
+		{FormatAVIF, func(b []byte) bool { return len(b) > 12 && string(b[4:12]) == "ftypavif" }},
+	}
+
+	for _, tc := range cases {
+		enc, err := LookupEncoder(tc.format)
+		if err != nil {
+			t.Fatalf("%s: lookup: %v", tc.format, err)
+		}
+		var buf bytes.Buffer
+		if err := enc.Encode(&buf, img, EncodeOptions{Quality: 80}); err != nil {
+			t.Fatalf("%s: encode: %v", tc.format, err)
+		}
+		if !tc.signature(buf.Bytes()) {
+			t.Fatalf("%s: unexpected header %x", tc.format, buf.Bytes()[:min(16, buf.Len())])
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for raw, want := range map[string]Format{"jpg": FormatJPEG, ".PNG": FormatPNG, "webp": FormatWebP, "avif": FormatAVIF} {
+		got, err := ParseFormat(raw)
+		if err != nil || got != want {
+			t.Fatalf("ParseFormat(%q): expected %s, got %s (%v)", raw, want, got, err)
+		}
+	}
+	if _, err := ParseFormat("gif"); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatalf("expected ErrUnsupportedFormat, got %v", err)
+	}
+}
+
+func TestFormatForContentType(t *testing.T) {
+	if f, ok := FormatForContentType("image/webp"); !ok || f != FormatWebP {
+		t.Fatalf("expected webp, got %s %v", f, ok)
+	}
+	if _, ok := FormatForContentType("image/gif"); ok {
+		t.Fatal("expected image/gif to be unsupported")
+	}
+}
+
+func TestStreamPages_VectorFormats(t *testing.T) {
+	restore := SetDocumentOpenerForTest(func(string) (Document, error) {
+		// No image: vector pages must not be rasterised.
+		return &stubDocument{pages: 3, imgErr: errors.New("unexpected render")}, nil
+	})
+	defer restore()
+
+	svc := NewPDFService(Config{})
+	sel, _ := ParsePageSelector("1,3")
+	for _, format := range []Format{FormatSVG, FormatHTML} {
+		pages, err := svc.ConvertPages(context.Background(), "ignored", sel, ConvertOptions{Format: format})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", format, err)
+		}
+		if len(pages) != 2 || pages[1].Page != 3 || !bytes.Contains(pages[1].Data, []byte("page 2")) {
+			t.Fatalf("%s: unexpected pages %+v", format, pages)
+		}
+	}
+
+	_, err := svc.ConvertPages(context.Background(), "ignored", FirstPage(), ConvertOptions{Format: FormatSVG, MaxBytes: 10})
+	if !errors.Is(err, ErrOutputTooLarge) {
+		t.Fatalf("expected ErrOutputTooLarge, got %v", err)
+	}
+}
+
+func noisyImage(size int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 7919 % 251)
+	}
+	return img
+}
+
+func TestEncodeWithin_LowersQualityToFit(t *testing.T) {
+	enc, _ := LookupEncoder(FormatJPEG)
+	img := noisyImage(64)
+
+	var full bytes.Buffer
+	if err := enc.Encode(&full, img, EncodeOptions{Quality: 95}); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	limit := full.Len() / 2
+	data, err := encodeWithin(enc, img, EncodeOptions{Quality: 95}, 1, limit)
+	if err != nil {
+		t.Fatalf("encodeWithin: %v", err)
+	}
+	if len(data) > limit {
+		t.Fatalf("expected at most %d bytes, got %d", limit, len(data))
+	}
+
+	if _, err := encodeWithin(enc, img, EncodeOptions{Quality: 95}, 90, limit); !errors.Is(err, ErrOutputTooLarge) {
+		t.Fatalf("expected ErrOutputTooLarge above min quality, got %v", err)
+	}
+}
+
+func TestEncodeWithin_LosslessDoesNotRetry(t *testing.T) {
+	enc, _ := LookupEncoder(FormatPNG)
+	if _, err := encodeWithin(enc, noisyImage(32), EncodeOptions{}, 1, 100); !errors.Is(err, ErrOutputTooLarge) {
+		t.Fatalf("expected ErrOutputTooLarge, got %v", err)
+	}
+}
+
+func TestParseChroma(t *testing.T) {
+	for raw, want := range map[string]Chroma{"": Chroma420, "4:4:4": Chroma444, "422": Chroma422} {
+		got, err := ParseChroma(raw)
+		if err != nil || got != want {
+			t.Fatalf("ParseChroma(%q): expected %s, got %s (%v)", raw, want, got, err)
+		}
+	}
+	if _, err := ParseChroma("411"); !errors.Is(err, ErrInvalidRenderOptions) {
+		t.Fatalf("expected ErrInvalidRenderOptions, got %v", err)
+	}
+}
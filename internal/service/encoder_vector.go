@@ -0,0 +1,71 @@
+package service
+
+import (
+	"fmt"
+	"image"
+	"io"
+)
+
+const (
+	FormatSVG  Format = "svg"
+	FormatHTML Format = "html"
+)
+
+// PageExporter is implemented by encoders that write a page straight from the document instead of
+// from a rendered image. Raster-only options such as DPI, size, quality and grayscale do not apply.
+type PageExporter interface {
+	Encoder
+	// ExportPage writes page pageIndex (0-based) of doc.
+	ExportPage(w io.Writer, doc Document, pageIndex int) error
+}
+
+// IsActiveContent reports whether contentType is one of the vector formats, which browsers treat as
+// documents that can run script and follow links rather than as inert images.
+func IsActiveContent(contentType string) bool {
+	return contentType == (svgEncoder{}).ContentType() || contentType == (htmlEncoder{}).ContentType()
+}
+
+func init() {
+	RegisterEncoder(FormatSVG, svgEncoder{})
+	RegisterEncoder(FormatHTML, htmlEncoder{})
+}
+
+// svgEncoder writes MuPDF's SVG output, with text converted to paths so no fonts are needed.
+type svgEncoder struct{}
+
+func (svgEncoder) ContentType() string { return "image/svg+xml" }
+func (svgEncoder) Extension() string   { return ".svg" }
+func (svgEncoder) Lossy() bool         { return false }
+
+func (svgEncoder) Encode(io.Writer, image.Image, EncodeOptions) error {
+	return fmt.Errorf("%w: svg is exported from document pages, not images", ErrUnsupportedFormat)
+}
+
+func (svgEncoder) ExportPage(w io.Writer, doc Document, pageIndex int) error {
+	svg, err := doc.SVG(pageIndex)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, svg)
+	return err
+}
+
+// htmlEncoder writes a standalone HTML document with absolutely positioned text and embedded images.
+type htmlEncoder struct{}
+
+func (htmlEncoder) ContentType() string { return "text/html; charset=utf-8" }
+func (htmlEncoder) Extension() string   { return ".html" }
+func (htmlEncoder) Lossy() bool         { return false }
+
+func (htmlEncoder) Encode(io.Writer, image.Image, EncodeOptions) error {
+	return fmt.Errorf("%w: html is exported from document pages, not images", ErrUnsupportedFormat)
+}
+
+func (htmlEncoder) ExportPage(w io.Writer, doc Document, pageIndex int) error {
+	html, err := doc.HTML(pageIndex, true)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, html)
+	return err
+}
@@ -0,0 +1,23 @@
+package service
+
+import (
+	"image"
+	"io"
+
+	"github.com/gen2brain/webp"
+)
+
+func init() {
+	RegisterEncoder(FormatWebP, webpEncoder{})
+}
+
+// webpEncoder uses the WASM build of libwebp, so it needs neither cgo nor system libraries.
+type webpEncoder struct{}
+
+func (webpEncoder) ContentType() string { return "image/webp" }
+func (webpEncoder) Extension() string   { return ".webp" }
+func (webpEncoder) Lossy() bool         { return true }
+
+func (webpEncoder) Encode(w io.Writer, img image.Image, opts EncodeOptions) error {
+	return webp.Encode(w, img, webp.Options{Quality: opts.Quality, Method: webp.DefaultMethod})
+}
@@ -0,0 +1,740 @@
+package service
+
+/*
+#include <setjmp.h>
+#include <stdlib.h>
+#include <string.h>
+
+// Opaque MuPDF types and functions provided by the library that go-fitz links in. go-fitz does not
+// wrap them, so they are declared here rather than included from its headers.
+typedef struct fz_context fz_context;
+typedef struct fz_document fz_document;
+typedef struct fz_page fz_page;
+typedef struct fz_stext_page fz_stext_page;
+typedef struct fz_buffer fz_buffer;
+typedef struct fz_output fz_output;
+typedef struct fz_colorspace fz_colorspace;
+typedef struct fz_pixmap fz_pixmap;
+typedef struct fz_device fz_device;
+typedef struct fz_separations fz_separations;
+typedef struct { float a, b, c, d, e, f; } fz_matrix;
+typedef struct { float x0, y0, x1, y1; } fz_rect;
+typedef struct { int x0, y0, x1, y1; } fz_irect;
+typedef struct { int flags; float scale; } fz_stext_options;
+typedef struct { int chapter; int page; } fz_location;
+
+// fz_outline matches MuPDF's public layout, which Outline walks directly.
+typedef struct fz_outline {
+	int refs;
+	char *title;
+	char *uri;
+	fz_location page;
+	float x, y;
+	struct fz_outline *next;
+	struct fz_outline *down;
+	int is_open;
+} fz_outline;
+
+// Values from fitz/device.h, fitz/output-svg.h and fitz/structured-text.h.
+enum { FZ_NO_CACHE = 2, FZ_SVG_TEXT_AS_PATH = 0, FZ_STEXT_PRESERVE_IMAGES = 4 };
+
+// fz_cookie matches MuPDF's public layout. Setting abort makes a run in progress stop early.
+typedef struct {
+	int abort;
+	int progress;
+	size_t progress_max;
+	int errors;
+	int incomplete;
+} fz_cookie;
+
+// MuPDF's exception handling, copied from fitz/context.h and fitz/system.h. The library is built with
+// sigsetjmp on Unix, and fz_jmp_buf must match it. Locals assigned inside fz_try and read in
+// fz_always or fz_catch must be passed to fz_var so longjmp does not leave them stale.
+#if defined(__unix) || defined(__APPLE__)
+typedef sigjmp_buf fz_jmp_buf;
+#define fz_setjmp(BUF) sigsetjmp(BUF, 0)
+#else
+typedef jmp_buf fz_jmp_buf;
+#define fz_setjmp(BUF) setjmp(BUF)
+#endif
+fz_jmp_buf *fz_push_try(fz_context *ctx);
+int fz_do_try(fz_context *ctx);
+int fz_do_always(fz_context *ctx);
+int fz_do_catch(fz_context *ctx);
+const char *fz_caught_message(fz_context *ctx);
+void fz_var_imp(void *var);
+#define fz_var(var) fz_var_imp((void *)&(var))
+#define fz_try(ctx) if (!fz_setjmp(*fz_push_try(ctx))) if (fz_do_try(ctx)) do
+#define fz_always(ctx) while (0); if (fz_do_always(ctx)) do
+#define fz_catch(ctx) while (0); if (fz_do_catch(ctx))
+
+int fz_authenticate_password(fz_context *ctx, fz_document *doc, const char *password);
+fz_page *fz_load_page(fz_context *ctx, fz_document *doc, int number);
+void fz_drop_page(fz_context *ctx, fz_page *page);
+fz_stext_page *fz_new_stext_page(fz_context *ctx, fz_rect mediabox);
+fz_device *fz_new_stext_device(fz_context *ctx, fz_stext_page *page, const void *options);
+void fz_drop_stext_page(fz_context *ctx, fz_stext_page *page);
+fz_buffer *fz_new_buffer(fz_context *ctx, size_t capacity);
+void fz_drop_buffer(fz_context *ctx, fz_buffer *buf);
+const char *fz_string_from_buffer(fz_context *ctx, fz_buffer *buf);
+fz_output *fz_new_output_with_buffer(fz_context *ctx, fz_buffer *buf);
+void fz_close_output(fz_context *ctx, fz_output *out);
+void fz_drop_output(fz_context *ctx, fz_output *out);
+void fz_print_stext_page_as_json(fz_context *ctx, fz_output *out, fz_stext_page *page, float scale);
+void fz_print_stext_page_as_html(fz_context *ctx, fz_output *out, fz_stext_page *page, int id);
+void fz_print_stext_header_as_html(fz_context *ctx, fz_output *out);
+void fz_print_stext_trailer_as_html(fz_context *ctx, fz_output *out);
+fz_device *fz_new_svg_device(fz_context *ctx, fz_output *out, float page_width, float page_height, int text_format, int reuse_images);
+void fz_enable_device_hints(fz_context *ctx, fz_device *dev, int hints);
+fz_outline *fz_load_outline(fz_context *ctx, fz_document *doc);
+void fz_drop_outline(fz_context *ctx, fz_outline *outline);
+int fz_lookup_metadata(fz_context *ctx, fz_document *doc, const char *key, char *buf, int size);
+fz_rect fz_bound_page(fz_context *ctx, fz_page *page);
+fz_matrix fz_scale(float sx, float sy);
+fz_rect fz_transform_rect(fz_rect rect, fz_matrix m);
+fz_irect fz_round_rect(fz_rect rect);
+fz_colorspace *fz_device_rgb(fz_context *ctx);
+fz_pixmap *fz_new_pixmap_with_bbox(fz_context *ctx, fz_colorspace *cs, fz_irect bbox, fz_separations *seps, int alpha);
+void fz_clear_pixmap_with_value(fz_context *ctx, fz_pixmap *pix, int value);
+unsigned char *fz_pixmap_samples(fz_context *ctx, const fz_pixmap *pix);
+int fz_pixmap_stride(fz_context *ctx, const fz_pixmap *pix);
+void fz_drop_pixmap(fz_context *ctx, fz_pixmap *pix);
+fz_device *fz_new_draw_device(fz_context *ctx, fz_matrix transform, fz_pixmap *dest);
+void fz_run_page(fz_context *ctx, fz_page *page, fz_device *dev, fz_matrix transform, fz_cookie *cookie);
+void fz_run_page_contents(fz_context *ctx, fz_page *page, fz_device *dev, fz_matrix transform, fz_cookie *cookie);
+void fz_close_device(fz_context *ctx, fz_device *dev);
+void fz_drop_device(fz_context *ctx, fz_device *dev);
+
+// page_bound stores the page's bounds in points in *bounds. It returns 0, with MuPDF's message in
+// *message, when the page cannot be loaded.
+static int page_bound(fz_context *ctx, fz_document *doc, int number, fz_rect *bounds, const char **message) {
+	fz_page *page = NULL;
+	fz_var(page);
+
+	fz_try(ctx) {
+		page = fz_load_page(ctx, doc, number);
+		*bounds = fz_bound_page(ctx, page);
+	}
+	fz_always(ctx) {
+		fz_drop_page(ctx, page);
+	}
+	fz_catch(ctx) {
+		*message = fz_caught_message(ctx);
+		return 0;
+	}
+	return 1;
+}
+
+// authenticate_password wraps fz_authenticate_password. It returns 0, with MuPDF's message in
+// *message when MuPDF failed, if the password does not unlock the document.
+static int authenticate_password(fz_context *ctx, fz_document *doc, const char *password, const char **message) {
+	int ok = 0;
+	fz_var(ok);
+
+	fz_try(ctx) {
+		ok = fz_authenticate_password(ctx, doc, password);
+	}
+	fz_catch(ctx) {
+		*message = fz_caught_message(ctx);
+		return 0;
+	}
+	return ok;
+}
+
+// lookup_metadata wraps fz_lookup_metadata. It returns -1, with MuPDF's message in *message when
+// MuPDF failed, if the entry cannot be read.
+static int lookup_metadata(fz_context *ctx, fz_document *doc, const char *key, char *buf, int size, const char **message) {
+	int n = -1;
+	fz_var(n);
+
+	fz_try(ctx) {
+		n = fz_lookup_metadata(ctx, doc, key, buf, size);
+	}
+	fz_catch(ctx) {
+		*message = fz_caught_message(ctx);
+		return -1;
+	}
+	return n;
+}
+
+// load_outline wraps fz_load_outline. The caller drops the result. It returns NULL when the document
+// has no outline, or when MuPDF failed, in which case *message holds MuPDF's message.
+static fz_outline *load_outline(fz_context *ctx, fz_document *doc, const char **message) {
+	fz_outline *outline = NULL;
+	fz_var(outline);
+
+	fz_try(ctx) {
+		outline = fz_load_outline(ctx, doc);
+	}
+	fz_catch(ctx) {
+		*message = fz_caught_message(ctx);
+		return NULL;
+	}
+	return outline;
+}
+
+// stext_json returns the page's structured text as MuPDF JSON. The caller frees the result. It matches
+// fz_new_stext_page_from_page, but runs the page with cookie so extraction can be aborted. It returns
+// NULL, with MuPDF's message in *message when MuPDF failed, if the text cannot be extracted.
+static char *stext_json(fz_context *ctx, fz_document *doc, int number, fz_cookie *cookie, const char **message) {
+	fz_matrix identity = { 1, 0, 0, 1, 0, 0 };
+	fz_page *page = NULL;
+	fz_stext_page *text = NULL;
+	fz_device *dev = NULL;
+	fz_buffer *buf = NULL;
+	fz_output *out = NULL;
+	char *json = NULL;
+	fz_var(page);
+	fz_var(text);
+	fz_var(dev);
+	fz_var(buf);
+	fz_var(out);
+
+	fz_try(ctx) {
+		page = fz_load_page(ctx, doc, number);
+		text = fz_new_stext_page(ctx, fz_bound_page(ctx, page));
+		dev = fz_new_stext_device(ctx, text, NULL);
+		fz_run_page_contents(ctx, page, dev, identity, cookie);
+		fz_close_device(ctx, dev);
+
+		buf = fz_new_buffer(ctx, 4096);
+		out = fz_new_output_with_buffer(ctx, buf);
+		fz_print_stext_page_as_json(ctx, out, text, 1);
+		fz_close_output(ctx, out);
+		// Nothing after the copy can throw, so json never needs freeing in fz_catch.
+		json = strdup(fz_string_from_buffer(ctx, buf));
+	}
+	fz_always(ctx) {
+		fz_drop_output(ctx, out);
+		fz_drop_buffer(ctx, buf);
+		fz_drop_device(ctx, dev);
+		fz_drop_stext_page(ctx, text);
+		fz_drop_page(ctx, page);
+	}
+	fz_catch(ctx) {
+		*message = fz_caught_message(ctx);
+		return NULL;
+	}
+	return json;
+}
+
+// page_svg returns the page as a standalone SVG document with text drawn as paths. The caller frees the
+// result. It matches go-fitz's SVG, but runs the page with cookie so the export can be aborted. It
+// returns NULL, with MuPDF's message in *message when MuPDF failed, if the page cannot be exported.
+static char *page_svg(fz_context *ctx, fz_document *doc, int number, fz_cookie *cookie, const char **message) {
+	fz_matrix identity = { 1, 0, 0, 1, 0, 0 };
+	fz_page *page = NULL;
+	fz_buffer *buf = NULL;
+	fz_output *out = NULL;
+	fz_device *dev = NULL;
+	char *svg = NULL;
+	fz_var(page);
+	fz_var(buf);
+	fz_var(out);
+	fz_var(dev);
+
+	fz_try(ctx) {
+		page = fz_load_page(ctx, doc, number);
+		fz_rect bounds = fz_bound_page(ctx, page);
+		buf = fz_new_buffer(ctx, 1024);
+		out = fz_new_output_with_buffer(ctx, buf);
+		dev = fz_new_svg_device(ctx, out, bounds.x1 - bounds.x0, bounds.y1 - bounds.y0, FZ_SVG_TEXT_AS_PATH, 1);
+		fz_enable_device_hints(ctx, dev, FZ_NO_CACHE);
+		fz_run_page(ctx, page, dev, identity, cookie);
+		fz_close_device(ctx, dev);
+		fz_close_output(ctx, out);
+		// Nothing after the copy can throw, so svg never needs freeing in fz_catch.
+		svg = strdup(fz_string_from_buffer(ctx, buf));
+	}
+	fz_always(ctx) {
+		fz_drop_device(ctx, dev);
+		fz_drop_output(ctx, out);
+		fz_drop_buffer(ctx, buf);
+		fz_drop_page(ctx, page);
+	}
+	fz_catch(ctx) {
+		*message = fz_caught_message(ctx);
+		return NULL;
+	}
+	return svg;
+}
+
+// page_html returns the page's structured text as HTML with embedded images, wrapped in a complete
+// document when header is set. The caller frees the result. It matches go-fitz's HTML, but runs the
+// page with cookie so the export can be aborted. It returns NULL, with MuPDF's message in *message
+// when MuPDF failed, if the page cannot be exported.
+static char *page_html(fz_context *ctx, fz_document *doc, int number, int header, fz_cookie *cookie, const char **message) {
+	fz_matrix identity = { 1, 0, 0, 1, 0, 0 };
+	fz_stext_options opts = { FZ_STEXT_PRESERVE_IMAGES, 0 };
+	fz_page *page = NULL;
+	fz_stext_page *text = NULL;
+	fz_device *dev = NULL;
+	fz_buffer *buf = NULL;
+	fz_output *out = NULL;
+	char *html = NULL;
+	fz_var(page);
+	fz_var(text);
+	fz_var(dev);
+	fz_var(buf);
+	fz_var(out);
+
+	fz_try(ctx) {
+		page = fz_load_page(ctx, doc, number);
+		text = fz_new_stext_page(ctx, fz_bound_page(ctx, page));
+		dev = fz_new_stext_device(ctx, text, &opts);
+		fz_enable_device_hints(ctx, dev, FZ_NO_CACHE);
+		fz_run_page(ctx, page, dev, identity, cookie);
+		fz_close_device(ctx, dev);
+
+		buf = fz_new_buffer(ctx, 1024);
+		out = fz_new_output_with_buffer(ctx, buf);
+		if (header) {
+			fz_print_stext_header_as_html(ctx, out);
+		}
+		fz_print_stext_page_as_html(ctx, out, text, number);
+		if (header) {
+			fz_print_stext_trailer_as_html(ctx, out);
+		}
+		fz_close_output(ctx, out);
+		// Nothing after the copy can throw, so html never needs freeing in fz_catch.
+		html = strdup(fz_string_from_buffer(ctx, buf));
+	}
+	fz_always(ctx) {
+		fz_drop_output(ctx, out);
+		fz_drop_buffer(ctx, buf);
+		fz_drop_device(ctx, dev);
+		fz_drop_stext_page(ctx, text);
+		fz_drop_page(ctx, page);
+	}
+	fz_catch(ctx) {
+		*message = fz_caught_message(ctx);
+		return NULL;
+	}
+	return html;
+}
+
+// render_region draws the part of the page between (x0, y0) and (x1, y1), in points from the page's
+// top-left corner, at scale pixels per point, or the whole page when whole is set. Only that area is
+// rasterised, and the run stops early once cookie->abort is set. It returns RGBA samples with a stride
+// of 4 * width, which the caller frees, or NULL when allocation fails, the run was aborted or MuPDF
+// failed, in which case *message holds MuPDF's message.
+static unsigned char *render_region(fz_context *ctx, fz_document *doc, int number, float scale, int whole,
+		float x0, float y0, float x1, float y1, fz_cookie *cookie, int *width, int *height, const char **message) {
+	fz_page *page = NULL;
+	fz_pixmap *pix = NULL;
+	fz_device *dev = NULL;
+	unsigned char *out = NULL;
+	fz_var(page);
+	fz_var(pix);
+	fz_var(dev);
+
+	fz_try(ctx) {
+		page = fz_load_page(ctx, doc, number);
+		fz_rect bounds = fz_bound_page(ctx, page);
+		fz_rect clip = { bounds.x0 + x0, bounds.y0 + y0, bounds.x0 + x1, bounds.y0 + y1 };
+		if (whole) {
+			clip = bounds;
+		}
+		fz_matrix ctm = fz_scale(scale, scale);
+		fz_matrix identity = { 1, 0, 0, 1, 0, 0 };
+		fz_irect bbox = fz_round_rect(fz_transform_rect(clip, ctm));
+
+		pix = fz_new_pixmap_with_bbox(ctx, fz_device_rgb(ctx), bbox, NULL, 1);
+		fz_clear_pixmap_with_value(ctx, pix, 0xff);
+		dev = fz_new_draw_device(ctx, ctm, pix);
+		fz_run_page(ctx, page, dev, identity, cookie);
+		fz_close_device(ctx, dev);
+
+		int w = bbox.x1 - bbox.x0, h = bbox.y1 - bbox.y0;
+		int stride = fz_pixmap_stride(ctx, pix);
+		unsigned char *samples = fz_pixmap_samples(ctx, pix);
+		// An aborted run leaves a partial pixmap, so skip copying it out. Nothing after the
+		// allocation can throw, so out never needs freeing in fz_catch.
+		out = cookie->abort ? NULL : malloc((size_t)w * h * 4);
+		if (out != NULL) {
+			for (int y = 0; y < h; y++) {
+				memcpy(out + (size_t)y * w * 4, samples + (size_t)y * stride, (size_t)w * 4);
+			}
+		}
+		*width = w;
+		*height = h;
+	}
+	fz_always(ctx) {
+		fz_drop_device(ctx, dev);
+		fz_drop_pixmap(ctx, pix);
+		fz_drop_page(ctx, page);
+	}
+	fz_catch(ctx) {
+		*message = fz_caught_message(ctx);
+		return NULL;
+	}
+	return out;
+}
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"unsafe"
+
+	fitz "github.com/gen2brain/go-fitz"
+)
+
+// fitzDocument adapts go-fitz documents. go-fitz reports encrypted files as ErrNeedsPassword from New
+// but keeps the document open, so the adapter records that the document is locked instead of failing.
+// Rasterisation, text extraction and vector export run with a MuPDF cookie so Interrupt can stop them
+// mid-page.
+type fitzDocument struct {
+	*fitz.Document
+	locked bool
+
+	// cookieMu guards cookie against Interrupt racing Close. Renders read the cookie without it,
+	// since documents are only closed once their renders have returned.
+	cookieMu sync.Mutex
+	cookie   *C.fz_cookie
+}
+
+func openFitzDocument(path string) (Document, error) {
+	doc, err := fitz.New(path)
+	locked := errors.Is(err, fitz.ErrNeedsPassword)
+	if err != nil && !locked {
+		return nil, err
+	}
+	cookie := (*C.fz_cookie)(C.calloc(1, C.sizeof_fz_cookie))
+	if cookie == nil {
+		doc.Close()
+		return nil, errors.New("allocate render cookie")
+	}
+	return &fitzDocument{Document: doc, locked: locked, cookie: cookie}, nil
+}
+
+// Close releases the document and its cookie.
+func (d *fitzDocument) Close() error {
+	d.cookieMu.Lock()
+	defer d.cookieMu.Unlock()
+	err := d.Document.Close()
+	C.free(unsafe.Pointer(d.cookie))
+	d.cookie = nil
+	return err
+}
+
+// Interrupt aborts the render or extraction in progress, if any, and makes every later one fail. It
+// may be called from any goroutine.
+func (d *fitzDocument) Interrupt() {
+	d.cookieMu.Lock()
+	defer d.cookieMu.Unlock()
+	if d.cookie != nil {
+		d.cookie.abort = 1
+	}
+}
+
+// interrupted reports whether Interrupt was called; MuPDF leaves partial output behind when aborted.
+func (d *fitzDocument) interrupted() bool {
+	return d.cookie.abort != 0
+}
+
+// handles returns the MuPDF context, document and the mutex go-fitz guards them with. go-fitz keeps
+// them unexported, so they are read through reflection; go.mod pins the version this relies on.
+func (d *fitzDocument) handles() (*C.fz_context, *C.fz_document, *sync.Mutex) {
+	fields := reflect.ValueOf(d.Document).Elem()
+	ctx := (*C.fz_context)(fields.FieldByName("ctx").UnsafePointer())
+	doc := (*C.fz_document)(fields.FieldByName("doc").UnsafePointer())
+	mu := (*sync.Mutex)(fields.FieldByName("mtx").Addr().UnsafePointer())
+	return ctx, doc, mu
+}
+
+func (d *fitzDocument) NeedsPassword() bool {
+	return d.locked
+}
+
+// Authenticate unlocks the document with password using fz_authenticate_password. A MuPDF error while
+// checking the password leaves the document locked.
+func (d *fitzDocument) Authenticate(password string) bool {
+	ctx, doc, mu := d.handles()
+	if ctx == nil || doc == nil {
+		return false
+	}
+	mu.Lock()
+	defer mu.Unlock()
+
+	cpassword := C.CString(password)
+	defer C.free(unsafe.Pointer(cpassword))
+	var message *C.char
+	if C.authenticate_password(ctx, doc, cpassword, &message) == 0 {
+		return false
+	}
+	d.locked = false
+	return true
+}
+
+// ImageDPI renders the whole page at dpi. It replaces go-fitz's version so the render can be interrupted.
+func (d *fitzDocument) ImageDPI(pageNumber int, dpi float64) (image.Image, error) {
+	return d.render(pageNumber, dpi, true, Rect{})
+}
+
+// RenderRegion renders only the part of the page inside region, given in points from the page's
+// top-left corner, so small crops can be drawn at high resolution without rasterising the whole page.
+func (d *fitzDocument) RenderRegion(pageNumber int, dpi float64, region Rect) (image.Image, error) {
+	return d.render(pageNumber, dpi, false, region)
+}
+
+func (d *fitzDocument) render(pageNumber int, dpi float64, whole bool, region Rect) (image.Image, error) {
+	if pageNumber < 0 || pageNumber >= d.NumPage() {
+		return nil, fitz.ErrPageMissing
+	}
+	ctx, doc, mu := d.handles()
+	mu.Lock()
+	defer mu.Unlock()
+	if d.interrupted() {
+		return nil, errInterrupted
+	}
+
+	var cwhole C.int
+	if whole {
+		cwhole = 1
+	}
+	var width, height C.int
+	var message *C.char
+	samples := C.render_region(ctx, doc, C.int(pageNumber), C.float(dpi/pointsPerInch), cwhole,
+		C.float(region.X), C.float(region.Y), C.float(region.X+region.W), C.float(region.Y+region.H),
+		d.cookie, &width, &height, &message)
+	if samples != nil {
+		defer C.free(unsafe.Pointer(samples))
+	}
+	if d.interrupted() {
+		return nil, errInterrupted
+	}
+	if message != nil {
+		return nil, fmt.Errorf("render page %d: %s", pageNumber+1, C.GoString(message))
+	}
+	if samples == nil {
+		return nil, fitz.ErrCreatePixmap
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+	copy(img.Pix, unsafe.Slice((*byte)(unsafe.Pointer(samples)), len(img.Pix)))
+	return img, nil
+}
+
+// Bound returns the page bounds truncated to whole points, like go-fitz's version, but reports MuPDF
+// errors instead of letting them abort the process.
+func (d *fitzDocument) Bound(pageNumber int) (image.Rectangle, error) {
+	bounds, err := d.bound(pageNumber)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	return image.Rect(int(bounds.x0), int(bounds.y0), int(bounds.x1), int(bounds.y1)), nil
+}
+
+// PageSize returns the page size in points. Unlike Bound it does not round to whole points.
+func (d *fitzDocument) PageSize(pageNumber int) (float64, float64, error) {
+	bounds, err := d.bound(pageNumber)
+	if err != nil {
+		return 0, 0, err
+	}
+	// MuPDF works in single precision; rounding to hundredths drops the float32 noise (595.280029...).
+	return roundPoints(float64(bounds.x1 - bounds.x0)), roundPoints(float64(bounds.y1 - bounds.y0)), nil
+}
+
+func (d *fitzDocument) bound(pageNumber int) (C.fz_rect, error) {
+	if pageNumber < 0 || pageNumber >= d.NumPage() {
+		return C.fz_rect{}, fitz.ErrPageMissing
+	}
+	ctx, doc, mu := d.handles()
+	mu.Lock()
+	defer mu.Unlock()
+
+	var bounds C.fz_rect
+	var message *C.char
+	if C.page_bound(ctx, doc, C.int(pageNumber), &bounds, &message) == 0 {
+		return C.fz_rect{}, fmt.Errorf("bound page %d: %s", pageNumber+1, C.GoString(message))
+	}
+	return bounds, nil
+}
+
+func roundPoints(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// metadataKeys maps Metadata keys to MuPDF lookup keys. go-fitz's own Metadata pads values with NULs,
+// truncates them at 256 bytes and misspells the modification date key, so lookups are done here.
+var metadataKeys = map[string]string{
+	"format":       "format",
+	"encryption":   "encryption",
+	"title":        "info:Title",
+	"author":       "info:Author",
+	"subject":      "info:Subject",
+	"keywords":     "info:Keywords",
+	"creator":      "info:Creator",
+	"producer":     "info:Producer",
+	"creationDate": "info:CreationDate",
+	"modDate":      "info:ModDate",
+}
+
+// Metadata returns the document information entries that are present. Entries MuPDF fails to read
+// are left out.
+func (d *fitzDocument) Metadata() map[string]string {
+	ctx, doc, mu := d.handles()
+	mu.Lock()
+	defer mu.Unlock()
+
+	data := make(map[string]string, len(metadataKeys))
+	for name, key := range metadataKeys {
+		ckey := C.CString(key)
+		var message *C.char
+		// The first call reports the required size including the terminating NUL, or -1 when absent.
+		size := C.lookup_metadata(ctx, doc, ckey, nil, 0, &message)
+		if size > 1 {
+			buf := make([]byte, int(size))
+			if C.lookup_metadata(ctx, doc, ckey, (*C.char)(unsafe.Pointer(&buf[0])), size, &message) >= 0 {
+				if value := strings.TrimRight(string(buf), "\x00"); value != "" {
+					data[name] = value
+				}
+			}
+		}
+		C.free(unsafe.Pointer(ckey))
+	}
+	return data
+}
+
+// Outline returns the document outline, or nil when the document has none. It replaces go-fitz's ToC,
+// which neither holds the document's mutex nor catches MuPDF errors.
+func (d *fitzDocument) Outline() ([]OutlineItem, error) {
+	ctx, doc, mu := d.handles()
+	mu.Lock()
+	defer mu.Unlock()
+
+	var message *C.char
+	outline := C.load_outline(ctx, doc, &message)
+	if message != nil {
+		return nil, fmt.Errorf("load outline: %s", C.GoString(message))
+	}
+	if outline == nil {
+		return nil, nil
+	}
+	defer C.fz_drop_outline(ctx, outline)
+
+	var items []OutlineItem
+	var walk func(entry *C.fz_outline, level int)
+	walk = func(entry *C.fz_outline, level int) {
+		for ; entry != nil; entry = entry.next {
+			item := OutlineItem{Level: level, Title: C.GoString(entry.title)}
+			if entry.page.page >= 0 {
+				item.Page = int(entry.page.page) + 1
+			}
+			if uri := C.GoString(entry.uri); !strings.HasPrefix(uri, "#") {
+				item.URI = uri
+			}
+			items = append(items, item)
+			walk(entry.down, level+1)
+		}
+	}
+	walk(outline, 1)
+	return items, nil
+}
+
+// stextPage mirrors the subset of MuPDF's structured text JSON used here. Coordinates are whole points.
+type stextPage struct {
+	Blocks []struct {
+		Type  string    `json:"type"`
+		BBox  stextRect `json:"bbox"`
+		Lines []struct {
+			BBox stextRect `json:"bbox"`
+			Text string    `json:"text"`
+		} `json:"lines"`
+	} `json:"blocks"`
+}
+
+type stextRect struct {
+	X, Y, W, H float64
+}
+
+// TextBlocks returns the text blocks on a page, in reading order as reported by MuPDF.
+func (d *fitzDocument) TextBlocks(pageNumber int) ([]TextBlock, error) {
+	raw, err := d.export(pageNumber, "extract structured text from page", func(ctx *C.fz_context, doc *C.fz_document, message **C.char) *C.char {
+		return C.stext_json(ctx, doc, C.int(pageNumber), d.cookie, message)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var page stextPage
+	if err := json.Unmarshal([]byte(raw), &page); err != nil {
+		return nil, fmt.Errorf("decode structured text: %w", err)
+	}
+
+	blocks := make([]TextBlock, 0, len(page.Blocks))
+	for _, b := range page.Blocks {
+		if b.Type != "text" {
+			continue
+		}
+		block := TextBlock{BBox: Rect(b.BBox)}
+		for _, l := range b.Lines {
+			block.Lines = append(block.Lines, TextLine{BBox: Rect(l.BBox), Text: l.Text})
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+// SVG returns the page as a standalone SVG document. It replaces go-fitz's version so MuPDF errors are
+// returned and the export can be interrupted.
+func (d *fitzDocument) SVG(pageNumber int) (string, error) {
+	return d.export(pageNumber, "export svg of page", func(ctx *C.fz_context, doc *C.fz_document, message **C.char) *C.char {
+		return C.page_svg(ctx, doc, C.int(pageNumber), d.cookie, message)
+	})
+}
+
+// HTML returns the page as HTML, wrapped in a complete document when header is set. It replaces
+// go-fitz's version so MuPDF errors are returned and the export can be interrupted.
+func (d *fitzDocument) HTML(pageNumber int, header bool) (string, error) {
+	var cheader C.int
+	if header {
+		cheader = 1
+	}
+	return d.export(pageNumber, "export html of page", func(ctx *C.fz_context, doc *C.fz_document, message **C.char) *C.char {
+		return C.page_html(ctx, doc, C.int(pageNumber), cheader, d.cookie, message)
+	})
+}
+
+// export runs a C helper that returns a malloc'd string for pageNumber under go-fitz's mutex, and
+// copies the string out. what prefixes MuPDF's message in the returned error.
+func (d *fitzDocument) export(pageNumber int, what string, run func(*C.fz_context, *C.fz_document, **C.char) *C.char) (string, error) {
+	if pageNumber < 0 || pageNumber >= d.NumPage() {
+		return "", fitz.ErrPageMissing
+	}
+	ctx, doc, mu := d.handles()
+	mu.Lock()
+	if d.interrupted() {
+		mu.Unlock()
+		return "", errInterrupted
+	}
+	var message *C.char
+	raw := run(ctx, doc, &message)
+	// The message belongs to the MuPDF context, so it is copied before another call can replace it.
+	var failure string
+	if message != nil {
+		failure = C.GoString(message)
+	}
+	mu.Unlock()
+	if raw != nil {
+		defer C.free(unsafe.Pointer(raw))
+	}
+	if d.interrupted() {
+		return "", errInterrupted
+	}
+	if raw == nil {
+		if failure == "" {
+			failure = "out of memory"
+		}
+		return "", fmt.Errorf("%s %d: %s", what, pageNumber+1, failure)
+	}
+	return C.GoString(raw), nil
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	fitz "github.com/gen2brain/go-fitz"
+)
+
+// brokenPageTreePDF claims two pages but lists one, so MuPDF throws when page 2 is loaded.
+const brokenPageTreePDF = "%PDF-1.4\n1 0 obj<< /Type /Catalog /Pages 2 0 R >>endobj\n" +
+	"2 0 obj<< /Type /Pages /Kids [3 0 R] /Count 2 >>endobj\n" +
+	"3 0 obj<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] >>endobj\n" +
+	"trailer<< /Root 1 0 R >>\n%%EOF\n"
+
+func TestFitzDocument_MuPDFErrorsAreReturned(t *testing.T) {
+	doc, err := openFitzDocument(writeTestPDF(t, brokenPageTreePDF))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer doc.Close()
+	fitzDoc := doc.(*fitzDocument)
+	if doc.NumPage() != 2 {
+		t.Fatalf("expected the page count from the page tree, got %d", doc.NumPage())
+	}
+
+	// Each call would abort the process if the MuPDF exception escaped.
+	const want = "cannot find page 2"
+	if _, _, err := fitzDoc.PageSize(1); err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("page size: expected %q, got %v", want, err)
+	}
+	if _, err := doc.ImageDPI(1, 72); err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("render: expected %q, got %v", want, err)
+	}
+	if _, err := fitzDoc.RenderRegion(1, 72, Rect{W: 10, H: 10}); err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("render region: expected %q, got %v", want, err)
+	}
+	if _, err := doc.TextBlocks(1); err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("text: expected %q, got %v", want, err)
+	}
+	if _, err := doc.Bound(1); err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("bound: expected %q, got %v", want, err)
+	}
+	for _, exporter := range []PageExporter{svgEncoder{}, htmlEncoder{}} {
+		if err := exporter.ExportPage(io.Discard, doc, 1); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s export: expected %q, got %v", exporter.Extension(), want, err)
+		}
+	}
+
+	// The document stays usable after a caught error.
+	if img, err := doc.ImageDPI(0, 72); err != nil || img.Bounds().Dx() != 200 {
+		t.Fatalf("expected page 1 to render after the failures, got %v", err)
+	}
+	if svg, err := doc.SVG(0); err != nil || !strings.Contains(svg, "<svg") {
+		t.Fatalf("expected page 1 to export as svg after the failures, got %v", err)
+	}
+}
+
+func TestExtractText_BrokenPage(t *testing.T) {
+	path := writeTestPDF(t, brokenPageTreePDF)
+	sel, _ := ParsePageSelector("2")
+	_, err := NewPDFService(Config{}).ExtractText(context.Background(), path, sel, "", false)
+	if err == nil || !strings.Contains(err.Error(), "cannot find page 2") {
+		t.Fatalf("expected the MuPDF error, got %v", err)
+	}
+}
+
+// handles reads these unexported go-fitz fields, so a dependency bump that renames or retypes them
+// must fail here rather than panic or corrupt memory at run time.
+func TestFitzDocument_HandleFields(t *testing.T) {
+	typ := reflect.TypeOf(fitz.Document{})
+	for name, want := range map[string]string{
+		"ctx": "*fitz._Ctype_struct_fz_context",
+		"doc": "*fitz._Ctype_struct_fz_document",
+		"mtx": "sync.Mutex",
+	} {
+		field, ok := typ.FieldByName(name)
+		if !ok {
+			t.Fatalf("fitz.Document has no field %s", name)
+		}
+		if got := field.Type.String(); got != want {
+			t.Fatalf("fitz.Document.%s: expected %s, got %s", name, want, got)
+		}
+	}
+}
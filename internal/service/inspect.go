@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OutlineItem is one table of contents entry. Page is 1-based and zero when the entry links outside
+// the document, in which case URI holds the target.
+type OutlineItem struct {
+	Level int    `json:"level"`
+	Title string `json:"title"`
+	Page  int    `json:"page,omitempty"`
+	URI   string `json:"uri,omitempty"`
+}
+
+// PageSize is the size of one page in points (1/72 inch). Page is 1-based.
+type PageSize struct {
+	Page   int     `json:"page"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// DocumentInfo describes a PDF without rendering it.
+type DocumentInfo struct {
+	PageCount int `json:"pageCount"`
+	// Format is the file format and version reported by MuPDF, e.g. "PDF 1.7".
+	Format string `json:"format,omitempty"`
+	// Encrypted reports whether the file uses a security handler, even one with an empty user password.
+	Encrypted  bool   `json:"encrypted"`
+	Encryption string `json:"encryption,omitempty"`
+	// NeedsPassword is set when the document is encrypted and no password was given. Only PageCount,
+	// Format and the encryption fields are filled in that case.
+	NeedsPassword bool          `json:"needsPassword"`
+	Title         string        `json:"title,omitempty"`
+	Author        string        `json:"author,omitempty"`
+	Subject       string        `json:"subject,omitempty"`
+	Keywords      string        `json:"keywords,omitempty"`
+	Creator       string        `json:"creator,omitempty"`
+	Producer      string        `json:"producer,omitempty"`
+	CreatedAt     *time.Time    `json:"createdAt,omitempty"`
+	ModifiedAt    *time.Time    `json:"modifiedAt,omitempty"`
+	Pages         []PageSize    `json:"pages,omitempty"`
+	Outline       []OutlineItem `json:"outline,omitempty"`
+}
+
+// Inspect reads the metadata, page sizes and outline of the PDF at pdfPath without rendering. Unlike
+// the conversion methods, an encrypted document opened without a password is not an error: the
+// result has NeedsPassword set instead. A wrong password still fails with ErrPDFBadPassword.
+func (s *PDFService) Inspect(ctx context.Context, pdfPath string, password string) (DocumentInfo, error) {
+	var info DocumentInfo
+	if err := ctx.Err(); err != nil {
+		return info, err
+	}
+
+	doc, err := s.open(pdfPath)
+	if err != nil {
+		return info, fmt.Errorf("open pdf: %w", err)
+	}
+	defer doc.Close()
+
+	info.PageCount = doc.NumPage()
+	meta := doc.Metadata()
+	info.Format = meta["format"]
+	if encryption := meta["encryption"]; encryption != "" && encryption != "None" {
+		info.Encrypted = true
+		info.Encryption = encryption
+	}
+
+	if locked, ok := doc.(lockedDocument); ok && locked.NeedsPassword() {
+		if password == "" {
+			info.NeedsPassword = true
+			return info, nil
+		}
+		if !locked.Authenticate(password) {
+			return DocumentInfo{}, ErrPDFBadPassword
+		}
+		// Information dictionary strings are encrypted, so read them again now the document is unlocked.
+		meta = doc.Metadata()
+	}
+
+	info.Title = meta["title"]
+	info.Author = meta["author"]
+	info.Subject = meta["subject"]
+	info.Keywords = meta["keywords"]
+	info.Creator = meta["creator"]
+	info.Producer = meta["producer"]
+	info.CreatedAt = parsePDFDate(meta["creationDate"])
+	info.ModifiedAt = parsePDFDate(meta["modDate"])
+
+	info.Pages = make([]PageSize, 0, info.PageCount)
+	for idx := 0; idx < info.PageCount; idx++ {
+		if err := ctx.Err(); err != nil {
+			return DocumentInfo{}, err
+		}
+		width, height, err := pageSize(doc, idx)
+		if err != nil {
+			return DocumentInfo{}, fmt.Errorf("bound page %d: %w", idx+1, err)
+		}
+		info.Pages = append(info.Pages, PageSize{Page: idx + 1, Width: width, Height: height})
+	}
+
+	if info.Outline, err = doc.Outline(); err != nil {
+		return DocumentInfo{}, fmt.Errorf("load outline: %w", err)
+	}
+	return info, nil
+}
+
+// pageSizer is implemented by documents that report page sizes in fractional points. Bound rounds
+// them to whole points, which turns A4 (595.28 x 841.89) into 595 x 842.
+type pageSizer interface {
+	PageSize(pageNumber int) (width, height float64, err error)
+}
+
+// pageSize returns the size of a page in points, falling back to Bound for documents without pageSizer.
+func pageSize(doc Document, pageNumber int) (float64, float64, error) {
+	if sizer, ok := doc.(pageSizer); ok {
+		return sizer.PageSize(pageNumber)
+	}
+	bounds, err := doc.Bound(pageNumber)
+	if err != nil {
+		return 0, 0, err
+	}
+	return float64(bounds.Dx()), float64(bounds.Dy()), nil
+}
+
+// parsePDFDate parses a PDF date string ("D:YYYYMMDDHHmmSSOHH'mm'", every field after the year
+// optional). It returns nil for empty or malformed values, which are common in real files.
+func parsePDFDate(raw string) *time.Time {
+	s := strings.TrimPrefix(strings.TrimSpace(raw), "D:")
+	if len(s) < 4 {
+		return nil
+	}
+
+	// Year, month, day, hour, minute and second, with the defaults used for omitted fields.
+	fields := []int{0, 1, 1, 0, 0, 0}
+	widths := []int{4, 2, 2, 2, 2, 2}
+	for i, width := range widths {
+		if len(s) < width || s[0] < '0' || s[0] > '9' {
+			if i == 0 {
+				return nil
+			}
+			break
+		}
+		v, err := strconv.Atoi(s[:width])
+		if err != nil {
+			return nil
+		}
+		fields[i] = v
+		s = s[width:]
+	}
+
+	loc := time.UTC
+	if s != "" && (s[0] == '+' || s[0] == '-') {
+		offset := strings.NewReplacer("'", "").Replace(s[1:])
+		hours, err := strconv.Atoi(offset[:min(2, len(offset))])
+		if err != nil {
+			return nil
+		}
+		minutes := 0
+		if len(offset) >= 4 {
+			if minutes, err = strconv.Atoi(offset[2:4]); err != nil {
+				return nil
+			}
+		}
+		seconds := hours*3600 + minutes*60
+		if s[0] == '-' {
+			seconds = -seconds
+		}
+		loc = time.FixedZone("", seconds)
+	}
+
+	t := time.Date(fields[0], time.Month(fields[1]), fields[2], fields[3], fields[4], fields[5], 0, loc)
+	if t.Month() != time.Month(fields[1]) || t.Day() != fields[2] {
+		return nil
+	}
+	return &t
+}
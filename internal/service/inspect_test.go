@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestInspect(t *testing.T) {
+	toc := []OutlineItem{{Level: 1, Title: "Intro", Page: 1}, {Level: 2, Title: "Details", Page: 2}}
+	restore := SetDocumentOpenerForTest(func(string) (Document, error) {
+		return &stubDocument{pages: 2, toc: toc, meta: map[string]string{
+			"format":       "PDF 1.7",
+			"encryption":   "None",
+			"title":        "Quarterly report",
+			"author":       "Finance",
+			"creationDate": "D:20240131093000+09'00'",
+			"modDate":      "garbage",
+		}}, nil
+	})
+	defer restore()
+
+	info, err := NewPDFService(Config{}).Inspect(context.Background(), "ignored", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.PageCount != 2 || len(info.Pages) != 2 || info.Pages[1] != (PageSize{Page: 2, Width: 612, Height: 792}) {
+		t.Fatalf("unexpected pages %+v", info)
+	}
+	if info.Format != "PDF 1.7" || info.Title != "Quarterly report" || info.Author != "Finance" {
+		t.Fatalf("unexpected metadata %+v", info)
+	}
+	if info.Encrypted || info.Encryption != "" || info.NeedsPassword {
+		t.Fatalf("expected unencrypted document, got %+v", info)
+	}
+	want := time.Date(2024, 1, 31, 0, 30, 0, 0, time.UTC)
+	if info.CreatedAt == nil || !info.CreatedAt.Equal(want) {
+		t.Fatalf("expected creation date %v, got %v", want, info.CreatedAt)
+	}
+	if info.ModifiedAt != nil {
+		t.Fatalf("expected malformed date to be dropped, got %v", info.ModifiedAt)
+	}
+	if len(info.Outline) != 2 || info.Outline[1].Title != "Details" {
+		t.Fatalf("unexpected outline %+v", info.Outline)
+	}
+}
+
+// sizedStubDocument reports fractional page sizes, as MuPDF does for A4.
+type sizedStubDocument struct {
+	stubDocument
+}
+
+func (s *sizedStubDocument) PageSize(pageNumber int) (float64, float64, error) {
+	return 595.28, 841.89, nil
+}
+
+func TestInspect_FractionalPageSizes(t *testing.T) {
+	restore := SetDocumentOpenerForTest(func(string) (Document, error) {
+		return &sizedStubDocument{stubDocument{pages: 1}}, nil
+	})
+	defer restore()
+
+	info, err := NewPDFService(Config{}).Inspect(context.Background(), "ignored", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(info.Pages) != 1 || info.Pages[0] != (PageSize{Page: 1, Width: 595.28, Height: 841.89}) {
+		t.Fatalf("expected unrounded page sizes, got %+v", info.Pages)
+	}
+}
+
+func TestInspect_Encrypted(t *testing.T) {
+	restore := SetDocumentOpenerForTest(func(string) (Document, error) {
+		return &lockedStubDocument{
+			stubDocument: stubDocument{pages: 3, meta: map[string]string{
+				"format":     "PDF 1.4",
+				"encryption": "Standard V1 R2 40-bit RC4",
+				"title":      "Secret",
+			}},
+			password: "s3cret",
+			locked:   true,
+		}, nil
+	})
+	defer restore()
+
+	svc := NewPDFService(Config{})
+	info, err := svc.Inspect(context.Background(), "ignored", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !info.Encrypted || !info.NeedsPassword || info.PageCount != 3 || info.Encryption == "" {
+		t.Fatalf("expected encryption status, got %+v", info)
+	}
+	if info.Title != "" || info.Pages != nil {
+		t.Fatalf("expected locked document details to be withheld, got %+v", info)
+	}
+
+	if _, err := svc.Inspect(context.Background(), "ignored", "wrong"); !errors.Is(err, ErrPDFBadPassword) {
+		t.Fatalf("expected ErrPDFBadPassword, got %v", err)
+	}
+
+	info, err = svc.Inspect(context.Background(), "ignored", "s3cret")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !info.Encrypted || info.NeedsPassword || info.Title != "Secret" || len(info.Pages) != 3 {
+		t.Fatalf("expected unlocked details, got %+v", info)
+	}
+}
+
+func TestParsePDFDate(t *testing.T) {
+	cases := []struct {
+		raw  string
+		want time.Time
+		ok   bool
+	}{
+		{raw: "D:20240131093000+09'00'", want: time.Date(2024, 1, 31, 0, 30, 0, 0, time.UTC), ok: true},
+		{raw: "D:20240131093000Z", want: time.Date(2024, 1, 31, 9, 30, 0, 0, time.UTC), ok: true},
+		{raw: "D:20240131093000-05'30", want: time.Date(2024, 1, 31, 15, 0, 0, 0, time.UTC), ok: true},
+		{raw: "D:2024", want: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), ok: true},
+		{raw: "20240229", want: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), ok: true},
+		{raw: ""},
+		{raw: "D:20230230"},
+		{raw: "yesterday"},
+	}
+	for _, tc := range cases {
+		got := parsePDFDate(tc.raw)
+		if !tc.ok {
+			if got != nil {
+				t.Fatalf("%q: expected nil, got %v", tc.raw, got)
+			}
+			continue
+		}
+		if got == nil || !got.Equal(tc.want) {
+			t.Fatalf("%q: expected %v, got %v", tc.raw, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// errInterrupted is returned by documents whose work was stopped by Interrupt. The service reports
+// the context error that caused the interruption instead.
+var errInterrupted = errors.New("render interrupted")
+
+// interruptibleDocument is implemented by documents whose MuPDF work can be stopped part-way through
+// a page, rather than only between pages.
+type interruptibleDocument interface {
+	// Interrupt aborts the work in progress and makes every later render or extraction fail. It is
+	// called from a different goroutine than the one rendering.
+	Interrupt()
+}
+
+// withPageBudget runs one page's MuPDF work under ctx and the configured per-page time budget. When
+// either ends first the document is interrupted and the context error is returned, so callers keep
+// reporting timeouts as context.DeadlineExceeded.
+func (s *PDFService) withPageBudget(ctx context.Context, doc Document, idx int, work func() error) error {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.PageTimeout)
+	defer cancel()
+	if in, ok := doc.(interruptibleDocument); ok {
+		stop := context.AfterFunc(ctx, in.Interrupt)
+		defer stop()
+	}
+
+	err := work()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("page %d: %w", idx+1, ctxErr)
+	}
+	return err
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"image"
+	"testing"
+	"time"
+)
+
+// hangingDocument renders nothing until it is interrupted, like MuPDF stuck on a pathological page.
+type hangingDocument struct {
+	stubDocument
+	interrupt chan struct{}
+}
+
+func newHangingDocument() *hangingDocument {
+	return &hangingDocument{stubDocument: stubDocument{pages: 2}, interrupt: make(chan struct{})}
+}
+
+func (d *hangingDocument) Interrupt() { close(d.interrupt) }
+
+func (d *hangingDocument) ImageDPI(pageNumber int, dpi float64) (image.Image, error) {
+	<-d.interrupt
+	return nil, errInterrupted
+}
+
+func (d *hangingDocument) TextBlocks(pageNumber int) ([]TextBlock, error) {
+	<-d.interrupt
+	return nil, errInterrupted
+}
+
+func TestStreamPages_PageTimeoutInterruptsRender(t *testing.T) {
+	restore := SetDocumentOpenerForTest(func(string) (Document, error) { return newHangingDocument(), nil })
+	defer restore()
+
+	svc := NewPDFService(Config{PageTimeout: 20 * time.Millisecond})
+	_, err := svc.ConvertPages(context.Background(), "ignored", FirstPage(), ConvertOptions{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestExtractText_CancelInterruptsExtraction(t *testing.T) {
+	restore := SetDocumentOpenerForTest(func(string) (Document, error) { return newHangingDocument(), nil })
+	defer restore()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	_, err := NewPDFService(Config{}).ExtractText(ctx, "ignored", AllPages(), "", false)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestRenderContactSheet_DeadlineInterruptsRender(t *testing.T) {
+	restore := SetDocumentOpenerForTest(func(string) (Document, error) { return newHangingDocument(), nil })
+	defer restore()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := NewPDFService(Config{}).RenderContactSheet(ctx, "ignored", AllPages(), ContactSheetOptions{}, ConvertOptions{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"pdf2jpg/internal/metrics"
+)
+
+// thumbnailFormat labels the pages rendered into contact sheets.
+const thumbnailFormat = "thumbnail"
+
+// renderRecorder counts rendered pages so tests can observe them without the Prometheus registry.
+type renderRecorder interface {
+	ObservePage(format string, d time.Duration)
+}
+
+var (
+	cacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Name:      "conversion_cache_total",
+		Help:      "Result cache lookups, by result (hit, miss or error).",
+	}, []string{"result"})
+	pageRenderDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Name:      "render_page_duration_seconds",
+		Help:      "Time MuPDF spent rendering one page, excluding encoding, by output format.",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"format"})
+	pagesRendered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Name:      "pages_rendered_total",
+		Help:      "Pages rendered, by output format. Pages served from the result cache are not counted.",
+	}, []string{"format"})
+)
+
+// promMetrics records into the process-wide collectors served on /metrics.
+type promMetrics struct{}
+
+func (promMetrics) IncCache(result string) {
+	cacheLookups.WithLabelValues(result).Inc()
+}
+
+func (promMetrics) ObservePage(format string, d time.Duration) {
+	pageRenderDuration.WithLabelValues(format).Observe(d.Seconds())
+	pagesRendered.WithLabelValues(format).Inc()
+}
+
+// encoderFormat is the metric label for pages produced by encoder, such as "jpg" or "svg".
+func encoderFormat(encoder Encoder) string {
+	return strings.TrimPrefix(encoder.Extension(), ".")
+}
+
+// renderPageTimed runs work under the page budget and records it as one page rendered for format.
+func (s *PDFService) renderPageTimed(ctx context.Context, doc Document, idx int, format string, work func() error) error {
+	start := time.Now()
+	if err := s.withPageBudget(ctx, doc, idx, work); err != nil {
+		return err
+	}
+	s.renderMetrics.ObservePage(format, time.Since(start))
+	return nil
+}
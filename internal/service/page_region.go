@@ -0,0 +1,184 @@
+package service
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	// trimPreviewDPI renders margin-detection previews at one pixel per point.
+	trimPreviewDPI = 72.0
+	// maxTrimPreviewSide caps the preview's longer side for very large pages.
+	maxTrimPreviewSide = 2000
+	// trimTolerance is how far below white a channel may fall and still count as margin, which
+	// absorbs scanner noise and off-white paper.
+	trimTolerance = 16
+)
+
+// regionRenderer is implemented by documents that can rasterise part of a page without drawing the rest.
+type regionRenderer interface {
+	// RenderRegion renders region, in points from the page's top-left corner, at dpi.
+	RenderRegion(pageNumber int, dpi float64, region Rect) (image.Image, error)
+}
+
+// ParseRotation parses a clockwise rotation of 0, 90, 180 or 270 degrees. An empty string selects 0.
+func ParseRotation(raw string) (int, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, nil
+	}
+	degrees, err := strconv.Atoi(raw)
+	if err != nil || !validRotation(degrees) {
+		return 0, fmt.Errorf("%w: rotation %q is not 0, 90, 180 or 270", ErrInvalidRenderOptions, raw)
+	}
+	return degrees, nil
+}
+
+func validRotation(degrees int) bool {
+	return degrees == 0 || degrees == 90 || degrees == 180 || degrees == 270
+}
+
+// ParseCrop parses an "x,y,w,h" crop rectangle. With relative set the values are fractions (0-1) of
+// the page width and height, otherwise points.
+func ParseCrop(raw string, relative bool) (Rect, error) {
+	parts := strings.Split(raw, ",")
+	if len(parts) != 4 {
+		return Rect{}, fmt.Errorf("%w: crop %q is not x,y,w,h", ErrInvalidRenderOptions, raw)
+	}
+	var values [4]float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return Rect{}, fmt.Errorf("%w: crop %q is not x,y,w,h", ErrInvalidRenderOptions, raw)
+		}
+		values[i] = v
+	}
+	crop := Rect{X: values[0], Y: values[1], W: values[2], H: values[3]}
+	if err := validateCrop(crop, relative); err != nil {
+		return Rect{}, err
+	}
+	return crop, nil
+}
+
+func validateCrop(crop Rect, relative bool) error {
+	if crop.X < 0 || crop.Y < 0 || crop.W <= 0 || crop.H <= 0 {
+		return fmt.Errorf("%w: crop needs a non-negative origin and a positive size", ErrInvalidRenderOptions)
+	}
+	if relative && (crop.X+crop.W > 1 || crop.Y+crop.H > 1) {
+		return fmt.Errorf("%w: relative crop extends past the page", ErrInvalidRenderOptions)
+	}
+	return nil
+}
+
+// pageRegion resolves opts.Crop against the page bounds, clipping it to the page. The result is in
+// points from the page's top-left corner; without a crop it covers the whole page.
+func pageRegion(bounds image.Rectangle, opts ConvertOptions) (Rect, error) {
+	pageW, pageH := float64(bounds.Dx()), float64(bounds.Dy())
+	crop := opts.Crop
+	if crop == (Rect{}) {
+		return Rect{W: pageW, H: pageH}, nil
+	}
+	if opts.CropRelative {
+		crop = Rect{X: crop.X * pageW, Y: crop.Y * pageH, W: crop.W * pageW, H: crop.H * pageH}
+	}
+
+	x1 := math.Min(crop.X+crop.W, pageW)
+	y1 := math.Min(crop.Y+crop.H, pageH)
+	if x1 <= crop.X || y1 <= crop.Y {
+		return Rect{}, fmt.Errorf("%w: crop lies outside the %vx%v page", ErrInvalidRenderOptions, pageW, pageH)
+	}
+	return Rect{X: crop.X, Y: crop.Y, W: x1 - crop.X, H: y1 - crop.Y}, nil
+}
+
+// trimRegion shrinks region to the content inside it, judged from a low-resolution preview. A blank
+// region is returned unchanged.
+func (s *PDFService) trimRegion(doc Document, idx int, bounds image.Rectangle, region Rect) (Rect, error) {
+	dpi := trimPreviewDPI
+	if longest := math.Max(region.W, region.H); longest*dpi/pointsPerInch > maxTrimPreviewSide {
+		dpi = maxTrimPreviewSide * pointsPerInch / longest
+	}
+	preview, err := s.drawRegion(doc, idx, bounds, region, dpi)
+	if err != nil {
+		return Rect{}, err
+	}
+	content, ok := contentBounds(preview)
+	if !ok {
+		return region, nil
+	}
+
+	// Keep one preview pixel either side so antialiased edges survive the higher resolution render.
+	pb := preview.Bounds()
+	scale := pointsPerInch / dpi
+	x0 := math.Max(region.X+float64(content.Min.X-pb.Min.X-1)*scale, region.X)
+	y0 := math.Max(region.Y+float64(content.Min.Y-pb.Min.Y-1)*scale, region.Y)
+	x1 := math.Min(region.X+float64(content.Max.X-pb.Min.X+1)*scale, region.X+region.W)
+	y1 := math.Min(region.Y+float64(content.Max.Y-pb.Min.Y+1)*scale, region.Y+region.H)
+	return Rect{X: x0, Y: y0, W: x1 - x0, H: y1 - y0}, nil
+}
+
+// drawRegion rasterises region of the page at dpi. Documents that cannot render regions draw the whole
+// page, which is then cropped, so the full page size is checked against the limits too.
+func (s *PDFService) drawRegion(doc Document, idx int, bounds image.Rectangle, region Rect, dpi float64) (image.Image, error) {
+	var img image.Image
+	var err error
+	renderer, ok := doc.(regionRenderer)
+	switch {
+	case region == Rect{W: float64(bounds.Dx()), H: float64(bounds.Dy())}:
+		img, err = doc.ImageDPI(idx, dpi)
+	case ok:
+		img, err = renderer.RenderRegion(idx, dpi, region)
+	default:
+		if err := s.cfg.checkSize(pixelsAt(float64(bounds.Dx()), dpi), pixelsAt(float64(bounds.Dy()), dpi)); err != nil {
+			return nil, err
+		}
+		if img, err = doc.ImageDPI(idx, dpi); err == nil {
+			img = cropPoints(img, region, dpi)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("render page %d: %w", idx+1, err)
+	}
+	return img, nil
+}
+
+// cropPoints cuts region, in points from the top-left corner, out of a full page rendered at dpi.
+func cropPoints(img image.Image, region Rect, dpi float64) image.Image {
+	b := img.Bounds()
+	scale := dpi / pointsPerInch
+	rect := image.Rect(
+		b.Min.X+int(math.Round(region.X*scale)),
+		b.Min.Y+int(math.Round(region.Y*scale)),
+		b.Min.X+int(math.Round((region.X+region.W)*scale)),
+		b.Min.Y+int(math.Round((region.Y+region.H)*scale)),
+	).Intersect(b)
+	if rect.Empty() {
+		return img
+	}
+	return toRGBA(img).SubImage(rect)
+}
+
+// contentBounds returns the smallest rectangle holding every pixel that is neither near-white nor
+// transparent, and false when there is none.
+func contentBounds(img image.Image) (image.Rectangle, bool) {
+	rgba := toRGBA(img)
+	b := rgba.Bounds()
+	minX, minY, maxX, maxY := b.Max.X, b.Max.Y, b.Min.X-1, b.Min.Y-1
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		offset := rgba.PixOffset(b.Min.X, y)
+		for x := b.Min.X; x < b.Max.X; x, offset = x+1, offset+4 {
+			p := rgba.Pix[offset : offset+4 : offset+4]
+			if p[3] <= trimTolerance || (p[0] >= 255-trimTolerance && p[1] >= 255-trimTolerance && p[2] >= 255-trimTolerance) {
+				continue
+			}
+			minX, maxX = min(minX, x), max(maxX, x)
+			minY, maxY = min(minY, y), max(maxY, y)
+		}
+	}
+	if maxX < minX {
+		return image.Rectangle{}, false
+	}
+	return image.Rect(minX, minY, maxX+1, maxY+1), true
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"testing"
+)
+
+// boxDocument renders a white 612x792pt page with a black box at (100,200)-(300,250) points.
+type boxDocument struct {
+	stubDocument
+	regions []Rect
+}
+
+func (d *boxDocument) ImageDPI(pageNumber int, dpi float64) (image.Image, error) {
+	scale := dpi / pointsPerInch
+	img := image.NewRGBA(image.Rect(0, 0, pixelsAt(612, dpi), pixelsAt(792, dpi)))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	box := image.Rect(int(100*scale), int(200*scale), int(300*scale), int(250*scale))
+	draw.Draw(img, box, image.Black, image.Point{}, draw.Src)
+	return img, nil
+}
+
+// regionBoxDocument also renders regions directly, recording each request.
+type regionBoxDocument struct {
+	boxDocument
+}
+
+func (d *regionBoxDocument) RenderRegion(pageNumber int, dpi float64, region Rect) (image.Image, error) {
+	d.regions = append(d.regions, region)
+	page, _ := d.ImageDPI(pageNumber, dpi)
+	return cropPoints(page, region, dpi), nil
+}
+
+func renderPNG(t *testing.T, doc Document, opts ConvertOptions) image.Image {
+	t.Helper()
+	restore := SetDocumentOpenerForTest(func(string) (Document, error) { return doc, nil })
+	defer restore()
+
+	opts.Format = FormatPNG
+	pages, err := NewPDFService(Config{}).ConvertPages(context.Background(), "ignored", FirstPage(), opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(pages[0].Data))
+	if err != nil {
+		t.Fatalf("decode png: %v", err)
+	}
+	return img
+}
+
+func TestConvertPages_Crop(t *testing.T) {
+	doc := &boxDocument{stubDocument: stubDocument{pages: 1}}
+	img := renderPNG(t, doc, ConvertOptions{DPI: 144, Crop: Rect{X: 100, Y: 200, W: 200, H: 50}})
+	if b := img.Bounds(); b.Dx() != 400 || b.Dy() != 100 {
+		t.Fatalf("expected 400x100, got %v", b)
+	}
+	if r, _, _, _ := img.At(200, 50).RGBA(); r != 0 {
+		t.Fatalf("expected the cropped box to be black, got %v", img.At(200, 50))
+	}
+
+	img = renderPNG(t, doc, ConvertOptions{Width: 306, Crop: Rect{W: 0.5, H: 0.25}, CropRelative: true})
+	if b := img.Bounds(); b.Dx() != 306 || b.Dy() != 198 {
+		t.Fatalf("expected 306x198 for the top-left quarter, got %v", b)
+	}
+}
+
+func TestConvertPages_CropUsesRegionRenderer(t *testing.T) {
+	doc := &regionBoxDocument{boxDocument{stubDocument: stubDocument{pages: 1}}}
+	renderPNG(t, doc, ConvertOptions{DPI: 72, Crop: Rect{X: 500, Y: 700, W: 500, H: 500}})
+	if len(doc.regions) != 1 || doc.regions[0] != (Rect{X: 500, Y: 700, W: 112, H: 92}) {
+		t.Fatalf("expected one region clipped to the page, got %+v", doc.regions)
+	}
+}
+
+func TestConvertPages_Trim(t *testing.T) {
+	doc := &boxDocument{stubDocument: stubDocument{pages: 1}}
+	img := renderPNG(t, doc, ConvertOptions{DPI: 72, Trim: true})
+	// The box is 200x50 points, plus one preview pixel of margin either side.
+	if b := img.Bounds(); b.Dx() != 202 || b.Dy() != 52 {
+		t.Fatalf("expected 202x52, got %v", b)
+	}
+	if r, _, _, _ := img.At(0, 0).RGBA(); r>>8 != 255 {
+		t.Fatalf("expected a white margin pixel, got %v", img.At(0, 0))
+	}
+	if r, _, _, _ := img.At(1, 1).RGBA(); r != 0 {
+		t.Fatalf("expected the box to start after the margin, got %v", img.At(1, 1))
+	}
+}
+
+func TestConvertPages_Rotate(t *testing.T) {
+	doc := &boxDocument{stubDocument: stubDocument{pages: 1}}
+	img := renderPNG(t, doc, ConvertOptions{DPI: 72, Rotate: 90})
+	if b := img.Bounds(); b.Dx() != 792 || b.Dy() != 612 {
+		t.Fatalf("expected 792x612, got %v", b)
+	}
+	// Point (100, 200) on the page lands at (792-1-200, 100) after a clockwise quarter turn.
+	if r, _, _, _ := img.At(591, 100).RGBA(); r != 0 {
+		t.Fatalf("expected the box corner to move, got %v", img.At(591, 100))
+	}
+
+	img = renderPNG(t, doc, ConvertOptions{Width: 100, Crop: Rect{X: 100, Y: 200, W: 200, H: 50}, Rotate: 270})
+	if b := img.Bounds(); b.Dx() != 100 || b.Dy() != 400 {
+		t.Fatalf("expected sizing to apply after rotation, got %v", b)
+	}
+}
+
+func TestConvertPages_RegionErrors(t *testing.T) {
+	restore := SetDocumentOpenerForTest(func(string) (Document, error) {
+		return &boxDocument{stubDocument: stubDocument{pages: 1}}, nil
+	})
+	defer restore()
+
+	svc := NewPDFService(Config{})
+	cases := []ConvertOptions{
+		{Crop: Rect{X: 700, Y: 0, W: 10, H: 10}},
+		{Crop: Rect{X: 0.5, Y: 0, W: 0.6, H: 1}, CropRelative: true},
+		{Rotate: 45},
+		{Rotate: 90, Format: FormatSVG},
+	}
+	for _, opts := range cases {
+		if _, err := svc.ConvertPages(context.Background(), "ignored", FirstPage(), opts); !errors.Is(err, ErrInvalidRenderOptions) {
+			t.Fatalf("%+v: expected ErrInvalidRenderOptions, got %v", opts, err)
+		}
+	}
+}
+
+func TestParseCrop(t *testing.T) {
+	crop, err := ParseCrop(" 10, 20.5,100,50 ", false)
+	if err != nil || crop != (Rect{X: 10, Y: 20.5, W: 100, H: 50}) {
+		t.Fatalf("unexpected crop %+v (%v)", crop, err)
+	}
+	if _, err := ParseCrop("0.25,0.25,0.5,0.5", true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, tc := range []struct {
+		raw      string
+		relative bool
+	}{
+		{raw: "1,2,3"},
+		{raw: "a,b,c,d"},
+		{raw: "0,0,0,10"},
+		{raw: "-1,0,10,10"},
+		{raw: "0,0,NaN,10"},
+		{raw: "0.5,0,0.6,1", relative: true},
+	} {
+		if _, err := ParseCrop(tc.raw, tc.relative); !errors.Is(err, ErrInvalidRenderOptions) {
+			t.Fatalf("%q: expected ErrInvalidRenderOptions, got %v", tc.raw, err)
+		}
+	}
+}
+
+func TestParseRotation(t *testing.T) {
+	for raw, want := range map[string]int{"": 0, "90": 90, " 180 ": 180, "270": 270} {
+		if got, err := ParseRotation(raw); err != nil || got != want {
+			t.Fatalf("%q: expected %d, got %d (%v)", raw, want, got, err)
+		}
+	}
+	for _, raw := range []string{"45", "-90", "360", "right"} {
+		if _, err := ParseRotation(raw); !errors.Is(err, ErrInvalidRenderOptions) {
+			t.Fatalf("%q: expected ErrInvalidRenderOptions, got %v", raw, err)
+		}
+	}
+}
+
+func TestRotateImage(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	red := color.RGBA{R: 255, A: 255}
+	src.Set(0, 0, red)
+
+	cases := map[int]image.Point{90: {X: 1, Y: 0}, 180: {X: 2, Y: 1}, 270: {X: 0, Y: 2}}
+	for degrees, want := range cases {
+		dst := rotateImage(src, degrees)
+		if degrees != 180 && (dst.Bounds().Dx() != 2 || dst.Bounds().Dy() != 3) {
+			t.Fatalf("%d: expected 2x3, got %v", degrees, dst.Bounds())
+		}
+		if dst.At(want.X, want.Y) != red {
+			t.Fatalf("%d: expected red at %v", degrees, want)
+		}
+	}
+}
+
+func TestContentBounds(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	if _, ok := contentBounds(img); ok {
+		t.Fatal("expected a blank image to have no content")
+	}
+	img.Set(2, 3, color.RGBA{R: 250, G: 250, B: 250, A: 255})
+	if _, ok := contentBounds(img); ok {
+		t.Fatal("expected near-white noise to be ignored")
+	}
+	img.Set(2, 3, color.Black)
+	img.Set(6, 7, color.RGBA{B: 200, A: 255})
+	if got, ok := contentBounds(img); !ok || got != image.Rect(2, 3, 7, 8) {
+		t.Fatalf("expected (2,3)-(7,8), got %v", got)
+	}
+}
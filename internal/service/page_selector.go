@@ -0,0 +1,203 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidPageSelector is returned when a page expression cannot be parsed.
+	ErrInvalidPageSelector = errors.New("invalid page selector")
+	// ErrPageOutOfRange is returned when a selection refers to a page the PDF does not contain.
+	ErrPageOutOfRange = errors.New("page out of range")
+	// ErrTooManyPages is returned, together with ErrRenderLimitExceeded, when a selection names more
+	// pages than Config.MaxPages allows.
+	ErrTooManyPages = errors.New("too many pages selected")
+)
+
+const (
+	lastPageKeyword = "last"
+	defaultMaxPages = 1000
+)
+
+// pageRef identifies a page by 1-based position. Negative values count from the end, so -1 is the last page.
+type pageRef int
+
+type pageRange struct {
+	start pageRef
+	end   pageRef
+}
+
+// PageSelector describes which pages of a document should be rendered.
+// The zero value selects the first page only.
+type PageSelector struct {
+	ranges []pageRange
+}
+
+// FirstPage returns a selector for page one.
+func FirstPage() PageSelector {
+	return PageSelector{}
+}
+
+// AllPages returns a selector for every page in document order.
+func AllPages() PageSelector {
+	return PageSelector{ranges: []pageRange{{start: 1, end: -1}}}
+}
+
+// ParsePageSelector parses expressions such as "3", "2-5,9", "-1", "last" or "-3--1".
+// Pages are 1-based; negative numbers count from the end. Descending ranges render in reverse order.
+// An empty expression selects the first page.
+func ParsePageSelector(expr string) (PageSelector, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return FirstPage(), nil
+	}
+
+	var sel PageSelector
+	for _, term := range strings.Split(expr, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			return PageSelector{}, fmt.Errorf("%w: empty term in %q", ErrInvalidPageSelector, expr)
+		}
+
+		startRaw, endRaw, isRange := splitRangeTerm(term)
+		start, err := parsePageRef(startRaw)
+		if err != nil {
+			return PageSelector{}, err
+		}
+		end := start
+		if isRange {
+			if end, err = parsePageRef(endRaw); err != nil {
+				return PageSelector{}, err
+			}
+		}
+		sel.ranges = append(sel.ranges, pageRange{start: start, end: end})
+	}
+	return sel, nil
+}
+
+// splitRangeTerm splits "a-b" while keeping the sign of negative operands, e.g. "-3--1" -> "-3", "-1".
+func splitRangeTerm(term string) (string, string, bool) {
+	for i := 1; i < len(term); i++ {
+		if term[i] != '-' {
+			continue
+		}
+		prev := term[i-1]
+		if (prev >= '0' && prev <= '9') || prev == ' ' || strings.HasSuffix(strings.ToLower(term[:i]), lastPageKeyword) {
+			return strings.TrimSpace(term[:i]), strings.TrimSpace(term[i+1:]), true
+		}
+	}
+	return term, "", false
+}
+
+func parsePageRef(raw string) (pageRef, error) {
+	if strings.EqualFold(raw, lastPageKeyword) {
+		return -1, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidPageSelector, raw)
+	}
+	return pageRef(n), nil
+}
+
+// IsSingle reports whether the selector can only ever resolve to one page.
+func (s PageSelector) IsSingle() bool {
+	if len(s.ranges) == 0 {
+		return true
+	}
+	return len(s.ranges) == 1 && s.ranges[0].start == s.ranges[0].end
+}
+
+// Resolve converts the selector into 0-based page indexes for a document with pageCount pages. A page
+// named more than once is kept only where it first appears. Selections naming more than maxPages
+// pages, counting repeats, fail with ErrTooManyPages; zero or less means no limit.
+func (s PageSelector) Resolve(pageCount, maxPages int) ([]int, error) {
+	pages, more, err := s.resolve(pageCount, maxPages)
+	if err != nil {
+		return nil, err
+	}
+	if more {
+		return nil, fmt.Errorf("%w: %w: limit %d", ErrTooManyPages, ErrRenderLimitExceeded, maxPages)
+	}
+	return pages, nil
+}
+
+// resolve expands the selector, dropping repeated pages. Once the expression has named limit pages,
+// counting repeats, it stops and reports whether any were left, so large or repetitive expressions
+// are never expanded in full. Every range is still checked against pageCount.
+func (s PageSelector) resolve(pageCount, limit int) ([]int, bool, error) {
+	if pageCount <= 0 {
+		return nil, false, ErrPDFHasNoPages
+	}
+	if len(s.ranges) == 0 {
+		return []int{0}, false, nil
+	}
+
+	bounds := make([][2]int, len(s.ranges))
+	for i, r := range s.ranges {
+		start, err := r.start.index(pageCount)
+		if err != nil {
+			return nil, false, err
+		}
+		end, err := r.end.index(pageCount)
+		if err != nil {
+			return nil, false, err
+		}
+		bounds[i] = [2]int{start, end}
+	}
+
+	var pages []int
+	seen := make(map[int]bool)
+	named := 0
+	for _, b := range bounds {
+		start, end := b[0], b[1]
+		step := 1
+		if end < start {
+			step = -1
+		}
+		for p := start; ; p += step {
+			if limit > 0 && named == limit {
+				return pages, true, nil
+			}
+			named++
+			if !seen[p] {
+				seen[p] = true
+				pages = append(pages, p)
+			}
+			if p == end {
+				break
+			}
+		}
+	}
+	return pages, false, nil
+}
+
+// String returns the normalised form of the selector.
+func (s PageSelector) String() string {
+	if len(s.ranges) == 0 {
+		return "1"
+	}
+	parts := make([]string, 0, len(s.ranges))
+	for _, r := range s.ranges {
+		if r.start == r.end {
+			parts = append(parts, strconv.Itoa(int(r.start)))
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%d-%d", r.start, r.end))
+	}
+	return strings.Join(parts, ",")
+}
+
+func (p pageRef) index(pageCount int) (int, error) {
+	idx := int(p) - 1
+	if p < 0 {
+		idx = pageCount + int(p)
+	}
+	if idx < 0 || idx >= pageCount {
+		return 0, fmt.Errorf("%w: page %d of %d", ErrPageOutOfRange, p, pageCount)
+	}
+	return idx, nil
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParsePageSelector_Resolve(t *testing.T) {
+	cases := []struct {
+		expr string
+		want []int
+	}{
+		{expr: "", want: []int{0}},
+		{expr: "3", want: []int{2}},
+		{expr: "last", want: []int{9}},
+		{expr: "-1", want: []int{9}},
+		{expr: "2-5,9", want: []int{1, 2, 3, 4, 8}},
+		{expr: "-3--1", want: []int{7, 8, 9}},
+		{expr: "8-last", want: []int{7, 8, 9}},
+		{expr: "3-1", want: []int{2, 1, 0}},
+		{expr: " 1 , LAST ", want: []int{0, 9}},
+		{expr: "3,1-4,2", want: []int{2, 0, 1, 3}},
+	}
+
+	for _, tc := range cases {
+		sel, err := ParsePageSelector(tc.expr)
+		if err != nil {
+			t.Fatalf("ParsePageSelector(%q): unexpected error: %v", tc.expr, err)
+		}
+		got, err := sel.Resolve(10, 0)
+		if err != nil {
+			t.Fatalf("Resolve(%q): unexpected error: %v", tc.expr, err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("Resolve(%q): expected %v, got %v", tc.expr, tc.want, got)
+		}
+	}
+}
+
+func TestParsePageSelector_Invalid(t *testing.T) {
+	for _, expr := range []string{"0", "a", "1,,2", "1-", "1-b", "2-0"} {
+		if _, err := ParsePageSelector(expr); !errors.Is(err, ErrInvalidPageSelector) {
+			t.Fatalf("ParsePageSelector(%q): expected ErrInvalidPageSelector, got %v", expr, err)
+		}
+	}
+}
+
+func TestPageSelector_OutOfRange(t *testing.T) {
+	for _, expr := range []string{"4", "-4", "2-4"} {
+		sel, err := ParsePageSelector(expr)
+		if err != nil {
+			t.Fatalf("ParsePageSelector(%q): unexpected error: %v", expr, err)
+		}
+		if _, err := sel.Resolve(3, 0); !errors.Is(err, ErrPageOutOfRange) {
+			t.Fatalf("Resolve(%q): expected ErrPageOutOfRange, got %v", expr, err)
+		}
+	}
+}
+
+func TestPageSelector_MaxPages(t *testing.T) {
+	sel, _ := ParsePageSelector("1-3,2-3")
+	if _, err := sel.Resolve(10, 4); !errors.Is(err, ErrTooManyPages) || !errors.Is(err, ErrRenderLimitExceeded) {
+		t.Fatalf("expected repeats to count towards the limit, got %v", err)
+	}
+	if got, err := sel.Resolve(10, 5); err != nil || !reflect.DeepEqual(got, []int{0, 1, 2}) {
+		t.Fatalf("expected pages within the limit, got %v (%v)", got, err)
+	}
+
+	// The contact sheet truncates instead, but still checks every range.
+	if got, more, err := sel.resolve(10, 2); err != nil || !more || !reflect.DeepEqual(got, []int{0, 1}) {
+		t.Fatalf("expected the first two pages, got %v %v (%v)", got, more, err)
+	}
+	sel, _ = ParsePageSelector("1-3,20")
+	if _, _, err := sel.resolve(10, 2); !errors.Is(err, ErrPageOutOfRange) {
+		t.Fatalf("expected ErrPageOutOfRange, got %v", err)
+	}
+}
+
+func TestPageSelector_IsSingle(t *testing.T) {
+	cases := map[string]bool{
+		"":       true,
+		"3":      true,
+		"last":   true,
+		"2-2":    true,
+		"2-5":    false,
+		"1,2":    false,
+		"1-last": false,
+	}
+	for expr, want := range cases {
+		sel, err := ParsePageSelector(expr)
+		if err != nil {
+			t.Fatalf("ParsePageSelector(%q): unexpected error: %v", expr, err)
+		}
+		if sel.IsSingle() != want {
+			t.Fatalf("IsSingle(%q): expected %v", expr, want)
+		}
+	}
+}
@@ -6,67 +6,350 @@ import (
 	"errors"
 	"fmt"
 	"image"
-	"image/jpeg"
-
-	fitz "github.com/gen2brain/go-fitz"
+	"io"
 )
 
 var (
 	// ErrPDFHasNoPages is returned when the PDF contains no pages.
 	ErrPDFHasNoPages = errors.New("pdf has no pages")
+	// ErrPDFEncrypted is returned when the PDF needs a password and none was given.
+	ErrPDFEncrypted = errors.New("pdf is encrypted")
+	// ErrPDFBadPassword is returned when the given password does not unlock the PDF.
+	ErrPDFBadPassword = errors.New("incorrect pdf password")
 )
 
-// PDFService performs PDF to JPEG conversions using go-fitz.
+// Document is the subset of go-fitz document behaviour used by PDFService.
 type Document interface {
 	NumPage() int
-	Image(pageNumber int) (image.Image, error)
+	// ImageDPI renders the page at the given resolution.
+	ImageDPI(pageNumber int, dpi float64) (image.Image, error)
+	// Bound returns the page size in points (1/72 inch).
+	Bound(pageNumber int) (image.Rectangle, error)
+	// TextBlocks returns the page's text grouped into blocks and lines with their bounding boxes.
+	TextBlocks(pageNumber int) ([]TextBlock, error)
+	// SVG returns the page as a standalone SVG document.
+	SVG(pageNumber int) (string, error)
+	// HTML returns the page as HTML, wrapped in a complete document when header is set.
+	HTML(pageNumber int, header bool) (string, error)
+	// Metadata returns the document information entries that are present, keyed by format,
+	// encryption, title, author, subject, keywords, creator, producer, creationDate and modDate.
+	Metadata() map[string]string
+	// Outline returns the table of contents, or nil when the document has none.
+	Outline() ([]OutlineItem, error)
 	Close() error
 }
 
-var openDocument = func(path string) (Document, error) {
-	return fitz.New(path)
+// lockedDocument is implemented by documents that may be encrypted.
+type lockedDocument interface {
+	NeedsPassword() bool
+	// Authenticate reports whether password unlocked the document.
+	Authenticate(password string) bool
+}
+
+var openDocument = openFitzDocument
+
+// open opens the document at pdfPath in a sandboxed worker when a pool is configured, and
+// in-process otherwise.
+func (s *PDFService) open(pdfPath string) (Document, error) {
+	if s.cfg.Workers != nil {
+		return s.cfg.Workers.Open(pdfPath)
+	}
+	return openDocument(pdfPath)
+}
+
+// openPDF opens the PDF at pdfPath and unlocks it with password when it is encrypted. Passwords given
+// for unencrypted documents are ignored.
+func (s *PDFService) openPDF(pdfPath, password string) (Document, error) {
+	doc, err := s.open(pdfPath)
+	if err != nil {
+		return nil, fmt.Errorf("open pdf: %w", err)
+	}
+
+	locked, ok := doc.(lockedDocument)
+	if !ok || !locked.NeedsPassword() {
+		return doc, nil
+	}
+	if password == "" {
+		doc.Close()
+		return nil, ErrPDFEncrypted
+	}
+	if !locked.Authenticate(password) {
+		doc.Close()
+		return nil, ErrPDFBadPassword
+	}
+	return doc, nil
 }
 
+// PDFService renders PDF pages with go-fitz, in-process or in sandboxed workers, and encodes them
+// with the registered encoders.
 type PDFService struct {
-	jpegQuality int
+	cfg           Config
+	cacheMetrics  cacheRecorder
+	renderMetrics renderRecorder
+}
+
+// NewPDFService constructs a new service. Zero fields in cfg fall back to built-in defaults.
+func NewPDFService(cfg Config) *PDFService {
+	svc := &PDFService{
+		cfg:           cfg.withDefaults(),
+		renderMetrics: promMetrics{},
+	}
+	if cfg.Cache != nil {
+		svc.cacheMetrics = promMetrics{}
+	}
+	return svc
 }
 
-// NewPDFService constructs a new service configured with the provided JPEG quality.
-func NewPDFService(quality int) *PDFService {
-	return &PDFService{
-		jpegQuality: quality,
+// PageImage is a single rendered page. Page is 1-based.
+type PageImage struct {
+	Page int
+	Data []byte
+}
+
+// ConvertFirstPage renders the first page of the PDF at pdfPath as JPEG into w.
+func (s *PDFService) ConvertFirstPage(ctx context.Context, pdfPath string, w io.Writer) error {
+	return s.StreamPages(ctx, pdfPath, FirstPage(), ConvertOptions{}, func(int) (io.Writer, error) {
+		return w, nil
+	})
+}
+
+// PageWriterFunc returns the destination for the next rendered page. page is 1-based. It is only
+// called once the page has rendered, so a sink can defer side effects such as response headers until
+// then.
+type PageWriterFunc func(page int) (io.Writer, error)
+
+// ConvertPages renders the pages chosen by sel to encoded bytes, in selection order. It holds every
+// encoded page in memory; servers should prefer StreamPages.
+func (s *PDFService) ConvertPages(ctx context.Context, pdfPath string, sel PageSelector, opts ConvertOptions) ([]PageImage, error) {
+	var results []PageImage
+	var buffers []*bytes.Buffer
+	err := s.StreamPages(ctx, pdfPath, sel, opts, func(page int) (io.Writer, error) {
+		buf := &bytes.Buffer{}
+		results = append(results, PageImage{Page: page})
+		buffers = append(buffers, buf)
+		return buf, nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	for i, buf := range buffers {
+		results[i].Data = buf.Bytes()
+	}
+	return results, nil
 }
 
-// ConvertFirstPage renders the first page of the PDF at pdfPath to JPEG bytes.
-func (s *PDFService) ConvertFirstPage(ctx context.Context, pdfPath string) ([]byte, error) {
+// StreamPages renders the pages chosen by sel one at a time and encodes each page into the writer
+// returned by next, so callers never hold more than one page in memory. Rendering stops part-way
+// through a page when ctx ends or the page exceeds Config.PageTimeout. With Config.Cache set, pages
+// rendered before are served from the cache, but the document is still opened and unlocked first.
+func (s *PDFService) StreamPages(ctx context.Context, pdfPath string, sel PageSelector, opts ConvertOptions, next PageWriterFunc) error {
 	select {
 	case <-ctx.Done():
-		return nil, ctx.Err()
+		return ctx.Err()
 	default:
 	}
 
-	doc, err := openDocument(pdfPath)
+	if err := s.cfg.validateOptions(opts); err != nil {
+		return err
+	}
+	encoder, err := LookupEncoder(opts.Format)
 	if err != nil {
-		return nil, fmt.Errorf("open pdf: %w", err)
+		return err
+	}
+	if _, vector := encoder.(PageExporter); vector && (opts.Crop != (Rect{}) || opts.Trim || opts.Rotate != 0) {
+		return fmt.Errorf("%w: crop, trim and rotate need a raster format", ErrInvalidRenderOptions)
+	}
+
+	doc, err := s.openPDF(pdfPath, opts.Password)
+	if err != nil {
+		return err
 	}
 	defer doc.Close()
 
 	if doc.NumPage() == 0 {
-		return nil, ErrPDFHasNoPages
+		return ErrPDFHasNoPages
+	}
+
+	indexes, err := sel.Resolve(doc.NumPage(), s.cfg.MaxPages)
+	if err != nil {
+		return err
+	}
+
+	cache, err := s.newPageCache(pdfPath, opts)
+	if err != nil {
+		return err
+	}
+
+	for _, idx := range indexes {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		pageNext := next
+		if cache != nil {
+			hit, err := cache.serve(idx, next)
+			if err != nil {
+				return err
+			}
+			if hit {
+				continue
+			}
+			pageNext = cache.record(next)
+		}
+
+		if err := s.streamPage(ctx, doc, idx, encoder, opts, pageNext); err != nil {
+			return err
+		}
+		if cache != nil {
+			cache.save(idx)
+		}
+	}
+
+	return nil
+}
+
+// streamPage renders and encodes one page into the writer returned by next.
+func (s *PDFService) streamPage(ctx context.Context, doc Document, idx int, encoder Encoder, opts ConvertOptions, next PageWriterFunc) error {
+	if exporter, ok := encoder.(PageExporter); ok {
+		return s.exportPage(ctx, next, exporter, doc, idx, encoderFormat(encoder), opts.MaxBytes)
 	}
 
-	image, err := doc.Image(0)
+	var img image.Image
+	err := s.renderPageTimed(ctx, doc, idx, encoderFormat(encoder), func() (err error) {
+		img, err = s.renderPage(doc, idx, opts)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("render image: %w", err)
+		return err
 	}
 
+	w, err := next(idx + 1)
+	if err != nil {
+		return fmt.Errorf("open page %d writer: %w", idx+1, err)
+	}
+	if err := s.encodePage(w, encoder, img, opts); err != nil {
+		return fmt.Errorf("encode page %d: %w", idx+1, err)
+	}
+	return nil
+}
+
+func (s *PDFService) encodePage(w io.Writer, encoder Encoder, img image.Image, opts ConvertOptions) error {
+	if opts.Grayscale {
+		img = toGray(img)
+	}
+	encodeOpts := EncodeOptions{Quality: s.cfg.clampQuality(opts.Quality), Chroma: opts.Chroma}
+	if opts.MaxBytes <= 0 {
+		return encoder.Encode(w, img, encodeOpts)
+	}
+
+	data, err := encodeWithin(encoder, img, encodeOpts, s.cfg.MinQuality, opts.MaxBytes)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// exportPage writes a vector page. Vector output cannot be shrunk, so a page over maxBytes fails.
+func (s *PDFService) exportPage(ctx context.Context, next PageWriterFunc, exporter PageExporter, doc Document, idx int, format string, maxBytes int) error {
 	var buf bytes.Buffer
-	if err := jpeg.Encode(&buf, image, &jpeg.Options{Quality: s.jpegQuality}); err != nil {
-		return nil, fmt.Errorf("encode jpeg: %w", err)
+	err := s.renderPageTimed(ctx, doc, idx, format, func() error {
+		if err := exporter.ExportPage(&buf, doc, idx); err != nil {
+			return fmt.Errorf("export page %d: %w", idx+1, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if maxBytes > 0 && buf.Len() > maxBytes {
+		return fmt.Errorf("encode page %d: %w: %d bytes, limit %d", idx+1, ErrOutputTooLarge, buf.Len(), maxBytes)
+	}
+
+	w, err := next(idx + 1)
+	if err != nil {
+		return fmt.Errorf("open page %d writer: %w", idx+1, err)
+	}
+	if _, err := buf.WriteTo(w); err != nil {
+		return fmt.Errorf("write page %d: %w", idx+1, err)
+	}
+	return nil
+}
+
+// CountPages returns how many pages sel resolves to for the PDF at pdfPath, without rendering them.
+func (s *PDFService) CountPages(ctx context.Context, pdfPath string, sel PageSelector, password string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
 	}
 
-	return buf.Bytes(), nil
+	doc, err := s.openPDF(pdfPath, password)
+	if err != nil {
+		return 0, err
+	}
+	defer doc.Close()
+
+	indexes, err := sel.Resolve(doc.NumPage(), s.cfg.MaxPages)
+	if err != nil {
+		return 0, err
+	}
+	return len(indexes), nil
+}
+
+// pageLayout is the resolved geometry for rendering one page.
+type pageLayout struct {
+	bounds image.Rectangle
+	// region is the cropped and trimmed area in points from the page's top-left corner.
+	region Rect
+	rotate int
+	plan   renderPlan
+}
+
+// layoutPage resolves crop, trim and rotation for a page and plans the render size of the result.
+func (s *PDFService) layoutPage(doc Document, idx int, opts ConvertOptions) (pageLayout, error) {
+	bounds, err := doc.Bound(idx)
+	if err != nil {
+		return pageLayout{}, fmt.Errorf("bound page %d: %w", idx+1, err)
+	}
+	if bounds.Empty() {
+		return pageLayout{}, fmt.Errorf("invalid page bounds %v", bounds)
+	}
+
+	region, err := pageRegion(bounds, opts)
+	if err != nil {
+		return pageLayout{}, err
+	}
+	if opts.Trim {
+		if region, err = s.trimRegion(doc, idx, bounds, region); err != nil {
+			return pageLayout{}, err
+		}
+	}
+
+	width, height := region.W, region.H
+	if opts.Rotate == 90 || opts.Rotate == 270 {
+		width, height = height, width
+	}
+	plan, err := s.cfg.planRender(width, height, opts)
+	if err != nil {
+		return pageLayout{}, err
+	}
+	return pageLayout{bounds: bounds, region: region, rotate: opts.Rotate, plan: plan}, nil
+}
+
+// drawPage renders a page laid out by layoutPage.
+func (s *PDFService) drawPage(doc Document, idx int, layout pageLayout) (image.Image, error) {
+	img, err := s.drawRegion(doc, idx, layout.bounds, layout.region, layout.plan.dpi)
+	if err != nil {
+		return nil, err
+	}
+	return layout.plan.apply(rotateImage(img, layout.rotate)), nil
+}
+
+func (s *PDFService) renderPage(doc Document, idx int, opts ConvertOptions) (image.Image, error) {
+	layout, err := s.layoutPage(doc, idx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return s.drawPage(doc, idx, layout)
 }
 
 // SetDocumentOpenerForTest allows tests to replace the document opener. It returns a restore function.
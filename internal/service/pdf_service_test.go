@@ -1,10 +1,13 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"image"
 	"image/color"
+	"io"
 	"testing"
 )
 
@@ -12,11 +15,30 @@ type stubDocument struct {
 	pages  int
 	img    image.Image
 	imgErr error
+	blocks []TextBlock
+	meta   map[string]string
+	toc    []OutlineItem
 }
 
-func (s *stubDocument) NumPage() int                              { return s.pages }
-func (s *stubDocument) Image(pageNumber int) (image.Image, error) { return s.img, s.imgErr }
-func (s *stubDocument) Close() error                              { return nil }
+func (s *stubDocument) NumPage() int { return s.pages }
+func (s *stubDocument) ImageDPI(pageNumber int, dpi float64) (image.Image, error) {
+	return s.img, s.imgErr
+}
+func (s *stubDocument) Bound(pageNumber int) (image.Rectangle, error) {
+	return image.Rect(0, 0, 612, 792), nil
+}
+func (s *stubDocument) TextBlocks(pageNumber int) ([]TextBlock, error) {
+	return s.blocks, nil
+}
+func (s *stubDocument) SVG(pageNumber int) (string, error) {
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg"><!-- page %d --></svg>`, pageNumber), nil
+}
+func (s *stubDocument) HTML(pageNumber int, header bool) (string, error) {
+	return fmt.Sprintf("<html><body><div>page %d</div></body></html>", pageNumber), nil
+}
+func (s *stubDocument) Metadata() map[string]string     { return s.meta }
+func (s *stubDocument) Outline() ([]OutlineItem, error) { return s.toc, nil }
+func (s *stubDocument) Close() error                    { return nil }
 
 func TestConvertFirstPage_Success(t *testing.T) {
 	restore := SetDocumentOpenerForTest(func(string) (Document, error) {
@@ -29,11 +51,12 @@ func TestConvertFirstPage_Success(t *testing.T) {
 	})
 	defer restore()
 
-	svc := NewPDFService(85)
-	result, err := svc.ConvertFirstPage(context.Background(), "ignored")
-	if err != nil {
+	svc := NewPDFService(Config{Quality: 85})
+	var buf bytes.Buffer
+	if err := svc.ConvertFirstPage(context.Background(), "ignored", &buf); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
+	result := buf.Bytes()
 	if len(result) == 0 {
 		t.Fatal("expected jpeg bytes, got empty slice")
 	}
@@ -48,8 +71,8 @@ func TestConvertFirstPage_NoPages(t *testing.T) {
 	})
 	defer restore()
 
-	svc := NewPDFService(85)
-	_, err := svc.ConvertFirstPage(context.Background(), "ignored")
+	svc := NewPDFService(Config{Quality: 85})
+	err := svc.ConvertFirstPage(context.Background(), "ignored", io.Discard)
 	if !errors.Is(err, ErrPDFHasNoPages) {
 		t.Fatalf("expected ErrPDFHasNoPages, got %v", err)
 	}
@@ -62,8 +85,8 @@ func TestConvertFirstPage_OpenError(t *testing.T) {
 	})
 	defer restore()
 
-	svc := NewPDFService(85)
-	_, err := svc.ConvertFirstPage(context.Background(), "ignored")
+	svc := NewPDFService(Config{Quality: 85})
+	err := svc.ConvertFirstPage(context.Background(), "ignored", io.Discard)
 	if err == nil || !errors.Is(err, openErr) {
 		t.Fatalf("expected wrapped open error, got %v", err)
 	}
@@ -76,11 +99,170 @@ func TestConvertFirstPage_ContextCanceled(t *testing.T) {
 	})
 	defer restore()
 
-	svc := NewPDFService(85)
+	svc := NewPDFService(Config{Quality: 85})
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
-	_, err := svc.ConvertFirstPage(ctx, "ignored")
+	err := svc.ConvertFirstPage(ctx, "ignored", io.Discard)
 	if !errors.Is(err, context.Canceled) {
 		t.Fatalf("expected context.Canceled, got %v", err)
 	}
 }
+
+func TestConvertPages_Selection(t *testing.T) {
+	var rendered []int
+	restore := SetDocumentOpenerForTest(func(string) (Document, error) {
+		return &recordingDocument{
+			stubDocument: stubDocument{pages: 5, img: image.NewRGBA(image.Rect(0, 0, 1, 1))},
+			rendered:     &rendered,
+		}, nil
+	})
+	defer restore()
+
+	sel, err := ParsePageSelector("2,last")
+	if err != nil {
+		t.Fatalf("parse selector: %v", err)
+	}
+
+	svc := NewPDFService(Config{Quality: 85})
+	pages, err := svc.ConvertPages(context.Background(), "ignored", sel, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pages) != 2 || pages[0].Page != 2 || pages[1].Page != 5 {
+		t.Fatalf("unexpected pages: %+v", pages)
+	}
+	if len(rendered) != 2 || rendered[0] != 1 || rendered[1] != 4 {
+		t.Fatalf("expected page indexes [1 4], got %v", rendered)
+	}
+}
+
+func TestConvertPages_OutOfRange(t *testing.T) {
+	restore := SetDocumentOpenerForTest(func(string) (Document, error) {
+		return &stubDocument{pages: 2}, nil
+	})
+	defer restore()
+
+	sel, err := ParsePageSelector("3")
+	if err != nil {
+		t.Fatalf("parse selector: %v", err)
+	}
+
+	svc := NewPDFService(Config{Quality: 85})
+	_, err = svc.ConvertPages(context.Background(), "ignored", sel, ConvertOptions{})
+	if !errors.Is(err, ErrPageOutOfRange) {
+		t.Fatalf("expected ErrPageOutOfRange, got %v", err)
+	}
+}
+
+type recordingDocument struct {
+	stubDocument
+	rendered *[]int
+}
+
+func (r *recordingDocument) ImageDPI(pageNumber int, dpi float64) (image.Image, error) {
+	*r.rendered = append(*r.rendered, pageNumber)
+	return r.stubDocument.ImageDPI(pageNumber, dpi)
+}
+
+type lockedStubDocument struct {
+	stubDocument
+	password string
+	locked   bool
+	closed   bool
+}
+
+func (s *lockedStubDocument) NeedsPassword() bool { return s.locked }
+func (s *lockedStubDocument) Authenticate(password string) bool {
+	if password != s.password {
+		return false
+	}
+	s.locked = false
+	return true
+}
+func (s *lockedStubDocument) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestConvertPages_Password(t *testing.T) {
+	var doc *lockedStubDocument
+	restore := SetDocumentOpenerForTest(func(string) (Document, error) {
+		doc = &lockedStubDocument{
+			stubDocument: stubDocument{pages: 1, img: image.NewRGBA(image.Rect(0, 0, 1, 1))},
+			password:     "s3cret",
+			locked:       true,
+		}
+		return doc, nil
+	})
+	defer restore()
+
+	svc := NewPDFService(Config{})
+	cases := []struct {
+		password string
+		want     error
+	}{
+		{password: "", want: ErrPDFEncrypted},
+		{password: "wrong", want: ErrPDFBadPassword},
+		{password: "s3cret", want: nil},
+	}
+	for _, tc := range cases {
+		_, err := svc.ConvertPages(context.Background(), "ignored", FirstPage(), ConvertOptions{Password: tc.password})
+		if !errors.Is(err, tc.want) {
+			t.Fatalf("password %q: expected %v, got %v", tc.password, tc.want, err)
+		}
+		if !doc.closed {
+			t.Fatalf("password %q: expected document to be closed", tc.password)
+		}
+	}
+}
+
+// benchmarkPage is an A4 page rendered at 300 DPI with enough detail that it does not compress to nothing.
+func benchmarkPage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 2480, 3508))
+	for y := 0; y < 3508; y++ {
+		for x := 0; x < 2480; x++ {
+			img.SetRGBA(x, y, color.RGBA{R: uint8(x ^ y), G: uint8(x * y >> 4), B: uint8(y), A: 255})
+		}
+	}
+	return img
+}
+
+// The two benchmarks below compare the previous /convert path, which buffered the encoded page before
+// writing it, with the streaming path it now uses. Compare them with -benchmem: the buffered path
+// allocates the whole encoded page (and its growth copies) on top of the encoder's own allocations.
+func BenchmarkConvertPages_Buffered(b *testing.B) {
+	doc := &stubDocument{pages: 1, img: benchmarkPage()}
+	restore := SetDocumentOpenerForTest(func(string) (Document, error) { return doc, nil })
+	defer restore()
+	svc := NewPDFService(Config{})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pages, err := svc.ConvertPages(context.Background(), "ignored", FirstPage(), ConvertOptions{})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := io.Discard.Write(pages[0].Data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStreamPages_Writer(b *testing.B) {
+	doc := &stubDocument{pages: 1, img: benchmarkPage()}
+	restore := SetDocumentOpenerForTest(func(string) (Document, error) { return doc, nil })
+	defer restore()
+	svc := NewPDFService(Config{})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := svc.StreamPages(context.Background(), "ignored", FirstPage(), ConvertOptions{}, func(int) (io.Writer, error) {
+			return io.Discard, nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// pdfWriter emits a minimal PDF 1.4 file object by object, recording offsets for the cross-reference
+// table. Objects may be written in any order but each number exactly once. The first write error is
+// kept and returned by finish.
+type pdfWriter struct {
+	w       io.Writer
+	written int64
+	offsets map[int]int64
+	err     error
+}
+
+func newPDFWriter(w io.Writer) *pdfWriter {
+	p := &pdfWriter{w: w, offsets: make(map[int]int64)}
+	// The binary comment line tells transfer tools that the file is not plain text.
+	p.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+	return p
+}
+
+func (p *pdfWriter) write(b []byte) {
+	if p.err != nil {
+		return
+	}
+	n, err := p.w.Write(b)
+	p.written += int64(n)
+	p.err = err
+}
+
+func (p *pdfWriter) printf(format string, args ...any) {
+	p.write([]byte(fmt.Sprintf(format, args...)))
+}
+
+// object writes a dictionary object.
+func (p *pdfWriter) object(num int, dict string) {
+	p.offsets[num] = p.written
+	p.printf("%d 0 obj\n%s\nendobj\n", num, dict)
+}
+
+// stream writes a stream object whose dictionary is dict plus the Length of data.
+func (p *pdfWriter) stream(num int, dict string, data io.Reader, length int64) {
+	p.offsets[num] = p.written
+	p.printf("%d 0 obj\n<< %s /Length %d >>\nstream\n", num, dict, length)
+	if p.err == nil {
+		n, err := io.Copy(p.w, data)
+		p.written += n
+		p.err = err
+		if p.err == nil && n != length {
+			p.err = fmt.Errorf("stream %d: wrote %d bytes, declared %d", num, n, length)
+		}
+	}
+	p.printf("\nendstream\nendobj\n")
+}
+
+// finish writes the cross-reference table and trailer for objects 1..size-1 with root as the catalog.
+func (p *pdfWriter) finish(root, size int) error {
+	xref := p.written
+	p.printf("xref\n0 %d\n0000000000 65535 f \n", size)
+	for num := 1; num < size; num++ {
+		offset, ok := p.offsets[num]
+		if !ok {
+			return fmt.Errorf("pdf object %d was never written", num)
+		}
+		p.printf("%010d 00000 n \n", offset)
+	}
+	p.printf("trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", size, root, xref)
+	return p.err
+}
+
+// pdfNum formats a coordinate to four decimals without exponents or trailing zeros.
+func pdfNum(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1e4)/1e4, 'f', -1, 64)
+}
@@ -0,0 +1,310 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	// pointsPerInch is the PDF user-space unit density; page bounds are reported in points.
+	pointsPerInch = 72.0
+
+	defaultQuality      = 85
+	defaultMinQuality   = 10
+	defaultMaxQuality   = 100
+	defaultRenderDPI    = 300.0
+	defaultMaxDPI       = 600.0
+	defaultMaxDimension = 10000
+	defaultMaxPixels    = 40_000_000
+	defaultPageTimeout  = time.Minute
+)
+
+var (
+	// ErrInvalidRenderOptions is returned when render options are malformed or contradictory.
+	ErrInvalidRenderOptions = errors.New("invalid render options")
+	// ErrRenderLimitExceeded is returned when a render would exceed the server-side size caps.
+	ErrRenderLimitExceeded = errors.New("render exceeds server limits")
+)
+
+// FitMode controls how a page is mapped onto a Width x Height box.
+type FitMode string
+
+const (
+	// FitContain scales the page to fit inside the box, preserving aspect ratio.
+	FitContain FitMode = "fit"
+	// FitFill scales the page to cover the box, preserving aspect ratio, and crops the overflow.
+	FitFill FitMode = "fill"
+	// FitExact stretches the page to exactly the box size.
+	FitExact FitMode = "exact"
+)
+
+// ParseFitMode parses a fit mode name. An empty string selects FitContain.
+func ParseFitMode(raw string) (FitMode, error) {
+	switch FitMode(strings.ToLower(strings.TrimSpace(raw))) {
+	case "", FitContain:
+		return FitContain, nil
+	case FitFill:
+		return FitFill, nil
+	case FitExact:
+		return FitExact, nil
+	default:
+		return "", fmt.Errorf("%w: unknown fit mode %q", ErrInvalidRenderOptions, raw)
+	}
+}
+
+// ConvertOptions controls how selected pages are rendered and encoded.
+// DPI and the Width/Height box are mutually exclusive; when both are zero the server default DPI is used.
+// When only one of Width or Height is set the other follows the page aspect ratio, whatever the Fit mode.
+// Crop, Trim and Rotate are applied first, in that order, and sizing applies to their result.
+type ConvertOptions struct {
+	DPI    float64
+	Width  int
+	Height int
+	Fit    FitMode
+	// Crop limits rendering to a region measured from the page's top-left corner before rotation. It is
+	// clipped to the page. The zero value renders the whole page.
+	Crop Rect
+	// CropRelative interprets Crop as fractions (0-1) of the page width and height instead of points.
+	CropRelative bool
+	// Trim removes near-white margins around the content of the page or crop region.
+	Trim bool
+	// Rotate turns the output clockwise by 0, 90, 180 or 270 degrees.
+	Rotate int
+	// Format selects the output encoder; empty means JPEG.
+	Format Format
+	// Quality overrides the server default for lossy formats. It is clamped to the configured bounds.
+	Quality int
+	// Chroma selects chroma subsampling. Only ChromaSubsampler encoders (currently AVIF) accept it; it
+	// is rejected for every other format.
+	Chroma Chroma
+	// Grayscale converts pages to a single luminance channel before encoding.
+	Grayscale bool
+	// MaxBytes, when positive, lowers Quality until each encoded page fits.
+	MaxBytes int
+	// Password unlocks encrypted PDFs. It is ignored for unencrypted documents.
+	Password string
+	// InputDigest is the hex SHA-256 of the input file as returned by ResultKey. When set, the result
+	// cache uses it instead of hashing the file again. It does not affect the output.
+	InputDigest string
+}
+
+// Config captures server-side defaults and caps for PDFService.
+type Config struct {
+	// Quality is the default encoder quality (0-100) used by lossy formats.
+	Quality int
+	// MinQuality and MaxQuality bound per-request quality values, including MaxBytes reductions.
+	MinQuality int
+	MaxQuality int
+	// DefaultDPI is used when a request specifies neither DPI nor a target box.
+	DefaultDPI float64
+	// MaxDPI caps explicitly requested DPI values.
+	MaxDPI float64
+	// MaxDimension caps the width and height of any render, in pixels.
+	MaxDimension int
+	// MaxPixels caps the total pixel count of any render.
+	MaxPixels int
+	// MaxPages caps how many pages one selection may name for conversion and text extraction.
+	MaxPages int
+	// MaxSheetPages caps how many pages a contact sheet tiles.
+	MaxSheetPages int
+	// MaxComposeImages caps how many images ComposeImages bundles into one PDF.
+	MaxComposeImages int
+	// PageTimeout bounds the MuPDF work for any one page; a page that runs longer is aborted.
+	PageTimeout time.Duration
+	// Workers, when set, opens documents in sandboxed worker processes instead of in-process.
+	Workers *WorkerPool
+	// Cache, when set, keeps encoded pages keyed by the PDF's SHA-256 and the normalised options, so
+	// repeated conversions skip rendering.
+	Cache CacheStore
+}
+
+func (c Config) withDefaults() Config {
+	if c.Quality <= 0 {
+		c.Quality = defaultQuality
+	}
+	if c.MinQuality <= 0 {
+		c.MinQuality = defaultMinQuality
+	}
+	if c.MaxQuality <= 0 || c.MaxQuality > 100 {
+		c.MaxQuality = defaultMaxQuality
+	}
+	if c.MinQuality > c.MaxQuality {
+		c.MinQuality = c.MaxQuality
+	}
+	c.Quality = c.clampQuality(c.Quality)
+	if c.DefaultDPI <= 0 {
+		c.DefaultDPI = defaultRenderDPI
+	}
+	if c.MaxDPI <= 0 {
+		c.MaxDPI = defaultMaxDPI
+	}
+	if c.MaxDimension <= 0 {
+		c.MaxDimension = defaultMaxDimension
+	}
+	if c.MaxPixels <= 0 {
+		c.MaxPixels = defaultMaxPixels
+	}
+	if c.MaxPages <= 0 {
+		c.MaxPages = defaultMaxPages
+	}
+	if c.MaxSheetPages <= 0 {
+		c.MaxSheetPages = defaultMaxSheetPages
+	}
+	if c.MaxComposeImages <= 0 {
+		c.MaxComposeImages = defaultMaxComposeImages
+	}
+	if c.PageTimeout <= 0 {
+		c.PageTimeout = defaultPageTimeout
+	}
+	return c
+}
+
+// clampQuality keeps quality inside the configured bounds; zero selects the default.
+func (c Config) clampQuality(quality int) int {
+	if quality == 0 {
+		quality = c.Quality
+	}
+	return min(max(quality, c.MinQuality), c.MaxQuality)
+}
+
+// renderPlan is the resolved rendering strategy for a single page.
+type renderPlan struct {
+	dpi float64
+	// width and height are the final output size; zero means "whatever the renderer produced".
+	width  int
+	height int
+	fit    FitMode
+}
+
+// validateOptions rejects options that are malformed or exceed the caps regardless of page size.
+func (c Config) validateOptions(opts ConvertOptions) error {
+	if opts.DPI < 0 || opts.Width < 0 || opts.Height < 0 {
+		return fmt.Errorf("%w: negative size", ErrInvalidRenderOptions)
+	}
+	if opts.Quality < 0 || opts.Quality > 100 || opts.MaxBytes < 0 {
+		return fmt.Errorf("%w: quality or max bytes out of range", ErrInvalidRenderOptions)
+	}
+	if opts.DPI > 0 && (opts.Width > 0 || opts.Height > 0) {
+		return fmt.Errorf("%w: dpi cannot be combined with width or height", ErrInvalidRenderOptions)
+	}
+	if _, err := ParseFitMode(string(opts.Fit)); err != nil {
+		return err
+	}
+	if _, err := ParseChroma(string(opts.Chroma)); err != nil {
+		return err
+	}
+	if opts.Chroma != "" {
+		// Unknown formats are reported by the caller's own lookup.
+		if encoder, err := LookupEncoder(opts.Format); err == nil {
+			if _, ok := encoder.(ChromaSubsampler); !ok {
+				return fmt.Errorf("%w: chroma subsampling is not supported for %s", ErrInvalidRenderOptions, encoderFormat(encoder))
+			}
+		}
+	}
+	if !validRotation(opts.Rotate) {
+		return fmt.Errorf("%w: rotation %d is not 0, 90, 180 or 270", ErrInvalidRenderOptions, opts.Rotate)
+	}
+	if opts.Crop != (Rect{}) {
+		if err := validateCrop(opts.Crop, opts.CropRelative); err != nil {
+			return err
+		}
+	}
+	if opts.DPI > c.MaxDPI {
+		return fmt.Errorf("%w: dpi %.0f above maximum %.0f", ErrRenderLimitExceeded, opts.DPI, c.MaxDPI)
+	}
+	if opts.Width > c.MaxDimension || opts.Height > c.MaxDimension {
+		return fmt.Errorf("%w: %dx%d above maximum dimension %d", ErrRenderLimitExceeded, opts.Width, opts.Height, c.MaxDimension)
+	}
+	return nil
+}
+
+// planRender resolves options that already passed validateOptions against the size, in points, of
+// the area being rendered: the whole page, or its cropped and rotated region.
+func (c Config) planRender(pageW, pageH float64, opts ConvertOptions) (renderPlan, error) {
+	if pageW <= 0 || pageH <= 0 {
+		return renderPlan{}, fmt.Errorf("invalid page size %vx%v", pageW, pageH)
+	}
+
+	switch {
+	case opts.Width > 0 || opts.Height > 0:
+		return c.planBox(pageW, pageH, opts)
+	case opts.DPI > 0:
+		plan := renderPlan{dpi: opts.DPI}
+		return plan, c.checkSize(pixelsAt(pageW, plan.dpi), pixelsAt(pageH, plan.dpi))
+	default:
+		// Nobody asked for this size, so shrink oversized pages instead of rejecting them.
+		dpi := c.DefaultDPI
+		maxSide := math.Max(pageW, pageH)
+		if limit := float64(c.MaxDimension) * pointsPerInch / maxSide; dpi > limit {
+			dpi = limit
+		}
+		if limit := math.Sqrt(float64(c.MaxPixels)/(pageW*pageH)) * pointsPerInch; dpi > limit {
+			dpi = limit
+		}
+		return renderPlan{dpi: dpi}, nil
+	}
+}
+
+func (c Config) planBox(pageW, pageH float64, opts ConvertOptions) (renderPlan, error) {
+	fit, _ := ParseFitMode(string(opts.Fit))
+
+	scaleW := float64(opts.Width) / pageW
+	scaleH := float64(opts.Height) / pageH
+	width, height := opts.Width, opts.Height
+	var scale float64
+	switch {
+	case opts.Height == 0:
+		scale, fit = scaleW, FitContain
+		height = pixelsAt(pageH, scale*pointsPerInch)
+	case opts.Width == 0:
+		scale, fit = scaleH, FitContain
+		width = pixelsAt(pageW, scale*pointsPerInch)
+	case fit == FitContain:
+		scale = math.Min(scaleW, scaleH)
+		width = pixelsAt(pageW, scale*pointsPerInch)
+		height = pixelsAt(pageH, scale*pointsPerInch)
+	default:
+		// Fill and exact both render large enough to cover the box.
+		scale = math.Max(scaleW, scaleH)
+	}
+
+	plan := renderPlan{dpi: scale * pointsPerInch, width: width, height: height, fit: fit}
+	if err := c.checkSize(width, height); err != nil {
+		return renderPlan{}, err
+	}
+	return plan, c.checkSize(pixelsAt(pageW, plan.dpi), pixelsAt(pageH, plan.dpi))
+}
+
+func (c Config) checkSize(width, height int) error {
+	if width > c.MaxDimension || height > c.MaxDimension || width*height > c.MaxPixels {
+		return fmt.Errorf("%w: %dx%d pixels", ErrRenderLimitExceeded, width, height)
+	}
+	return nil
+}
+
+// apply brings a rendered page to the planned output size.
+func (p renderPlan) apply(img image.Image) image.Image {
+	if p.width == 0 || p.height == 0 {
+		return img
+	}
+	b := img.Bounds()
+	if b.Dx() == p.width && b.Dy() == p.height {
+		return img
+	}
+	if p.fit == FitFill {
+		img = cropToAspect(img, p.width, p.height)
+	}
+	return scaleImage(img, p.width, p.height)
+}
+
+func pixelsAt(points, dpi float64) int {
+	px := int(math.Round(points * dpi / pointsPerInch))
+	if px < 1 {
+		return 1
+	}
+	return px
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// letterBounds is a US Letter page in points.
+var letterBounds = image.Rect(0, 0, 612, 792)
+
+func TestPlanRender_DefaultDPIShrinksOversizedPages(t *testing.T) {
+	cfg := Config{MaxDimension: 1000}.withDefaults()
+	plan, err := cfg.planRender(7200, 720, ConvertOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if plan.dpi != 10 {
+		t.Fatalf("expected dpi clamped to 10, got %v", plan.dpi)
+	}
+}
+
+func TestPlanRender_Box(t *testing.T) {
+	cfg := Config{}.withDefaults()
+	cases := []struct {
+		name          string
+		opts          ConvertOptions
+		width, height int
+	}{
+		{name: "width only", opts: ConvertOptions{Width: 306}, width: 306, height: 396},
+		{name: "fit", opts: ConvertOptions{Width: 400, Height: 400, Fit: FitContain}, width: 309, height: 400},
+		{name: "fill", opts: ConvertOptions{Width: 400, Height: 400, Fit: FitFill}, width: 400, height: 400},
+		{name: "exact", opts: ConvertOptions{Width: 100, Height: 400, Fit: FitExact}, width: 100, height: 400},
+	}
+	for _, tc := range cases {
+		plan, err := cfg.planRender(float64(letterBounds.Dx()), float64(letterBounds.Dy()), tc.opts)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		if plan.width != tc.width || plan.height != tc.height {
+			t.Fatalf("%s: expected %dx%d, got %dx%d", tc.name, tc.width, tc.height, plan.width, plan.height)
+		}
+	}
+}
+
+func TestValidateOptions_Limits(t *testing.T) {
+	cfg := Config{MaxDPI: 300, MaxDimension: 2000}.withDefaults()
+	if err := cfg.validateOptions(ConvertOptions{DPI: 600}); !errors.Is(err, ErrRenderLimitExceeded) {
+		t.Fatalf("expected ErrRenderLimitExceeded for dpi, got %v", err)
+	}
+	if err := cfg.validateOptions(ConvertOptions{Width: 20000}); !errors.Is(err, ErrRenderLimitExceeded) {
+		t.Fatalf("expected ErrRenderLimitExceeded for width, got %v", err)
+	}
+	if err := cfg.validateOptions(ConvertOptions{DPI: 100, Width: 100}); !errors.Is(err, ErrInvalidRenderOptions) {
+		t.Fatalf("expected ErrInvalidRenderOptions, got %v", err)
+	}
+	if _, err := cfg.planRender(720, 72000, ConvertOptions{DPI: 72}); !errors.Is(err, ErrRenderLimitExceeded) {
+		t.Fatalf("expected ErrRenderLimitExceeded for tall page, got %v", err)
+	}
+}
+
+func TestConvertPages_ResizesToBox(t *testing.T) {
+	restore := SetDocumentOpenerForTest(func(string) (Document, error) {
+		return &stubDocument{pages: 1, img: image.NewRGBA(image.Rect(0, 0, 50, 60))}, nil
+	})
+	defer restore()
+
+	svc := NewPDFService(Config{})
+	pages, err := svc.ConvertPages(context.Background(), "ignored", FirstPage(), ConvertOptions{Width: 120, Height: 80, Fit: FitFill})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(pages[0].Data))
+	if err != nil {
+		t.Fatalf("decode jpeg: %v", err)
+	}
+	if cfg.Width != 120 || cfg.Height != 80 {
+		t.Fatalf("expected 120x80, got %dx%d", cfg.Width, cfg.Height)
+	}
+}
+
+func TestConfig_ClampQuality(t *testing.T) {
+	cfg := Config{Quality: 70, MinQuality: 40, MaxQuality: 90}.withDefaults()
+	for in, want := range map[int]int{0: 70, 10: 40, 80: 80, 100: 90} {
+		if got := cfg.clampQuality(in); got != want {
+			t.Fatalf("clampQuality(%d): expected %d, got %d", in, want, got)
+		}
+	}
+}
+
+func TestConvertPages_Grayscale(t *testing.T) {
+	restore := SetDocumentOpenerForTest(func(string) (Document, error) {
+		return &stubDocument{pages: 1, img: image.NewRGBA(image.Rect(0, 0, 8, 8))}, nil
+	})
+	defer restore()
+
+	svc := NewPDFService(Config{})
+	pages, err := svc.ConvertPages(context.Background(), "ignored", FirstPage(), ConvertOptions{Grayscale: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(pages[0].Data))
+	if err != nil {
+		t.Fatalf("decode jpeg: %v", err)
+	}
+	if cfg.ColorModel != color.GrayModel {
+		t.Fatalf("expected grayscale jpeg, got %T", cfg.ColorModel)
+	}
+}
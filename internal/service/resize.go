@@ -0,0 +1,122 @@
+package service
+
+import (
+	"image"
+	"image/draw"
+)
+
+// scaleImage resamples src to width x height using bilinear interpolation. Renders are already
+// produced close to the target DPI, so this only corrects rounding and aspect differences.
+func scaleImage(src image.Image, width, height int) *image.RGBA {
+	in := toRGBA(src)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	sb := in.Bounds()
+	sw, sh := sb.Dx(), sb.Dy()
+	if sw == 0 || sh == 0 {
+		return dst
+	}
+
+	xRatio := float64(sw) / float64(width)
+	yRatio := float64(sh) / float64(height)
+	for y := 0; y < height; y++ {
+		fy := (float64(y)+0.5)*yRatio - 0.5
+		y0, wy := splitCoord(fy, sh)
+		y1 := min(y0+1, sh-1)
+		for x := 0; x < width; x++ {
+			fx := (float64(x)+0.5)*xRatio - 0.5
+			x0, wx := splitCoord(fx, sw)
+			x1 := min(x0+1, sw-1)
+
+			p00 := in.PixOffset(sb.Min.X+x0, sb.Min.Y+y0)
+			p10 := in.PixOffset(sb.Min.X+x1, sb.Min.Y+y0)
+			p01 := in.PixOffset(sb.Min.X+x0, sb.Min.Y+y1)
+			p11 := in.PixOffset(sb.Min.X+x1, sb.Min.Y+y1)
+			d := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				top := float64(in.Pix[p00+c])*(1-wx) + float64(in.Pix[p10+c])*wx
+				bottom := float64(in.Pix[p01+c])*(1-wx) + float64(in.Pix[p11+c])*wx
+				dst.Pix[d+c] = uint8(top*(1-wy) + bottom*wy + 0.5)
+			}
+		}
+	}
+	return dst
+}
+
+func splitCoord(f float64, size int) (int, float64) {
+	if f <= 0 {
+		return 0, 0
+	}
+	i := int(f)
+	if i >= size-1 {
+		return size - 1, 0
+	}
+	return i, f - float64(i)
+}
+
+// cropToAspect trims the longer side of img, keeping the centre, so it matches width:height.
+func cropToAspect(img image.Image, width, height int) image.Image {
+	b := img.Bounds()
+	cropW, cropH := b.Dx(), b.Dy()
+	if cropW*height > cropH*width {
+		cropW = cropH * width / height
+	} else {
+		cropH = cropW * height / width
+	}
+	cropW, cropH = max(cropW, 1), max(cropH, 1)
+
+	x0 := b.Min.X + (b.Dx()-cropW)/2
+	y0 := b.Min.Y + (b.Dy()-cropH)/2
+	return toRGBA(img).SubImage(image.Rect(x0, y0, x0+cropW, y0+cropH))
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba
+	}
+	b := img.Bounds()
+	rgba := image.NewRGBA(b)
+	draw.Draw(rgba, b, img, b.Min, draw.Src)
+	return rgba
+}
+
+// toGray converts src to 8-bit luminance using the standard library's colour model.
+func toGray(src image.Image) *image.Gray {
+	if g, ok := src.(*image.Gray); ok {
+		return g
+	}
+	b := src.Bounds()
+	dst := image.NewGray(b)
+	draw.Draw(dst, b, src, b.Min, draw.Src)
+	return dst
+}
+
+// rotateImage turns img clockwise by 90, 180 or 270 degrees; any other value returns img unchanged.
+func rotateImage(img image.Image, degrees int) image.Image {
+	if degrees != 90 && degrees != 180 && degrees != 270 {
+		return img
+	}
+	in := toRGBA(img)
+	sb := in.Bounds()
+	sw, sh := sb.Dx(), sb.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, sw, sh))
+	if degrees != 180 {
+		dst = image.NewRGBA(image.Rect(0, 0, sh, sw))
+	}
+
+	for y := 0; y < sh; y++ {
+		for x := 0; x < sw; x++ {
+			var dx, dy int
+			switch degrees {
+			case 90:
+				dx, dy = sh-1-y, x
+			case 180:
+				dx, dy = sw-1-x, sh-1-y
+			default:
+				dx, dy = y, sw-1-x
+			}
+			s := in.PixOffset(sb.Min.X+x, sb.Min.Y+y)
+			copy(dst.Pix[dst.PixOffset(dx, dy):dst.PixOffset(dx, dy)+4], in.Pix[s:s+4])
+		}
+	}
+	return dst
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// resultCacheVersion is mixed into every key; bump it when rendering changes so stale entries stop matching.
+const resultCacheVersion = "v1"
+
+const (
+	cacheHit   = "hit"
+	cacheMiss  = "miss"
+	cacheError = "error"
+)
+
+// cacheRecorder counts cache lookups so tests can observe them without the Prometheus registry.
+type cacheRecorder interface {
+	IncCache(result string)
+}
+
+// fileDigest returns the hex SHA-256 of the file at path.
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// cacheOptions renders opts, with server defaults applied, as a canonical string. Requests that spell
+// the same render differently, such as an empty format and "jpeg", produce the same string. The
+// password is left out: documents are always unlocked before a cached page is served.
+func (s *PDFService) cacheOptions(opts ConvertOptions) string {
+	format := opts.Format
+	if format == "" {
+		format = FormatJPEG
+	}
+	fit, _ := ParseFitMode(string(opts.Fit))
+	chroma, _ := ParseChroma(string(opts.Chroma))
+	dpi := opts.DPI
+	if dpi == 0 && opts.Width == 0 && opts.Height == 0 {
+		dpi = s.cfg.DefaultDPI
+	}
+	minQuality := 0
+	if opts.MaxBytes > 0 {
+		minQuality = s.cfg.MinQuality
+	}
+	return fmt.Sprintf("format=%s quality=%d chroma=%s gray=%t maxBytes=%d minQuality=%d dpi=%g size=%dx%d fit=%s crop=%g,%g,%g,%g relative=%t trim=%t rotate=%d limits=%d,%d",
+		format, s.cfg.clampQuality(opts.Quality), chroma, opts.Grayscale, opts.MaxBytes, minQuality,
+		dpi, opts.Width, opts.Height, fit,
+		opts.Crop.X, opts.Crop.Y, opts.Crop.W, opts.Crop.H, opts.CropRelative, opts.Trim, opts.Rotate,
+		s.cfg.MaxDimension, s.cfg.MaxPixels)
+}
+
+// inputKind is the file extension, which is how MuPDF picks a parser: the same ZIP bytes open
+// differently as .xps and .cbz.
+func inputKind(path string) string {
+	return "input=" + strings.ToLower(filepath.Ext(path))
+}
+
+// cacheKey hashes the parts of a cache key into a fixed-length hex string.
+func cacheKey(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		// Length-prefix each part so that no two part lists hash the same bytes.
+		fmt.Fprintf(h, "%d:%s", len(part), part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ResultKey returns a key that identifies the output of rendering the pages chosen by sel from the PDF
+// at pdfPath with opts, and the file digest it was derived from. It only hashes the file and options,
+// so it does not check that the selection exists or that the password is right. Handlers use the key
+// as an ETag and pass the digest on as ConvertOptions.InputDigest. Both are empty when no cache is
+// configured, so that requests which cannot be served from the cache skip hashing.
+func (s *PDFService) ResultKey(ctx context.Context, pdfPath string, sel PageSelector, opts ConvertOptions) (key, digest string, err error) {
+	if err := ctx.Err(); err != nil {
+		return "", "", err
+	}
+	if s.cfg.Cache == nil {
+		return "", "", nil
+	}
+	if err := s.cfg.validateOptions(opts); err != nil {
+		return "", "", err
+	}
+	if digest, err = inputDigest(pdfPath, opts); err != nil {
+		return "", "", err
+	}
+	return cacheKey(resultCacheVersion, digest, inputKind(pdfPath), s.cacheOptions(opts), "pages="+sel.String()), digest, nil
+}
+
+// inputDigest returns opts.InputDigest, hashing the file when it is unset.
+func inputDigest(pdfPath string, opts ConvertOptions) (string, error) {
+	if opts.InputDigest != "" {
+		return opts.InputDigest, nil
+	}
+	digest, err := fileDigest(pdfPath)
+	if err != nil {
+		return "", fmt.Errorf("hash pdf: %w", err)
+	}
+	return digest, nil
+}
+
+// pageCache serves and stores the encoded pages of one StreamPages call.
+type pageCache struct {
+	store   CacheStore
+	metrics cacheRecorder
+	prefix  string
+	buf     bytes.Buffer
+}
+
+// newPageCache returns nil when no store is configured.
+func (s *PDFService) newPageCache(pdfPath string, opts ConvertOptions) (*pageCache, error) {
+	if s.cfg.Cache == nil {
+		return nil, nil
+	}
+	digest, err := inputDigest(pdfPath, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &pageCache{
+		store:   s.cfg.Cache,
+		metrics: s.cacheMetrics,
+		prefix:  cacheKey(resultCacheVersion, digest, inputKind(pdfPath), s.cacheOptions(opts)),
+	}, nil
+}
+
+func (c *pageCache) key(idx int) string {
+	return cacheKey(c.prefix, fmt.Sprintf("page=%d", idx))
+}
+
+// serve writes the cached page idx through next and reports whether it was cached. Store failures
+// count as misses so that a broken cache only costs a render.
+func (c *pageCache) serve(idx int, next PageWriterFunc) (bool, error) {
+	data, err := c.store.Get(c.key(idx))
+	switch {
+	case errors.Is(err, ErrCacheMiss):
+		c.metrics.IncCache(cacheMiss)
+		return false, nil
+	case err != nil:
+		c.metrics.IncCache(cacheError)
+		return false, nil
+	}
+	c.metrics.IncCache(cacheHit)
+
+	w, err := next(idx + 1)
+	if err != nil {
+		return true, fmt.Errorf("open page %d writer: %w", idx+1, err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return true, fmt.Errorf("write page %d: %w", idx+1, err)
+	}
+	return true, nil
+}
+
+// record wraps next so that everything written for the page is also kept for save.
+func (c *pageCache) record(next PageWriterFunc) PageWriterFunc {
+	c.buf.Reset()
+	return func(page int) (io.Writer, error) {
+		w, err := next(page)
+		if err != nil {
+			return nil, err
+		}
+		return io.MultiWriter(w, &c.buf), nil
+	}
+}
+
+// save stores the page recorded since the last record call.
+func (c *pageCache) save(idx int) {
+	if err := c.store.Put(c.key(idx), c.buf.Bytes()); err != nil {
+		c.metrics.IncCache(cacheError)
+	}
+}
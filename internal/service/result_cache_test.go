@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type fakeCacheMetrics struct {
+	counts map[string]int
+}
+
+func (f *fakeCacheMetrics) IncCache(result string) {
+	f.counts[result]++
+}
+
+type fakeRenderMetrics struct {
+	formats []string
+}
+
+func (f *fakeRenderMetrics) ObservePage(format string, d time.Duration) {
+	f.formats = append(f.formats, format)
+}
+
+func writeTestPDF(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "doc.pdf")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestStreamPages_Cache(t *testing.T) {
+	var rendered []int
+	var doc *lockedStubDocument
+	restore := SetDocumentOpenerForTest(func(string) (Document, error) {
+		doc = &lockedStubDocument{
+			stubDocument: stubDocument{pages: 3, img: image.NewRGBA(image.Rect(0, 0, 2, 2))},
+			password:     "s3cret",
+			locked:       true,
+		}
+		return &recordingLockedDocument{lockedStubDocument: doc, rendered: &rendered}, nil
+	})
+	defer restore()
+
+	metrics := &fakeCacheMetrics{counts: map[string]int{}}
+	svc := NewPDFService(Config{Cache: NewMemoryCache(1 << 20)})
+	svc.cacheMetrics = metrics
+	pdfPath := writeTestPDF(t, "%PDF-1.4 one")
+	sel, _ := ParsePageSelector("1-2")
+
+	first, err := svc.ConvertPages(context.Background(), pdfPath, sel, ConvertOptions{Password: "s3cret"})
+	if err != nil {
+		t.Fatalf("first convert: %v", err)
+	}
+	// The same render spelled differently still hits the cache.
+	second, err := svc.ConvertPages(context.Background(), pdfPath, sel, ConvertOptions{Password: "s3cret", Format: FormatJPEG, Quality: defaultQuality})
+	if err != nil {
+		t.Fatalf("second convert: %v", err)
+	}
+	if len(rendered) != 2 {
+		t.Fatalf("expected only the first conversion to render, got %v", rendered)
+	}
+	for i := range first {
+		if string(first[i].Data) != string(second[i].Data) || first[i].Page != second[i].Page {
+			t.Fatalf("page %d: cached output differs", i)
+		}
+	}
+	if metrics.counts[cacheMiss] != 2 || metrics.counts[cacheHit] != 2 {
+		t.Fatalf("unexpected cache metrics %v", metrics.counts)
+	}
+
+	// Cached pages are never served without the password.
+	if _, err := svc.ConvertPages(context.Background(), pdfPath, sel, ConvertOptions{}); !errors.Is(err, ErrPDFEncrypted) {
+		t.Fatalf("expected ErrPDFEncrypted, got %v", err)
+	}
+
+	// Different options or different bytes miss.
+	if _, err := svc.ConvertPages(context.Background(), pdfPath, sel, ConvertOptions{Password: "s3cret", Grayscale: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.ConvertPages(context.Background(), writeTestPDF(t, "%PDF-1.4 two"), sel, ConvertOptions{Password: "s3cret"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(rendered) != 6 {
+		t.Fatalf("expected changed inputs to render again, got %v", rendered)
+	}
+}
+
+type recordingLockedDocument struct {
+	*lockedStubDocument
+	rendered *[]int
+}
+
+func (r *recordingLockedDocument) ImageDPI(pageNumber int, dpi float64) (image.Image, error) {
+	*r.rendered = append(*r.rendered, pageNumber)
+	return r.lockedStubDocument.ImageDPI(pageNumber, dpi)
+}
+
+func TestResultKey(t *testing.T) {
+	svc := NewPDFService(Config{Cache: NewMemoryCache(1 << 20)})
+	pdfPath := writeTestPDF(t, "%PDF-1.4 one")
+	ctx := context.Background()
+
+	key := func(pages string, opts ConvertOptions) string {
+		t.Helper()
+		sel, err := ParsePageSelector(pages)
+		if err != nil {
+			t.Fatal(err)
+		}
+		k, digest, err := svc.ResultKey(ctx, pdfPath, sel, opts)
+		if err != nil {
+			t.Fatalf("result key: %v", err)
+		}
+		if want, _ := fileDigest(pdfPath); digest != want {
+			t.Fatalf("expected digest %s, got %s", want, digest)
+		}
+		return k
+	}
+
+	base := key("", ConvertOptions{})
+	if got := key("1", ConvertOptions{DPI: defaultRenderDPI, Format: FormatJPEG, Password: "ignored"}); got != base {
+		t.Fatal("expected equivalent requests to share a key")
+	}
+	if key("2", ConvertOptions{}) == base || key("", ConvertOptions{Format: FormatPNG}) == base {
+		t.Fatal("expected different requests to have different keys")
+	}
+	if _, _, err := svc.ResultKey(ctx, pdfPath, FirstPage(), ConvertOptions{Rotate: 45}); !errors.Is(err, ErrInvalidRenderOptions) {
+		t.Fatalf("expected ErrInvalidRenderOptions, got %v", err)
+	}
+	// A digest handed back by the caller is used instead of hashing the file.
+	if k, digest, err := svc.ResultKey(ctx, pdfPath, FirstPage(), ConvertOptions{InputDigest: "0123"}); k == base || digest != "0123" || err != nil {
+		t.Fatalf("expected the given digest to be used, got %q %q (%v)", k, digest, err)
+	}
+
+	// Without a cache there is nothing to key, so the file is not hashed.
+	if k, digest, err := NewPDFService(Config{}).ResultKey(ctx, pdfPath, FirstPage(), ConvertOptions{}); k != "" || digest != "" || err != nil {
+		t.Fatalf("expected no key without a cache, got %q %q (%v)", k, digest, err)
+	}
+}
+
+func TestStreamPages_CountsRenderedPages(t *testing.T) {
+	restore := SetDocumentOpenerForTest(func(string) (Document, error) {
+		return &stubDocument{pages: 3, img: image.NewRGBA(image.Rect(0, 0, 2, 2))}, nil
+	})
+	defer restore()
+
+	metrics := &fakeRenderMetrics{}
+	svc := NewPDFService(Config{Cache: NewMemoryCache(1 << 20)})
+	svc.renderMetrics = metrics
+	pdfPath := writeTestPDF(t, "%PDF-1.4 counted")
+	sel, _ := ParsePageSelector("1-2")
+
+	for i := 0; i < 2; i++ {
+		if _, err := svc.ConvertPages(context.Background(), pdfPath, sel, ConvertOptions{Format: FormatPNG}); err != nil {
+			t.Fatalf("convert %d: %v", i+1, err)
+		}
+	}
+	// The second conversion is served from the cache and renders nothing.
+	if len(metrics.formats) != 2 || metrics.formats[0] != "png" || metrics.formats[1] != "png" {
+		t.Fatalf("expected two rendered png pages, got %v", metrics.formats)
+	}
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"strings"
+)
+
+// Rect is an axis-aligned box in PDF points (1/72 inch) with the origin at the page's top-left.
+type Rect struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	W float64 `json:"w"`
+	H float64 `json:"h"`
+}
+
+// TextLine is a single line of text and its bounding box.
+type TextLine struct {
+	BBox Rect   `json:"bbox"`
+	Text string `json:"text"`
+}
+
+// TextBlock is a paragraph-like group of lines.
+type TextBlock struct {
+	BBox  Rect       `json:"bbox"`
+	Lines []TextLine `json:"lines"`
+}
+
+// PageText is the text extracted from one page. Page is 1-based.
+type PageText struct {
+	Page   int         `json:"page"`
+	Width  float64     `json:"width"`
+	Height float64     `json:"height"`
+	Text   string      `json:"text"`
+	Blocks []TextBlock `json:"blocks,omitempty"`
+}
+
+// ExtractText returns the text of the pages chosen by sel. In PageText.Text, lines are joined with
+// newlines and blocks with blank lines, trailing spaces are trimmed and blank blocks are dropped.
+// Blocks are returned unmodified, and only when withBlocks is set.
+func (s *PDFService) ExtractText(ctx context.Context, pdfPath string, sel PageSelector, password string, withBlocks bool) ([]PageText, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	doc, err := s.openPDF(pdfPath, password)
+	if err != nil {
+		return nil, err
+	}
+	defer doc.Close()
+
+	if doc.NumPage() == 0 {
+		return nil, ErrPDFHasNoPages
+	}
+	indexes, err := sel.Resolve(doc.NumPage(), s.cfg.MaxPages)
+	if err != nil {
+		return nil, err
+	}
+
+	pages := make([]PageText, 0, len(indexes))
+	for _, idx := range indexes {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		var bounds image.Rectangle
+		var blocks []TextBlock
+		err := s.withPageBudget(ctx, doc, idx, func() (err error) {
+			if bounds, err = doc.Bound(idx); err != nil {
+				return fmt.Errorf("bound page %d: %w", idx+1, err)
+			}
+			if blocks, err = doc.TextBlocks(idx); err != nil {
+				return fmt.Errorf("extract text page %d: %w", idx+1, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		page := PageText{
+			Page:   idx + 1,
+			Width:  float64(bounds.Dx()),
+			Height: float64(bounds.Dy()),
+			Text:   joinBlocks(blocks),
+		}
+		if withBlocks {
+			page.Blocks = blocks
+		}
+		pages = append(pages, page)
+	}
+	return pages, nil
+}
+
+func joinBlocks(blocks []TextBlock) string {
+	var paragraphs []string
+	for _, block := range blocks {
+		lines := make([]string, 0, len(block.Lines))
+		for _, line := range block.Lines {
+			lines = append(lines, strings.TrimRight(line.Text, " \t"))
+		}
+		if paragraph := strings.Join(lines, "\n"); strings.TrimSpace(paragraph) != "" {
+			paragraphs = append(paragraphs, paragraph)
+		}
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestExtractText(t *testing.T) {
+	blocks := []TextBlock{
+		{BBox: Rect{X: 72, Y: 72, W: 200, H: 30}, Lines: []TextLine{
+			{BBox: Rect{X: 72, Y: 72, W: 200, H: 14}, Text: "Invoice 42 "},
+			{BBox: Rect{X: 72, Y: 88, W: 120, H: 14}, Text: "Due: 2025-01-31"},
+		}},
+		{BBox: Rect{X: 72, Y: 120, W: 4, H: 14}, Lines: []TextLine{{Text: " "}}},
+		{BBox: Rect{X: 72, Y: 140, W: 80, H: 14}, Lines: []TextLine{{Text: "Total"}}},
+	}
+	restore := SetDocumentOpenerForTest(func(string) (Document, error) {
+		return &stubDocument{pages: 3, blocks: blocks}, nil
+	})
+	defer restore()
+
+	svc := NewPDFService(Config{})
+	sel, _ := ParsePageSelector("2-3")
+	pages, err := svc.ExtractText(context.Background(), "ignored", sel, "", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pages) != 2 || pages[0].Page != 2 || pages[1].Page != 3 {
+		t.Fatalf("unexpected pages %+v", pages)
+	}
+	if want := "Invoice 42\nDue: 2025-01-31\n\nTotal"; pages[0].Text != want {
+		t.Fatalf("expected %q, got %q", want, pages[0].Text)
+	}
+	if pages[0].Blocks != nil || pages[0].Width != 612 || pages[0].Height != 792 {
+		t.Fatalf("unexpected page metadata %+v", pages[0])
+	}
+
+	pages, err = svc.ExtractText(context.Background(), "ignored", FirstPage(), "", true)
+	if err != nil || len(pages[0].Blocks) != 3 {
+		t.Fatalf("expected blocks to be returned, got %+v (%v)", pages, err)
+	}
+}
+
+func TestExtractText_OutOfRange(t *testing.T) {
+	restore := SetDocumentOpenerForTest(func(string) (Document, error) {
+		return &stubDocument{pages: 1}, nil
+	})
+	defer restore()
+
+	sel, _ := ParsePageSelector("2")
+	_, err := NewPDFService(Config{}).ExtractText(context.Background(), "ignored", sel, "", false)
+	if !errors.Is(err, ErrPageOutOfRange) {
+		t.Fatalf("expected ErrPageOutOfRange, got %v", err)
+	}
+}
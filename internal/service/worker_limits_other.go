@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package service
+
+import "errors"
+
+// apply fails when limits are requested on platforms without setrlimit, rather than running unconfined.
+func (l WorkerLimits) apply() error {
+	if l.MemoryMB > 0 || l.CPUSeconds > 0 {
+		return errors.New("worker resource limits are not supported on this platform")
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+//go:build linux || darwin
+
+package service
+
+import "syscall"
+
+func (l WorkerLimits) apply() error {
+	if l.MemoryMB > 0 {
+		limit := uint64(l.MemoryMB) << 20
+		if err := syscall.Setrlimit(syscall.RLIMIT_DATA, &syscall.Rlimit{Cur: limit, Max: limit}); err != nil {
+			return err
+		}
+	}
+	if l.CPUSeconds > 0 {
+		limit := uint64(l.CPUSeconds)
+		if err := syscall.Setrlimit(syscall.RLIMIT_CPU, &syscall.Rlimit{Cur: limit, Max: limit}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
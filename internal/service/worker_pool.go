@@ -0,0 +1,349 @@
+package service
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultWorkerPoolSize = 2
+	// workerExitGrace is how long a closed worker may take to exit before it is killed.
+	workerExitGrace = 2 * time.Second
+)
+
+// ErrWorkerCrashed is returned when a render worker process exits in the middle of a call, which
+// usually means the document crashed MuPDF or hit a resource limit.
+var ErrWorkerCrashed = errors.New("render worker crashed")
+
+// WorkerPoolConfig captures tunables for WorkerPool. Zero fields fall back to defaults.
+type WorkerPoolConfig struct {
+	// Command and Args start one worker process that runs ServeWorker on its stdin and stdout.
+	Command string
+	Args    []string
+	// Size is how many idle workers are kept started ahead of demand. More start when all are busy.
+	Size   int
+	Logger *slog.Logger
+}
+
+// WorkerPool opens documents in child worker processes so that a crash, runaway allocation or memory
+// corruption in MuPDF only takes down that worker. Each worker serves one document and then exits,
+// and the pool replaces it in the background.
+type WorkerPool struct {
+	cfg  WorkerPoolConfig
+	idle chan *workerProcess
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewWorkerPool starts cfg.Size workers. It fails when the first worker cannot be started, so a
+// misconfigured command is reported at startup rather than on the first request.
+func NewWorkerPool(cfg WorkerPoolConfig) (*WorkerPool, error) {
+	if cfg.Size <= 0 {
+		cfg.Size = defaultWorkerPoolSize
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.New(slog.DiscardHandler)
+	}
+	p := &WorkerPool{cfg: cfg, idle: make(chan *workerProcess, cfg.Size)}
+
+	first, err := p.spawn()
+	if err != nil {
+		return nil, err
+	}
+	p.idle <- first
+	for i := 1; i < cfg.Size; i++ {
+		go p.refill()
+	}
+	return p, nil
+}
+
+// Open opens the document at path in an idle worker. The returned document owns the worker until it
+// is closed.
+func (p *WorkerPool) Open(path string) (Document, error) {
+	w, warm, err := p.take()
+	if err != nil {
+		return nil, err
+	}
+	go p.refill()
+
+	doc, err := w.open(path)
+	if errors.Is(err, ErrWorkerCrashed) && warm {
+		// An idle worker can die while waiting, for example to the OOM killer; retry once on a new one.
+		p.cfg.Logger.Warn("idle render worker was dead, starting another", "err", err)
+		if w, err = p.spawn(); err != nil {
+			return nil, err
+		}
+		doc, err = w.open(path)
+	}
+	return doc, err
+}
+
+// Close stops the idle workers. Documents that are still open keep their workers until they close.
+func (p *WorkerPool) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	for {
+		select {
+		case w := <-p.idle:
+			w.stop()
+		default:
+			return nil
+		}
+	}
+}
+
+// take returns an idle worker, or starts one when none is idle. warm reports which it was.
+func (p *WorkerPool) take() (*workerProcess, bool, error) {
+	select {
+	case w := <-p.idle:
+		return w, true, nil
+	default:
+	}
+	w, err := p.spawn()
+	return w, false, err
+}
+
+// refill starts a worker for the idle set, unless the pool is closed or already full.
+func (p *WorkerPool) refill() {
+	w, err := p.spawn()
+	if err != nil {
+		p.cfg.Logger.Error("start render worker", "err", err)
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.closed {
+		select {
+		case p.idle <- w:
+			return
+		default:
+		}
+	}
+	go w.stop()
+}
+
+func (p *WorkerPool) spawn() (*workerProcess, error) {
+	cmd := exec.Command(p.cfg.Command, p.cfg.Args...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("start render worker: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("start render worker: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start render worker: %w", err)
+	}
+
+	w := &workerProcess{cmd: cmd, stdin: stdin, logger: p.cfg.Logger, done: make(chan struct{})}
+	w.in = bufio.NewWriter(stdin)
+	w.enc = gob.NewEncoder(w.in)
+	w.dec = gob.NewDecoder(bufio.NewReader(stdout))
+	return w, nil
+}
+
+// workerProcess is one running worker. Calls are serialised; Interrupt may kill it at any time.
+type workerProcess struct {
+	cmd    *exec.Cmd
+	stdin  io.Closer
+	in     *bufio.Writer
+	enc    *gob.Encoder
+	dec    *gob.Decoder
+	logger *slog.Logger
+
+	mu   sync.Mutex
+	dead bool
+	// interrupted is set without mu, since Interrupt must not wait for the call in progress.
+	interrupted atomic.Bool
+
+	// done is closed once the process has exited and waitErr is set. Waiting closes stdout, so it only
+	// starts, through reap, once nothing more will be read.
+	reapOnce sync.Once
+	done     chan struct{}
+	waitErr  error
+}
+
+// call sends req and waits for the answer. Once the process has died every call fails.
+func (w *workerProcess) call(req workerRequest) (workerResponse, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var resp workerResponse
+	if w.dead || w.exchange(req, &resp) != nil {
+		return workerResponse{}, w.failed(req.Op)
+	}
+	if resp.Err != "" {
+		return resp, errors.New(resp.Err)
+	}
+	return resp, nil
+}
+
+func (w *workerProcess) exchange(req workerRequest, resp *workerResponse) error {
+	if err := w.enc.Encode(req); err != nil {
+		return err
+	}
+	if err := w.in.Flush(); err != nil {
+		return err
+	}
+	return w.dec.Decode(resp)
+}
+
+// failed marks the worker dead after a broken call and explains why it died.
+func (w *workerProcess) failed(op workerOp) error {
+	first := !w.dead
+	w.dead = true
+	w.stdin.Close()
+	w.reap()
+	<-w.done
+
+	if w.interrupted.Load() {
+		return errInterrupted
+	}
+	if first {
+		w.logger.Warn("render worker died", "op", string(op), "err", w.waitErr)
+	}
+	return fmt.Errorf("%w during %s: %v", ErrWorkerCrashed, op, w.waitErr)
+}
+
+// reap starts waiting for the process to exit.
+func (w *workerProcess) reap() {
+	w.reapOnce.Do(func() {
+		go func() {
+			w.waitErr = w.cmd.Wait()
+			close(w.done)
+		}()
+	})
+}
+
+// kill stops the process immediately, which breaks any call in progress.
+func (w *workerProcess) kill() {
+	if w.cmd.Process != nil {
+		_ = w.cmd.Process.Kill()
+	}
+}
+
+// stop ends a worker that is not in a call: it closes stdin, which ends ServeWorker, and kills the
+// process if it does not exit promptly.
+func (w *workerProcess) stop() {
+	w.stdin.Close()
+	w.reap()
+	select {
+	case <-w.done:
+	case <-time.After(workerExitGrace):
+		w.kill()
+		<-w.done
+	}
+}
+
+func (w *workerProcess) open(path string) (Document, error) {
+	resp, err := w.call(workerRequest{Op: workerOpOpen, Path: path})
+	if err != nil {
+		w.stop()
+		return nil, err
+	}
+	return &remoteDocument{worker: w, pages: resp.Pages, locked: resp.Locked}, nil
+}
+
+// remoteDocument forwards Document calls to the worker that opened it.
+type remoteDocument struct {
+	worker *workerProcess
+	pages  int
+	locked bool
+}
+
+func (d *remoteDocument) NumPage() int        { return d.pages }
+func (d *remoteDocument) NeedsPassword() bool { return d.locked }
+
+func (d *remoteDocument) Authenticate(password string) bool {
+	resp, err := d.worker.call(workerRequest{Op: workerOpAuthenticate, Password: password})
+	if err != nil {
+		return false
+	}
+	d.pages, d.locked = resp.Pages, resp.Locked
+	return resp.OK
+}
+
+func (d *remoteDocument) Bound(pageNumber int) (image.Rectangle, error) {
+	resp, err := d.worker.call(workerRequest{Op: workerOpBound, Page: pageNumber})
+	return resp.Bounds, err
+}
+
+func (d *remoteDocument) PageSize(pageNumber int) (float64, float64, error) {
+	resp, err := d.worker.call(workerRequest{Op: workerOpPageSize, Page: pageNumber})
+	return resp.Width, resp.Height, err
+}
+
+func (d *remoteDocument) ImageDPI(pageNumber int, dpi float64) (image.Image, error) {
+	return d.image(workerRequest{Op: workerOpImage, Page: pageNumber, DPI: dpi})
+}
+
+func (d *remoteDocument) RenderRegion(pageNumber int, dpi float64, region Rect) (image.Image, error) {
+	return d.image(workerRequest{Op: workerOpRegion, Page: pageNumber, DPI: dpi, Region: region})
+}
+
+func (d *remoteDocument) image(req workerRequest) (image.Image, error) {
+	resp, err := d.worker.call(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Image == nil {
+		return nil, errors.New("render worker returned no image")
+	}
+	return &image.RGBA{Pix: resp.Image.Pix, Stride: resp.Image.Stride, Rect: resp.Image.Rect}, nil
+}
+
+func (d *remoteDocument) TextBlocks(pageNumber int) ([]TextBlock, error) {
+	resp, err := d.worker.call(workerRequest{Op: workerOpText, Page: pageNumber})
+	return resp.Blocks, err
+}
+
+func (d *remoteDocument) SVG(pageNumber int) (string, error) {
+	resp, err := d.worker.call(workerRequest{Op: workerOpSVG, Page: pageNumber})
+	return resp.Text, err
+}
+
+func (d *remoteDocument) HTML(pageNumber int, header bool) (string, error) {
+	resp, err := d.worker.call(workerRequest{Op: workerOpHTML, Page: pageNumber, Header: header})
+	return resp.Text, err
+}
+
+func (d *remoteDocument) Metadata() map[string]string {
+	resp, _ := d.worker.call(workerRequest{Op: workerOpMetadata})
+	return resp.Meta
+}
+
+func (d *remoteDocument) Outline() ([]OutlineItem, error) {
+	resp, err := d.worker.call(workerRequest{Op: workerOpOutline})
+	return resp.Outline, err
+}
+
+// Interrupt kills the worker, which is the only way to stop MuPDF work that is stuck in another
+// process. It does not wait for the call in progress, so it is safe from any goroutine.
+func (d *remoteDocument) Interrupt() {
+	d.worker.interrupted.Store(true)
+	d.worker.kill()
+}
+
+// Close ends the worker. A worker that already died is only reaped.
+func (d *remoteDocument) Close() error {
+	_, err := d.worker.call(workerRequest{Op: workerOpClose})
+	d.worker.stop()
+	if errors.Is(err, errInterrupted) {
+		return nil
+	}
+	return err
+}
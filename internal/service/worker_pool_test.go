@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"image"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testWorkerEnv makes the test binary act as a render worker, serving workerTestDocument.
+const testWorkerEnv = "PDF2JPG_TEST_WORKER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(testWorkerEnv) != "" {
+		openDocument = openWorkerTestDocument
+		if err := ServeWorker(os.Stdin, os.Stdout, WorkerLimits{}); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// workerTestDocument behaves according to its file name: crash.pdf exits the worker while rendering,
+// hang.pdf never finishes rendering, and locked.pdf needs the password "s3cret".
+type workerTestDocument struct {
+	lockedStubDocument
+	name string
+}
+
+func openWorkerTestDocument(path string) (Document, error) {
+	doc := &workerTestDocument{name: path}
+	doc.pages = 3
+	doc.img = image.NewRGBA(image.Rect(0, 0, 40, 30))
+	doc.blocks = []TextBlock{{Lines: []TextLine{{Text: "hello from " + path}}}}
+	doc.meta = map[string]string{"title": "Worker"}
+	if path == "locked.pdf" {
+		doc.password, doc.locked = "s3cret", true
+	}
+	return doc, nil
+}
+
+func (d *workerTestDocument) ImageDPI(pageNumber int, dpi float64) (image.Image, error) {
+	switch d.name {
+	case "crash.pdf":
+		os.Exit(3)
+	case "hang.pdf":
+		select {}
+	}
+	return d.lockedStubDocument.ImageDPI(pageNumber, dpi)
+}
+
+func (d *workerTestDocument) PageSize(pageNumber int) (float64, float64, error) {
+	return 595.28, 841.89, nil
+}
+
+func newTestWorkerPool(t *testing.T) *WorkerPool {
+	t.Helper()
+	t.Setenv(testWorkerEnv, "1")
+	pool, err := NewWorkerPool(WorkerPoolConfig{Command: os.Args[0], Args: []string{"-test.run=^$"}, Size: 1})
+	if err != nil {
+		t.Fatalf("start pool: %v", err)
+	}
+	t.Cleanup(func() { pool.Close() })
+	return pool
+}
+
+func TestWorkerPool_Render(t *testing.T) {
+	svc := NewPDFService(Config{Workers: newTestWorkerPool(t)})
+
+	pages, err := svc.ConvertPages(context.Background(), "doc.pdf", AllPages(), ConvertOptions{DPI: 72, Format: FormatPNG})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pages) != 3 || len(pages[2].Data) == 0 {
+		t.Fatalf("expected three rendered pages, got %d", len(pages))
+	}
+
+	text, err := svc.ExtractText(context.Background(), "doc.pdf", FirstPage(), "", false)
+	if err != nil || len(text) != 1 || text[0].Text != "hello from doc.pdf" {
+		t.Fatalf("unexpected text %+v (%v)", text, err)
+	}
+
+	info, err := svc.Inspect(context.Background(), "doc.pdf", "")
+	if err != nil || info.Title != "Worker" || len(info.Pages) != 3 || info.Pages[0].Width != 595.28 {
+		t.Fatalf("unexpected info %+v (%v)", info, err)
+	}
+}
+
+func TestWorkerPool_Password(t *testing.T) {
+	svc := NewPDFService(Config{Workers: newTestWorkerPool(t)})
+
+	if _, err := svc.ConvertPages(context.Background(), "locked.pdf", FirstPage(), ConvertOptions{}); !errors.Is(err, ErrPDFEncrypted) {
+		t.Fatalf("expected ErrPDFEncrypted, got %v", err)
+	}
+	if _, err := svc.ConvertPages(context.Background(), "locked.pdf", FirstPage(), ConvertOptions{Password: "wrong"}); !errors.Is(err, ErrPDFBadPassword) {
+		t.Fatalf("expected ErrPDFBadPassword, got %v", err)
+	}
+	if _, err := svc.ConvertPages(context.Background(), "locked.pdf", FirstPage(), ConvertOptions{Password: "s3cret"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestWorkerPool_CrashIsContained(t *testing.T) {
+	svc := NewPDFService(Config{Workers: newTestWorkerPool(t)})
+
+	_, err := svc.ConvertPages(context.Background(), "crash.pdf", FirstPage(), ConvertOptions{})
+	if !errors.Is(err, ErrWorkerCrashed) || !strings.Contains(err.Error(), "exit status 3") {
+		t.Fatalf("expected ErrWorkerCrashed with the exit status, got %v", err)
+	}
+	if _, err := svc.ConvertPages(context.Background(), "doc.pdf", FirstPage(), ConvertOptions{}); err != nil {
+		t.Fatalf("expected a fresh worker after the crash, got %v", err)
+	}
+}
+
+func TestWorkerPool_TimeoutKillsWorker(t *testing.T) {
+	svc := NewPDFService(Config{Workers: newTestWorkerPool(t), PageTimeout: 50 * time.Millisecond})
+
+	start := time.Now()
+	_, err := svc.ConvertPages(context.Background(), "hang.pdf", FirstPage(), ConvertOptions{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > workerExitGrace {
+		t.Fatalf("expected the hung worker to be killed promptly, took %v", elapsed)
+	}
+}
+
+func TestNewWorkerPool_BadCommand(t *testing.T) {
+	if _, err := NewWorkerPool(WorkerPoolConfig{Command: "/nonexistent/pdf2jpg"}); err == nil {
+		t.Fatal("expected an error for a missing worker command")
+	}
+}
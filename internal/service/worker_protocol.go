@@ -0,0 +1,58 @@
+package service
+
+import (
+	"image"
+)
+
+// workerOp names a Document call forwarded to a render worker process.
+type workerOp string
+
+const (
+	workerOpOpen         workerOp = "open"
+	workerOpAuthenticate workerOp = "authenticate"
+	workerOpBound        workerOp = "bound"
+	workerOpPageSize     workerOp = "pagesize"
+	workerOpImage        workerOp = "image"
+	workerOpRegion       workerOp = "region"
+	workerOpText         workerOp = "text"
+	workerOpSVG          workerOp = "svg"
+	workerOpHTML         workerOp = "html"
+	workerOpMetadata     workerOp = "metadata"
+	workerOpOutline      workerOp = "outline"
+	workerOpClose        workerOp = "close"
+)
+
+// workerRequest is one gob-encoded call from the server to a worker. Only the fields the op needs are set.
+type workerRequest struct {
+	Op       workerOp
+	Path     string
+	Password string
+	Page     int
+	DPI      float64
+	Region   Rect
+	Header   bool
+}
+
+// workerResponse is the worker's answer to one workerRequest. Err is set when the call failed.
+type workerResponse struct {
+	Err string
+	// Pages and Locked describe the document after open and authenticate.
+	Pages   int
+	Locked  bool
+	OK      bool
+	Bounds  image.Rectangle
+	Width   float64
+	Height  float64
+	Image   *workerImage
+	Text    string
+	Blocks  []TextBlock
+	Meta    map[string]string
+	Outline []OutlineItem
+}
+
+// workerImage carries a rendered page as raw RGBA samples.
+type workerImage struct {
+	Rect   image.Rectangle
+	Stride int
+	Pix    []byte
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+)
+
+// WorkerLimits are the resource limits a render worker applies to itself before reading any input.
+// Zero fields leave the corresponding limit unset.
+type WorkerLimits struct {
+	// MemoryMB caps the worker's address space.
+	MemoryMB int
+	// CPUSeconds caps the CPU time the worker may use; the kernel kills it once exceeded.
+	CPUSeconds int
+}
+
+// ServeWorker runs the worker side of the sandboxed rendering protocol: it applies limits, opens one
+// document as instructed on in, answers calls on it through out, and returns once the document is
+// closed or in reaches EOF. Each worker process serves exactly one document, so nothing a malicious
+// file does to MuPDF's state outlives its request.
+func ServeWorker(in io.Reader, out io.Writer, limits WorkerLimits) error {
+	if err := limits.apply(); err != nil {
+		return fmt.Errorf("apply worker limits: %w", err)
+	}
+
+	dec := gob.NewDecoder(bufio.NewReader(in))
+	buf := bufio.NewWriter(out)
+	enc := gob.NewEncoder(buf)
+	send := func(resp workerResponse) error {
+		if err := enc.Encode(resp); err != nil {
+			return err
+		}
+		return buf.Flush()
+	}
+
+	var doc Document
+	defer func() {
+		if doc != nil {
+			doc.Close()
+		}
+	}()
+	for {
+		var req workerRequest
+		if err := dec.Decode(&req); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("read request: %w", err)
+		}
+
+		var resp workerResponse
+		switch {
+		case req.Op == workerOpOpen && doc == nil:
+			var err error
+			if doc, err = openDocument(req.Path); err != nil {
+				resp.Err = err.Error()
+				break
+			}
+			resp.Pages, resp.Locked = doc.NumPage(), needsPassword(doc)
+		case doc == nil:
+			resp.Err = fmt.Sprintf("%s before open", req.Op)
+		case req.Op == workerOpClose:
+			err := doc.Close()
+			doc = nil
+			if err != nil {
+				resp.Err = err.Error()
+			}
+			return send(resp)
+		default:
+			resp = serveWorkerCall(doc, req)
+		}
+		if err := send(resp); err != nil {
+			return fmt.Errorf("write response: %w", err)
+		}
+	}
+}
+
+// serveWorkerCall performs one call on an open document.
+func serveWorkerCall(doc Document, req workerRequest) workerResponse {
+	var resp workerResponse
+	var err error
+	switch req.Op {
+	case workerOpAuthenticate:
+		if locked, ok := doc.(lockedDocument); ok {
+			resp.OK = locked.Authenticate(req.Password)
+		}
+		resp.Pages, resp.Locked = doc.NumPage(), needsPassword(doc)
+	case workerOpBound:
+		resp.Bounds, err = doc.Bound(req.Page)
+	case workerOpPageSize:
+		resp.Width, resp.Height, err = pageSize(doc, req.Page)
+	case workerOpImage:
+		resp.Image, err = workerImageOf(doc.ImageDPI(req.Page, req.DPI))
+	case workerOpRegion:
+		renderer, ok := doc.(regionRenderer)
+		if !ok {
+			err = errors.New("document cannot render regions")
+			break
+		}
+		resp.Image, err = workerImageOf(renderer.RenderRegion(req.Page, req.DPI, req.Region))
+	case workerOpText:
+		resp.Blocks, err = doc.TextBlocks(req.Page)
+	case workerOpSVG:
+		resp.Text, err = doc.SVG(req.Page)
+	case workerOpHTML:
+		resp.Text, err = doc.HTML(req.Page, req.Header)
+	case workerOpMetadata:
+		resp.Meta = doc.Metadata()
+	case workerOpOutline:
+		resp.Outline, err = doc.Outline()
+	default:
+		err = fmt.Errorf("unknown worker op %q", req.Op)
+	}
+	if err != nil {
+		resp.Err = err.Error()
+	}
+	return resp
+}
+
+func workerImageOf(img image.Image, err error) (*workerImage, error) {
+	if err != nil {
+		return nil, err
+	}
+	rgba := toRGBA(img)
+	return &workerImage{Rect: rgba.Rect, Stride: rgba.Stride, Pix: rgba.Pix}, nil
+}
+
+func needsPassword(doc Document) bool {
+	locked, ok := doc.(lockedDocument)
+	return ok && locked.NeedsPassword()
+}
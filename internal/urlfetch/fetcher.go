@@ -0,0 +1,204 @@
+// Package urlfetch downloads remote documents with size, time and network restrictions suitable for
+// fetching URLs supplied by untrusted clients.
+package urlfetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"pdf2jpg/internal/doctype"
+)
+
+const (
+	defaultTimeout      = 30 * time.Second
+	defaultMaxRedirects = 3
+	defaultBaseName     = "document"
+)
+
+var (
+	// ErrInvalidURL indicates a malformed URL or an unsupported scheme.
+	ErrInvalidURL = errors.New("invalid url")
+	// ErrHostNotAllowed indicates the host is not on the configured allowlist.
+	ErrHostNotAllowed = errors.New("host not allowed")
+	// ErrBlockedAddress indicates the host resolved to a private, loopback or otherwise internal address.
+	ErrBlockedAddress = errors.New("address not allowed")
+	// ErrTooLarge indicates the document exceeds the configured size cap.
+	ErrTooLarge = errors.New("remote document too large")
+	// ErrUnexpectedContentType indicates the server did not return an accepted document type.
+	ErrUnexpectedContentType = errors.New("unexpected content type")
+	// ErrUpstreamStatus indicates the server answered with a non-200 status.
+	ErrUpstreamStatus = errors.New("unexpected upstream status")
+)
+
+// blockedNetworks lists ranges that are never fetched unless explicitly allowed, in addition to the
+// loopback, private, link-local, multicast and unspecified checks in net.IP.
+var blockedNetworks = mustParseCIDRs(
+	"0.0.0.0/8",     // "this" network
+	"100.64.0.0/10", // carrier-grade NAT
+	"192.0.0.0/24",  // IETF protocol assignments
+	"198.18.0.0/15", // benchmarking
+	"240.0.0.0/4",   // reserved
+	"64:ff9b::/96",  // NAT64, which can reach IPv4 internals
+	"2002::/16",     // 6to4
+)
+
+// Config captures fetch restrictions. Zero fields fall back to defaults.
+type Config struct {
+	// MaxBytes caps the document size; it should match the upload limit.
+	MaxBytes int64
+	Timeout  time.Duration
+	// AllowedHosts, when non-empty, restricts fetches to these hosts. A leading dot (".example.com")
+	// also matches subdomains.
+	AllowedHosts []string
+	// AllowedNetworks are CIDRs exempt from the internal address block, for trusted internal storage.
+	AllowedNetworks []string
+	MaxRedirects    int
+	// Types are the accepted document types; nil accepts PDF only.
+	Types *doctype.Registry
+}
+
+// Document is a fetched response body. The caller must close Body.
+type Document struct {
+	Body io.ReadCloser
+	// Filename is derived from the URL path and always ends in an extension of Type.
+	Filename string
+	Type     doctype.Type
+}
+
+// Fetcher downloads documents over HTTP(S) while refusing internal addresses.
+type Fetcher struct {
+	*Guard
+	client   *http.Client
+	maxBytes int64
+	types    *doctype.Registry
+}
+
+func New(cfg Config) (*Fetcher, error) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.MaxRedirects <= 0 {
+		cfg.MaxRedirects = defaultMaxRedirects
+	}
+
+	guard, err := NewGuard(cfg.AllowedHosts, cfg.AllowedNetworks)
+	if err != nil {
+		return nil, err
+	}
+	return &Fetcher{
+		Guard:    guard,
+		client:   guard.Client(cfg.Timeout, cfg.MaxRedirects),
+		maxBytes: cfg.MaxBytes,
+		types:    cfg.Types,
+	}, nil
+}
+
+// Fetch starts downloading rawURL. The returned body fails with ErrTooLarge once MaxBytes is exceeded.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Document, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	if err := f.CheckURL(u); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	req.Header.Set("Accept", strings.Join(f.types.MediaTypes(), ", "))
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", u.Redacted(), err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %d", ErrUpstreamStatus, resp.StatusCode)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	docType, ok := f.documentType(resp.Request.URL, mediaType)
+	if !ok {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %q", ErrUnexpectedContentType, mediaType)
+	}
+	if f.maxBytes > 0 && resp.ContentLength > f.maxBytes {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %d bytes", ErrTooLarge, resp.ContentLength)
+	}
+
+	body := resp.Body
+	if f.maxBytes > 0 {
+		body = &limitedBody{ReadCloser: resp.Body, remaining: f.maxBytes}
+	}
+	return &Document{Body: body, Filename: filenameFromURL(resp.Request.URL, docType), Type: docType}, nil
+}
+
+// documentType picks the type from an accepted media type, or for application/octet-stream from the URL
+// extension, falling back to the first accepted type.
+func (f *Fetcher) documentType(u *url.URL, mediaType string) (doctype.Type, bool) {
+	if mediaType != "application/octet-stream" {
+		return f.types.ByMediaType(mediaType)
+	}
+	if docType, ok := f.types.ByFilename(path.Base(u.Path)); ok {
+		return docType, true
+	}
+	return f.types.Types()[0], true
+}
+
+// limitedBody fails instead of silently truncating when the cap is exceeded.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, ErrTooLarge
+	}
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n, ErrTooLarge
+	}
+	return n, err
+}
+
+func filenameFromURL(u *url.URL, docType doctype.Type) string {
+	name := path.Base(u.Path)
+	if name == "." || name == "/" || name == "" {
+		return defaultBaseName + docType.Extension()
+	}
+	ext := strings.ToLower(path.Ext(name))
+	for _, candidate := range docType.Extensions {
+		if ext == candidate {
+			return name
+		}
+	}
+	return name + docType.Extension()
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
@@ -0,0 +1,116 @@
+package urlfetch
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+
+	"pdf2jpg/internal/doctype"
+)
+
+func TestAddressAllowed(t *testing.T) {
+	f, err := New(Config{AllowedNetworks: []string{"10.20.0.0/16"}})
+	if err != nil {
+		t.Fatalf("new fetcher: %v", err)
+	}
+	cases := map[string]bool{
+		"93.184.216.34":    true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"::1":              false,
+		"10.0.0.1":         false,
+		"172.16.5.4":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"fe80::1":          false,
+		"fc00::1":          false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"::ffff:127.0.0.1": false,
+		"10.20.3.4":        true,
+	}
+	for addr, want := range cases {
+		if got := f.addressAllowed(net.ParseIP(addr)); got != want {
+			t.Errorf("addressAllowed(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	f, err := New(Config{AllowedHosts: []string{"files.example.com", ".cdn.example.net"}})
+	if err != nil {
+		t.Fatalf("new fetcher: %v", err)
+	}
+	cases := map[string]error{
+		"https://files.example.com/a.pdf":       nil,
+		"https://eu.cdn.example.net/a.pdf":      nil,
+		"https://example.com/a.pdf":             ErrHostNotAllowed,
+		"https://cdn.example.net.evil.io/a.pdf": ErrHostNotAllowed,
+		"ftp://files.example.com/a.pdf":         ErrInvalidURL,
+		"https://user:pw@files.example.com/":    ErrInvalidURL,
+	}
+	for raw, want := range cases {
+		u, _ := url.Parse(raw)
+		if err := f.CheckURL(u); !errors.Is(err, want) {
+			t.Errorf("CheckURL(%s) = %v, want %v", raw, err, want)
+		}
+	}
+}
+
+func TestLimitedBody(t *testing.T) {
+	body := &limitedBody{ReadCloser: io.NopCloser(strings.NewReader("0123456789")), remaining: 10}
+	if data, err := io.ReadAll(body); err != nil || len(data) != 10 {
+		t.Fatalf("expected exact-size body to pass, got %d bytes (%v)", len(data), err)
+	}
+
+	body = &limitedBody{ReadCloser: io.NopCloser(strings.NewReader("0123456789x")), remaining: 10}
+	if _, err := io.ReadAll(body); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("expected ErrTooLarge, got %v", err)
+	}
+}
+
+func TestFilenameFromURL(t *testing.T) {
+	cases := map[string]string{
+		"https://a.example/docs/report.pdf": "report.pdf",
+		"https://a.example/download?id=3":   "download.pdf",
+		"https://a.example/":                "document.pdf",
+	}
+	for raw, want := range cases {
+		u, _ := url.Parse(raw)
+		if got := filenameFromURL(u, doctype.PDF); got != want {
+			t.Errorf("filenameFromURL(%s) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestDocumentType(t *testing.T) {
+	types, err := doctype.NewRegistry([]string{"pdf", "tiff"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &Fetcher{types: types}
+	cases := []struct {
+		url, mediaType, want string
+		ok                   bool
+	}{
+		{url: "https://a.example/scan", mediaType: "image/tiff", want: "tiff", ok: true},
+		{url: "https://a.example/scan.tif", mediaType: "application/octet-stream", want: "tiff", ok: true},
+		{url: "https://a.example/download", mediaType: "application/octet-stream", want: "pdf", ok: true},
+		{url: "https://a.example/book.epub", mediaType: "application/epub+zip"},
+		{url: "https://a.example/page.html", mediaType: "text/html"},
+	}
+	for _, tc := range cases {
+		u, _ := url.Parse(tc.url)
+		got, ok := f.documentType(u, tc.mediaType)
+		if ok != tc.ok || (ok && got.Name != tc.want) {
+			t.Errorf("documentType(%s, %s) = %q, %v", tc.url, tc.mediaType, got.Name, ok)
+		}
+	}
+	tiff, _ := types.ByMediaType("image/tiff")
+	if name := filenameFromURL(&url.URL{Path: "/scan"}, tiff); name != "scan.tiff" {
+		t.Errorf("expected scan.tiff, got %q", name)
+	}
+}
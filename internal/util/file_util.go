@@ -4,27 +4,24 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"mime/multipart"
-	"net/http"
 	"os"
-	"path/filepath"
-	"strings"
+
+	"pdf2jpg/internal/doctype"
 )
 
 const tempDir = "/tmp"
 
-// SaveUploadedFile persists the multipart file to a temporary PDF file and validates basic properties.
-func SaveUploadedFile(src multipart.File, originalName string) (string, error) {
+// ErrContentMismatch is returned when a file's leading bytes do not match its declared type.
+var ErrContentMismatch = errors.New("file content does not match its type")
+
+// SaveUploadedFile persists an uploaded or downloaded document of type docType to a temporary file
+// named with the type's extension, after checking its magic bytes.
+func SaveUploadedFile(src io.Reader, docType doctype.Type) (string, error) {
 	if src == nil {
 		return "", errors.New("missing file")
 	}
 
-	ext := strings.ToLower(filepath.Ext(originalName))
-	if ext != ".pdf" {
-		return "", fmt.Errorf("invalid file extension: %s", ext)
-	}
-
-	tempFile, err := os.CreateTemp(tempDir, "pdf2jpg-*.pdf")
+	tempFile, err := os.CreateTemp(tempDir, "pdf2jpg-*"+docType.Extension())
 	if err != nil {
 		return "", fmt.Errorf("create temp file: %w", err)
 	}
@@ -36,15 +33,16 @@ func SaveUploadedFile(src multipart.File, originalName string) (string, error) {
 		}
 	}()
 
-	header := make([]byte, 512)
+	header := make([]byte, doctype.SniffLen)
 	n, readErr := io.ReadFull(src, header)
 	if readErr != nil && !errors.Is(readErr, io.ErrUnexpectedEOF) && !errors.Is(readErr, io.EOF) {
-		return "", fmt.Errorf("read file header: %w", readErr)
+		err = fmt.Errorf("read file header: %w", readErr)
+		return "", err
 	}
 
-	contentType := http.DetectContentType(header[:n])
-	if contentType != "application/pdf" && contentType != "application/octet-stream" {
-		return "", fmt.Errorf("invalid content type: %s", contentType)
+	if !docType.Matches(header[:n]) {
+		err = fmt.Errorf("%w: expected %s", ErrContentMismatch, docType.Name)
+		return "", err
 	}
 
 	if _, err = tempFile.Write(header[:n]); err != nil {
@@ -1,25 +1,36 @@
 package test
 
 import (
+	"archive/zip"
 	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"image"
 	"image/color"
+	"image/jpeg"
+	"image/png"
 	"io"
-	"log"
+	"log/slog"
+	"mime"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"pdf2jpg/internal/auth"
+	"pdf2jpg/internal/doctype"
 	"pdf2jpg/internal/handler"
+	"pdf2jpg/internal/jobs"
+	"pdf2jpg/internal/limiter"
 	"pdf2jpg/internal/service"
+	"pdf2jpg/internal/urlfetch"
 )
 
 const (
@@ -34,30 +45,71 @@ type fakeDocument struct {
 	pages  int
 	img    image.Image
 	imgErr error
+	blocks []service.TextBlock
+	meta   map[string]string
+	toc    []service.OutlineItem
 }
 
 func (f *fakeDocument) NumPage() int {
 	return f.pages
 }
 
-func (f *fakeDocument) Image(int) (image.Image, error) {
+func (f *fakeDocument) ImageDPI(int, float64) (image.Image, error) {
 	if f.imgErr != nil {
 		return nil, f.imgErr
 	}
 	return f.img, nil
 }
 
+func (f *fakeDocument) Bound(int) (image.Rectangle, error) {
+	return image.Rect(0, 0, 612, 792), nil
+}
+
+func (f *fakeDocument) TextBlocks(int) ([]service.TextBlock, error) {
+	return f.blocks, nil
+}
+
+func (f *fakeDocument) SVG(page int) (string, error) {
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" id="p%d"/>`, page), nil
+}
+
+func (f *fakeDocument) HTML(page int, _ bool) (string, error) {
+	return fmt.Sprintf(`<html><body id="p%d"></body></html>`, page), nil
+}
+
+func (f *fakeDocument) Metadata() map[string]string {
+	return f.meta
+}
+
+func (f *fakeDocument) Outline() ([]service.OutlineItem, error) {
+	return f.toc, nil
+}
+
 func (f *fakeDocument) Close() error { return nil }
 
+// lockedDocument is an encrypted fake that unlocks with password.
+type lockedDocument struct {
+	fakeDocument
+	password string
+	unlocked bool
+}
+
+func (l *lockedDocument) NeedsPassword() bool { return !l.unlocked }
+
+func (l *lockedDocument) Authenticate(password string) bool {
+	l.unlocked = password == l.password
+	return l.unlocked
+}
+
 func newTestHandler(t *testing.T, opener func(string) (service.Document, error), keyService *auth.KeyService, enableDynamic bool) http.Handler {
 	t.Helper()
 
-	logger := log.New(io.Discard, "", 0)
+	logger := slog.New(slog.DiscardHandler)
 	restore := service.SetDocumentOpenerForTest(opener)
 	t.Cleanup(restore)
 
-	pdfService := service.NewPDFService(defaultJPEGQual)
-	convertHandler := handler.NewConvertHandler(pdfService, logger, maxUploadBytes)
+	pdfService := service.NewPDFService(service.Config{Quality: defaultJPEGQual})
+	convertHandler := handler.NewConvertHandler(pdfService, nil, nil, nil, logger, maxUploadBytes)
 
 	return auth.APIKeyMiddleware(auth.APIKeyMiddlewareConfig{
 		StaticKeys:     []string{testAPIKey},
@@ -107,6 +159,72 @@ func TestConvertEndpoint(t *testing.T) {
 		assertJSONError(t, rec, http.StatusBadRequest, "file field is required")
 	})
 
+	t.Run("encrypted pdf", func(t *testing.T) {
+		lockedOpener := func(string) (service.Document, error) {
+			return &lockedDocument{fakeDocument: fakeDocument{pages: 1, img: image.NewRGBA(image.Rect(0, 0, 1, 1))}, password: "s3cret"}, nil
+		}
+		handler := newTestHandler(t, lockedOpener, nil, false)
+
+		body, contentType := createMultipartBody(t, expectedFileName, minimalPDF())
+		assertJSONError(t, sendConvertRequest(t, handler, body, contentType, testAPIKey), http.StatusUnauthorized, "pdf is encrypted")
+
+		body, contentType = createMultipartBodyWithFields(t, expectedFileName, minimalPDF(), map[string]string{"password": "guess"})
+		assertJSONError(t, sendConvertRequest(t, handler, body, contentType, testAPIKey), http.StatusUnprocessableEntity, "incorrect pdf password")
+
+		body, contentType = createMultipartBodyWithFields(t, expectedFileName, minimalPDF(), map[string]string{"password": "s3cret"})
+		if rec := sendConvertRequest(t, handler, body, contentType, testAPIKey); rec.Code != http.StatusOK {
+			t.Fatalf("expected 200 with correct password, got %d: %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("svg zip", func(t *testing.T) {
+		handler := newTestHandler(t, func(string) (service.Document, error) {
+			return &fakeDocument{pages: 2}, nil
+		}, nil, false)
+		body, contentType := createMultipartBodyWithFields(t, expectedFileName, minimalPDF(), map[string]string{"pages": "1-2", "format": "svg"})
+		rec := sendConvertRequest(t, handler, body, contentType, testAPIKey)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+		if err != nil {
+			t.Fatalf("open zip: %v", err)
+		}
+		if len(zr.File) != 2 || zr.File[1].Name != "sample-p002.svg" {
+			t.Fatalf("unexpected zip entries %v", zr.File)
+		}
+	})
+
+	t.Run("html page", func(t *testing.T) {
+		handler := newTestHandler(t, successOpener, nil, false)
+		body, contentType := createMultipartBodyWithFields(t, expectedFileName, minimalPDF(), map[string]string{"format": "html"})
+		rec := sendConvertRequest(t, handler, body, contentType, testAPIKey)
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+			t.Fatalf("expected html response, got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+		}
+		if got := rec.Header().Get("Content-Disposition"); got != `attachment; filename="sample.html"` {
+			t.Fatalf("expected the page as a download, got Content-Disposition %s", got)
+		}
+		if rec.Header().Get("Content-Security-Policy") != "sandbox" || rec.Header().Get("X-Content-Type-Options") != "nosniff" {
+			t.Fatalf("expected a sandboxed, unsniffed response, got %v", rec.Header())
+		}
+	})
+
+	t.Run("svg page", func(t *testing.T) {
+		handler := newTestHandler(t, successOpener, nil, false)
+		body, contentType := createMultipartBodyWithFields(t, expectedFileName, minimalPDF(), map[string]string{"format": "svg"})
+		rec := sendConvertRequest(t, handler, body, contentType, testAPIKey)
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/svg+xml" {
+			t.Fatalf("expected svg response, got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+		}
+		if got := rec.Header().Get("Content-Disposition"); got != `attachment; filename="sample.svg"` {
+			t.Fatalf("expected the page as a download, got Content-Disposition %s", got)
+		}
+		if rec.Header().Get("Content-Security-Policy") != "sandbox" || rec.Header().Get("X-Content-Type-Options") != "nosniff" {
+			t.Fatalf("expected a sandboxed, unsniffed response, got %v", rec.Header())
+		}
+	})
+
 	t.Run("invalid extension", func(t *testing.T) {
 		handler := newTestHandler(t, successOpener, nil, false)
 		body, contentType := createMultipartBody(t, "not-pdf.txt", minimalPDF())
@@ -138,9 +256,136 @@ func TestConvertEndpoint(t *testing.T) {
 		assertJSONError(t, rec, http.StatusInternalServerError, "failed to convert pdf")
 	})
 
+	t.Run("page selection", func(t *testing.T) {
+		handler := newTestHandler(t, func(string) (service.Document, error) {
+			img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+			return &fakeDocument{pages: 3, img: img}, nil
+		}, nil, false)
+		body, contentType := createMultipartBodyWithFields(t, expectedFileName, minimalPDF(), map[string]string{"pages": "last"})
+		rec := sendConvertRequest(t, handler, body, contentType, testAPIKey)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("multiple pages as zip", func(t *testing.T) {
+		handler := newTestHandler(t, func(string) (service.Document, error) {
+			img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+			return &fakeDocument{pages: 3, img: img}, nil
+		}, nil, false)
+		body, contentType := createMultipartBodyWithFields(t, expectedFileName, minimalPDF(), map[string]string{"pages": "1,3"})
+		rec := sendConvertRequest(t, handler, body, contentType, testAPIKey)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if rec.Header().Get("Content-Type") != "application/zip" {
+			t.Fatalf("expected application/zip, got %s", rec.Header().Get("Content-Type"))
+		}
+		archive, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+		if err != nil {
+			t.Fatalf("open zip: %v", err)
+		}
+		var names []string
+		for _, f := range archive.File {
+			names = append(names, f.Name)
+		}
+		if strings.Join(names, ",") != "sample-p001.jpg,sample-p003.jpg" {
+			t.Fatalf("unexpected archive entries: %v", names)
+		}
+	})
+
+	t.Run("multiple pages with image output", func(t *testing.T) {
+		handler := newTestHandler(t, successOpener, nil, false)
+		body, contentType := createMultipartBodyWithFields(t, expectedFileName, minimalPDF(), map[string]string{"pages": "1-2", "output": "image"})
+		rec := sendConvertRequest(t, handler, body, contentType, testAPIKey)
+		assertJSONError(t, rec, http.StatusBadRequest, "pages must select a single page")
+	})
+
+	t.Run("zip page out of range", func(t *testing.T) {
+		handler := newTestHandler(t, successOpener, nil, false)
+		body, contentType := createMultipartBodyWithFields(t, expectedFileName, minimalPDF(), map[string]string{"pages": "1-3"})
+		rec := sendConvertRequest(t, handler, body, contentType, testAPIKey)
+		assertJSONError(t, rec, http.StatusBadRequest, "page out of range")
+	})
+
+	t.Run("png format", func(t *testing.T) {
+		handler := newTestHandler(t, successOpener, nil, false)
+		body, contentType := createMultipartBodyWithFields(t, expectedFileName, minimalPDF(), map[string]string{"format": "png"})
+		rec := sendConvertRequest(t, handler, body, contentType, testAPIKey)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if rec.Header().Get("Content-Type") != "image/png" {
+			t.Fatalf("expected image/png, got %s", rec.Header().Get("Content-Type"))
+		}
+		if !strings.Contains(rec.Header().Get("Content-Disposition"), `filename="sample.png"`) {
+			t.Fatalf("unexpected Content-Disposition %q", rec.Header().Get("Content-Disposition"))
+		}
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		handler := newTestHandler(t, successOpener, nil, false)
+		body, contentType := createMultipartBodyWithFields(t, expectedFileName, minimalPDF(), map[string]string{"format": "gif"})
+		rec := sendConvertRequest(t, handler, body, contentType, testAPIKey)
+		assertJSONError(t, rec, http.StatusBadRequest, "unsupported format")
+	})
+
+	t.Run("crop and rotate", func(t *testing.T) {
+		handler := newTestHandler(t, successOpener, nil, false)
+		fields := map[string]string{"format": "png", "crop": "0,0,0.5,0.25", "cropUnits": "fraction", "rotate": "90", "width": "99"}
+		body, contentType := createMultipartBodyWithFields(t, expectedFileName, minimalPDF(), fields)
+		rec := sendConvertRequest(t, handler, body, contentType, testAPIKey)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		cfg, _, err := image.DecodeConfig(rec.Body)
+		if err != nil {
+			t.Fatalf("decode png: %v", err)
+		}
+		// The 306x198pt region turns on its side, so width 99 gives a height of 153.
+		if cfg.Width != 99 || cfg.Height != 153 {
+			t.Fatalf("expected 99x153, got %dx%d", cfg.Width, cfg.Height)
+		}
+	})
+
+	t.Run("invalid rotate", func(t *testing.T) {
+		handler := newTestHandler(t, successOpener, nil, false)
+		body, contentType := createMultipartBodyWithFields(t, expectedFileName, minimalPDF(), map[string]string{"rotate": "45"})
+		rec := sendConvertRequest(t, handler, body, contentType, testAPIKey)
+		assertJSONError(t, rec, http.StatusBadRequest, "invalid rotate parameter")
+	})
+
+	t.Run("invalid quality", func(t *testing.T) {
+		handler := newTestHandler(t, successOpener, nil, false)
+		body, contentType := createMultipartBodyWithFields(t, expectedFileName, minimalPDF(), map[string]string{"quality": "150"})
+		rec := sendConvertRequest(t, handler, body, contentType, testAPIKey)
+		assertJSONError(t, rec, http.StatusBadRequest, "invalid quality parameter")
+	})
+
+	t.Run("max bytes unreachable", func(t *testing.T) {
+		handler := newTestHandler(t, successOpener, nil, false)
+		body, contentType := createMultipartBodyWithFields(t, expectedFileName, minimalPDF(), map[string]string{"format": "png", "maxBytes": "10"})
+		rec := sendConvertRequest(t, handler, body, contentType, testAPIKey)
+		assertJSONError(t, rec, http.StatusUnprocessableEntity, "output cannot fit within maxBytes")
+	})
+
+	t.Run("page out of range", func(t *testing.T) {
+		handler := newTestHandler(t, successOpener, nil, false)
+		body, contentType := createMultipartBodyWithFields(t, expectedFileName, minimalPDF(), map[string]string{"pages": "2"})
+		rec := sendConvertRequest(t, handler, body, contentType, testAPIKey)
+		assertJSONError(t, rec, http.StatusBadRequest, "page out of range")
+	})
+
+	t.Run("invalid pages parameter", func(t *testing.T) {
+		handler := newTestHandler(t, successOpener, nil, false)
+		body, contentType := createMultipartBodyWithFields(t, expectedFileName, minimalPDF(), map[string]string{"pages": "first"})
+		rec := sendConvertRequest(t, handler, body, contentType, testAPIKey)
+		assertJSONError(t, rec, http.StatusBadRequest, "invalid pages parameter")
+	})
+
 	t.Run("temporary key usage limit", func(t *testing.T) {
 		repo := newTestRepository()
-		logger := log.New(io.Discard, "", 0)
+		logger := slog.New(slog.DiscardHandler)
 		service := auth.NewKeyService(repo, logger, nil, auth.ServiceConfig{})
 		resp, err := service.IssueTemporaryKey(context.Background(), auth.IssueRequest{
 			Label:      "trial",
@@ -165,10 +410,1029 @@ func TestConvertEndpoint(t *testing.T) {
 	})
 }
 
+func TestConvertEndpoint_RawBody(t *testing.T) {
+	opener := func(string) (service.Document, error) {
+		return &fakeDocument{pages: 3, img: image.NewRGBA(image.Rect(0, 0, 1, 1))}, nil
+	}
+	send := func(t *testing.T, target string, body []byte, header http.Header) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/pdf")
+		req.Header.Set("X-API-Key", testAPIKey)
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		rec := httptest.NewRecorder()
+		newTestHandler(t, opener, nil, false).ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("options from query", func(t *testing.T) {
+		rec := send(t, "/convert?filename=invoice.pdf&format=png&pages=2", minimalPDF(), nil)
+		res := rec.Result()
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", res.StatusCode, rec.Body.String())
+		}
+		if res.Header.Get("Content-Type") != "image/png" {
+			t.Fatalf("expected image/png, got %s", res.Header.Get("Content-Type"))
+		}
+		if cd := res.Header.Get("Content-Disposition"); cd != `inline; filename="invoice.png"` {
+			t.Fatalf("unexpected Content-Disposition %s", cd)
+		}
+	})
+
+	t.Run("filename from content-disposition", func(t *testing.T) {
+		rec := send(t, "/convert?pages=1-2", minimalPDF(), http.Header{"Content-Disposition": {`attachment; filename="scan.pdf"`}})
+		res := rec.Result()
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", res.StatusCode, rec.Body.String())
+		}
+		if cd := res.Header.Get("Content-Disposition"); cd != `attachment; filename="scan.zip"` {
+			t.Fatalf("unexpected Content-Disposition %s", cd)
+		}
+	})
+
+	t.Run("default filename", func(t *testing.T) {
+		rec := send(t, "/convert", minimalPDF(), nil)
+		if cd := rec.Result().Header.Get("Content-Disposition"); cd != `inline; filename="document.jpg"` {
+			t.Fatalf("unexpected Content-Disposition %s", cd)
+		}
+	})
+
+	t.Run("leading junk before the pdf header", func(t *testing.T) {
+		body := append([]byte("X-Mailer: scanner\r\n\r\n"), minimalPDF()...)
+		rec := send(t, "/convert", body, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("not a pdf", func(t *testing.T) {
+		rec := send(t, "/convert", []byte("\x89PNG\r\n\x1a\nnot really a pdf"), nil)
+		assertJSONError(t, rec, http.StatusBadRequest, "file content does not match its type")
+	})
+
+	t.Run("invalid filename", func(t *testing.T) {
+		rec := send(t, "/convert?filename=notes.txt", minimalPDF(), nil)
+		assertJSONError(t, rec, http.StatusBadRequest, "file must be a pdf")
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		rec := send(t, "/convert", append(minimalPDF(), make([]byte, maxUploadBytes)...), nil)
+		assertJSONError(t, rec, http.StatusRequestEntityTooLarge, "file too large")
+	})
+}
+
+func TestConvertEndpoint_URL(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	restore := service.SetDocumentOpenerForTest(func(string) (service.Document, error) {
+		return &fakeDocument{pages: 3, img: image.NewRGBA(image.Rect(0, 0, 1, 1))}, nil
+	})
+	t.Cleanup(restore)
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/docs/report.pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			_, _ = w.Write(minimalPDF())
+		case "/page.html":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte("<html></html>"))
+		case "/huge.pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			_, _ = w.Write(append(minimalPDF(), make([]byte, 2048)...))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer origin.Close()
+
+	newHandler := func(t *testing.T, cfg urlfetch.Config) http.Handler {
+		t.Helper()
+		fetcher, err := urlfetch.New(cfg)
+		if err != nil {
+			t.Fatalf("new fetcher: %v", err)
+		}
+		pdfService := service.NewPDFService(service.Config{Quality: defaultJPEGQual})
+		return handler.NewConvertHandler(pdfService, fetcher, nil, nil, logger, maxUploadBytes)
+	}
+	send := func(h http.Handler, body string) *httptest.ResponseRecorder {
+		return sendConvertRequest(t, h, bytes.NewBufferString(body), "application/json", testAPIKey)
+	}
+	// The test origin listens on loopback, which is only reachable when explicitly allowed.
+	trusted := urlfetch.Config{MaxBytes: maxUploadBytes, AllowedNetworks: []string{"127.0.0.0/8", "::1/128"}}
+
+	t.Run("success", func(t *testing.T) {
+		rec := send(newHandler(t, trusted), `{"url":"`+origin.URL+`/docs/report.pdf","pages":"1-2","quality":70}`)
+		res := rec.Result()
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", res.StatusCode, rec.Body.String())
+		}
+		if res.Header.Get("Content-Type") != "application/zip" {
+			t.Fatalf("expected zip, got %s", res.Header.Get("Content-Type"))
+		}
+		if cd := res.Header.Get("Content-Disposition"); !strings.Contains(cd, "report.zip") {
+			t.Fatalf("expected filename from url, got %s", cd)
+		}
+	})
+
+	t.Run("loopback blocked by default", func(t *testing.T) {
+		rec := send(newHandler(t, urlfetch.Config{MaxBytes: maxUploadBytes}), `{"url":"`+origin.URL+`/docs/report.pdf"}`)
+		assertJSONError(t, rec, http.StatusBadRequest, "url not allowed")
+	})
+
+	t.Run("host not on allowlist", func(t *testing.T) {
+		cfg := trusted
+		cfg.AllowedHosts = []string{"docs.example.com"}
+		rec := send(newHandler(t, cfg), `{"url":"`+origin.URL+`/docs/report.pdf"}`)
+		assertJSONError(t, rec, http.StatusBadRequest, "url not allowed")
+	})
+
+	t.Run("not a pdf", func(t *testing.T) {
+		rec := send(newHandler(t, trusted), `{"url":"`+origin.URL+`/page.html"}`)
+		assertJSONError(t, rec, http.StatusBadRequest, "url must point to a pdf")
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		cfg := trusted
+		cfg.MaxBytes = 1024
+		rec := send(newHandler(t, cfg), `{"url":"`+origin.URL+`/huge.pdf"}`)
+		assertJSONError(t, rec, http.StatusRequestEntityTooLarge, "file too large")
+	})
+
+	t.Run("upstream error", func(t *testing.T) {
+		rec := send(newHandler(t, trusted), `{"url":"`+origin.URL+`/missing.pdf"}`)
+		assertJSONError(t, rec, http.StatusBadGateway, "failed to fetch url")
+	})
+
+	t.Run("invalid scheme", func(t *testing.T) {
+		rec := send(newHandler(t, trusted), `{"url":"file:///etc/passwd"}`)
+		assertJSONError(t, rec, http.StatusBadRequest, "invalid url parameter")
+	})
+
+	t.Run("missing url", func(t *testing.T) {
+		rec := send(newHandler(t, trusted), `{"pages":"1"}`)
+		assertJSONError(t, rec, http.StatusBadRequest, "url field is required")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		h := handler.NewConvertHandler(service.NewPDFService(service.Config{}), nil, nil, nil, logger, maxUploadBytes)
+		rec := send(h, `{"url":"`+origin.URL+`/docs/report.pdf"}`)
+		assertJSONError(t, rec, http.StatusUnsupportedMediaType, "url input is disabled")
+	})
+}
+
+func TestConvertEndpoint_InputTypes(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	var opened []string
+	restore := service.SetDocumentOpenerForTest(func(path string) (service.Document, error) {
+		opened = append(opened, filepath.Ext(path))
+		return &fakeDocument{pages: 2, img: image.NewRGBA(image.Rect(0, 0, 1, 1))}, nil
+	})
+	t.Cleanup(restore)
+
+	inputs, err := doctype.NewRegistry([]string{"pdf", "tiff", "epub"})
+	if err != nil {
+		t.Fatalf("new registry: %v", err)
+	}
+	tiff := append([]byte("II*\x00"), make([]byte, 60)...)
+	newHandler := func(inputs *doctype.Registry) http.Handler {
+		return handler.NewConvertHandler(service.NewPDFService(service.Config{}), nil, nil, inputs, logger, maxUploadBytes)
+	}
+
+	t.Run("multipart", func(t *testing.T) {
+		body, contentType := createMultipartBody(t, "scan.TIF", tiff)
+		rec := sendConvertRequest(t, newHandler(inputs), body, contentType, testAPIKey)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if got := opened[len(opened)-1]; got != ".tiff" {
+			t.Fatalf("expected a .tiff temp file, got %q", got)
+		}
+		if !strings.Contains(rec.Header().Get("Content-Disposition"), `filename="scan.jpg"`) {
+			t.Fatalf("unexpected Content-Disposition %s", rec.Header().Get("Content-Disposition"))
+		}
+	})
+
+	t.Run("raw body typed by content type", func(t *testing.T) {
+		rec := sendConvertRequest(t, newHandler(inputs), bytes.NewBuffer(tiff), "image/tiff", testAPIKey)
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Header().Get("Content-Disposition"), `filename="document.jpg"`) {
+			t.Fatalf("expected 200 for document.tiff, got %d: %s", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("magic bytes must match", func(t *testing.T) {
+		body, contentType := createMultipartBody(t, "book.epub", tiff)
+		rec := sendConvertRequest(t, newHandler(inputs), body, contentType, testAPIKey)
+		assertJSONError(t, rec, http.StatusBadRequest, "file content does not match its type")
+	})
+
+	t.Run("not allowed", func(t *testing.T) {
+		body, contentType := createMultipartBody(t, "comic.cbz", []byte("PK\x03\x04"))
+		rec := sendConvertRequest(t, newHandler(inputs), body, contentType, testAPIKey)
+		assertJSONError(t, rec, http.StatusBadRequest, "file must be a pdf, epub or tiff")
+
+		rec = sendConvertRequest(t, newHandler(nil), bytes.NewBuffer(tiff), "image/tiff", testAPIKey)
+		assertJSONError(t, rec, http.StatusBadRequest, "file must be a pdf")
+	})
+}
+
+func TestConvertEndpoint_RenderQueue(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	restore := service.SetDocumentOpenerForTest(func(string) (service.Document, error) {
+		return &fakeDocument{pages: 1, img: image.NewRGBA(image.Rect(0, 0, 1, 1))}, nil
+	})
+	t.Cleanup(restore)
+
+	renders := limiter.New(limiter.Config{MaxConcurrent: 1, QueueSize: 1, QueueTimeout: 20 * time.Millisecond})
+	convertHandler := handler.NewConvertHandler(service.NewPDFService(service.Config{}), nil, renders, nil, logger, maxUploadBytes)
+
+	send := func() *httptest.ResponseRecorder {
+		body, contentType := createMultipartBody(t, expectedFileName, minimalPDF())
+		return sendConvertRequest(t, convertHandler, body, contentType, testAPIKey)
+	}
+
+	release, err := renders.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	rec := send()
+	assertJSONError(t, rec, http.StatusServiceUnavailable, "server busy")
+	if rec.Header().Get("Retry-After") == "" {
+		t.Fatal("expected Retry-After header")
+	}
+
+	release()
+	if rec := send(); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 once the slot is free, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestConvertEndpoint_ETag(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	restore := service.SetDocumentOpenerForTest(func(string) (service.Document, error) {
+		return &fakeDocument{pages: 2, img: image.NewRGBA(image.Rect(0, 0, 1, 1))}, nil
+	})
+	t.Cleanup(restore)
+
+	pdfService := service.NewPDFService(service.Config{Cache: service.NewMemoryCache(1 << 20)})
+	convertHandler := handler.NewConvertHandler(pdfService, nil, nil, nil, logger, maxUploadBytes)
+	send := func(fields map[string]string) *httptest.ResponseRecorder {
+		body, contentType := createMultipartBodyWithFields(t, expectedFileName, minimalPDF(), fields)
+		return sendConvertRequest(t, convertHandler, body, contentType, testAPIKey)
+	}
+
+	first := send(nil)
+	second := send(map[string]string{"format": "jpeg"})
+	if first.Code != http.StatusOK || second.Code != http.StatusOK {
+		t.Fatalf("expected 200s, got %d and %d", first.Code, second.Code)
+	}
+	etag := first.Header().Get("ETag")
+	if etag == "" || !strings.HasPrefix(etag, `"`) {
+		t.Fatalf("expected a strong ETag, got %q", etag)
+	}
+	if second.Header().Get("ETag") != etag || !bytes.Equal(first.Body.Bytes(), second.Body.Bytes()) {
+		t.Fatal("expected the same result and ETag for an equivalent request")
+	}
+
+	if zip := send(map[string]string{"pages": "1-2"}); !strings.HasPrefix(zip.Header().Get("ETag"), `W/"`) {
+		t.Fatalf("expected a weak ETag for archives, got %q", zip.Header().Get("ETag"))
+	}
+	if failed := send(map[string]string{"pages": "3"}); failed.Code != http.StatusBadRequest || failed.Header().Get("ETag") != "" {
+		t.Fatalf("expected a 400 without ETag, got %d with %q", failed.Code, failed.Header().Get("ETag"))
+	}
+
+	conditional := func(handler http.Handler, ifNoneMatch string) *httptest.ResponseRecorder {
+		body, contentType := createMultipartBody(t, expectedFileName, minimalPDF())
+		req := httptest.NewRequest(http.MethodPost, "/convert", body)
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("If-None-Match", ifNoneMatch)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := conditional(convertHandler, `"other", W/`+etag); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 || rec.Header().Get("ETag") != etag {
+		t.Fatalf("expected an empty 304 with the ETag, got %d with %q", rec.Code, rec.Header().Get("ETag"))
+	}
+	if rec := conditional(convertHandler, `"other"`); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for a stale ETag, got %d", rec.Code)
+	}
+
+	// Without a result cache the upload is not hashed, so there is no validator to match.
+	uncached := handler.NewConvertHandler(service.NewPDFService(service.Config{}), nil, nil, nil, logger, maxUploadBytes)
+	if rec := conditional(uncached, etag); rec.Code != http.StatusOK || rec.Header().Get("ETag") != "" {
+		t.Fatalf("expected a 200 without ETag, got %d with %q", rec.Code, rec.Header().Get("ETag"))
+	}
+}
+
+func TestConvertEndpoint_Batch(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	restore := service.SetDocumentOpenerForTest(func(path string) (service.Document, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		pages := 2
+		if bytes.Contains(data, []byte("/Count 0")) {
+			pages = 0
+		}
+		return &fakeDocument{pages: pages, img: image.NewRGBA(image.Rect(0, 0, 1, 1))}, nil
+	})
+	t.Cleanup(restore)
+
+	renders := limiter.New(limiter.Config{MaxConcurrent: 1, QueueSize: 1, QueueTimeout: 20 * time.Millisecond})
+	convertHandler := handler.NewConvertHandler(service.NewPDFService(service.Config{}), nil, renders, nil, logger, maxUploadBytes)
+	emptyPDF := bytes.Replace(minimalPDF(), []byte("/Count 1"), []byte("/Count 0"), 1)
+	files := []uploadFile{
+		{"report.pdf", minimalPDF()},
+		{"empty.pdf", emptyPDF},
+		{"notes.txt", []byte("plain text")},
+		{"report.pdf", minimalPDF()},
+		{"fake.pdf", []byte("not a pdf at all")},
+	}
+
+	type manifest struct {
+		Succeeded int `json:"succeeded"`
+		Failed    int `json:"failed"`
+		Files     []struct {
+			File   string `json:"file"`
+			Status int    `json:"status"`
+			Error  string `json:"error"`
+			Pages  []struct {
+				Page int    `json:"page"`
+				Name string `json:"name"`
+			} `json:"pages"`
+		} `json:"files"`
+	}
+	checkManifest := func(t *testing.T, data []byte) {
+		t.Helper()
+		var m manifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			t.Fatalf("decode manifest: %v", err)
+		}
+		if m.Succeeded != 2 || m.Failed != 3 || len(m.Files) != len(files) {
+			t.Fatalf("unexpected manifest summary: %+v", m)
+		}
+		want := []struct {
+			status int
+			err    string
+		}{
+			{http.StatusOK, ""},
+			{http.StatusBadRequest, "pdf has no pages"},
+			{http.StatusBadRequest, "file must be a pdf"},
+			{http.StatusOK, ""},
+			{http.StatusBadRequest, "file content does not match its type"},
+		}
+		for i, w := range want {
+			if got := m.Files[i]; got.File != files[i].name || got.Status != w.status || got.Error != w.err {
+				t.Fatalf("file %d: expected %s %d %q, got %+v", i, files[i].name, w.status, w.err, got)
+			}
+		}
+		if pages := m.Files[3].Pages; len(pages) != 2 || !strings.HasPrefix(pages[0].Name, "report-p001-2.") || pages[1].Page != 2 {
+			t.Fatalf("expected de-duplicated page names for the second report, got %+v", pages)
+		}
+	}
+
+	t.Run("zip", func(t *testing.T) {
+		body, contentType := createMultiFileBody(t, files, map[string]string{"pages": "1-2"})
+		rec := sendConvertRequest(t, convertHandler, body, contentType, testAPIKey)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if rec.Header().Get("Content-Type") != "application/zip" || rec.Header().Get("ETag") != "" {
+			t.Fatalf("unexpected headers %v", rec.Header())
+		}
+		zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+		if err != nil {
+			t.Fatalf("open zip: %v", err)
+		}
+		var names []string
+		for _, f := range zr.File {
+			names = append(names, f.Name)
+		}
+		if got := strings.Join(names, ","); got != "manifest.json,report-p001.jpg,report-p002.jpg,report-p001-2.jpg,report-p002-2.jpg" {
+			t.Fatalf("unexpected entries %s", got)
+		}
+		mf, err := zr.File[0].Open()
+		if err != nil {
+			t.Fatalf("open manifest: %v", err)
+		}
+		data, _ := io.ReadAll(mf)
+		checkManifest(t, data)
+	})
+
+	t.Run("multipart", func(t *testing.T) {
+		body, contentType := createMultiFileBody(t, files, map[string]string{"pages": "1-2", "output": "multipart", "format": "png"})
+		rec := sendConvertRequest(t, convertHandler, body, contentType, testAPIKey)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		mediaType, params, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+		if err != nil || mediaType != "multipart/mixed" {
+			t.Fatalf("unexpected Content-Type %q", rec.Header().Get("Content-Type"))
+		}
+		mr := multipart.NewReader(rec.Body, params["boundary"])
+		var parts []string
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("read part: %v", err)
+			}
+			data, _ := io.ReadAll(part)
+			if len(parts) == 0 {
+				if part.Header.Get("Content-Type") != "application/json" {
+					t.Fatalf("expected the manifest first, got %v", part.Header)
+				}
+				checkManifest(t, data)
+			} else if part.Header.Get("Content-Type") != "image/png" {
+				t.Fatalf("unexpected page part %v", part.Header)
+			}
+			parts = append(parts, part.FileName())
+		}
+		if len(parts) != 5 || parts[1] != "report-p001.png" {
+			t.Fatalf("unexpected parts %v", parts)
+		}
+	})
+
+	t.Run("busy files are reported per file", func(t *testing.T) {
+		release, err := renders.Acquire(context.Background())
+		if err != nil {
+			t.Fatalf("acquire: %v", err)
+		}
+		defer release()
+		body, contentType := createMultiFileBody(t, files[:2], nil)
+		rec := sendConvertRequest(t, convertHandler, body, contentType, testAPIKey)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+		if err != nil || len(zr.File) != 1 {
+			t.Fatalf("expected only a manifest, got %v", err)
+		}
+		mf, _ := zr.File[0].Open()
+		var m manifest
+		if err := json.NewDecoder(mf).Decode(&m); err != nil {
+			t.Fatalf("decode manifest: %v", err)
+		}
+		for _, f := range m.Files {
+			if f.Status != http.StatusServiceUnavailable || f.Error != "server busy" {
+				t.Fatalf("expected busy files, got %+v", m.Files)
+			}
+		}
+	})
+
+	t.Run("invalid output", func(t *testing.T) {
+		body, contentType := createMultiFileBody(t, files[:2], map[string]string{"output": "image"})
+		rec := sendConvertRequest(t, convertHandler, body, contentType, testAPIKey)
+		assertJSONError(t, rec, http.StatusBadRequest, "invalid output parameter")
+	})
+
+	t.Run("too many files", func(t *testing.T) {
+		many := make([]uploadFile, 21)
+		for i := range many {
+			many[i] = uploadFile{fmt.Sprintf("f%d.pdf", i), minimalPDF()}
+		}
+		body, contentType := createMultiFileBody(t, many, nil)
+		rec := sendConvertRequest(t, convertHandler, body, contentType, testAPIKey)
+		assertJSONError(t, rec, http.StatusBadRequest, "too many files")
+	})
+}
+
+func TestContactSheetEndpoint(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	restore := service.SetDocumentOpenerForTest(func(string) (service.Document, error) {
+		return &fakeDocument{pages: 5, img: image.NewRGBA(image.Rect(0, 0, 10, 13))}, nil
+	})
+	t.Cleanup(restore)
+
+	pdfService := service.NewPDFService(service.Config{Quality: defaultJPEGQual})
+	sheetHandler := auth.APIKeyMiddleware(auth.APIKeyMiddlewareConfig{
+		StaticKeys: []string{testAPIKey},
+		Logger:     logger,
+	})(handler.NewContactSheetHandler(pdfService, nil, nil, logger, maxUploadBytes))
+
+	t.Run("grid", func(t *testing.T) {
+		body, contentType := createMultipartBodyWithFields(t, expectedFileName, minimalPDF(), map[string]string{
+			"columns": "2", "thumbWidth": "100", "padding": "10", "format": "png",
+		})
+		rec := sendConvertRequest(t, sheetHandler, body, contentType, testAPIKey)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if !strings.Contains(rec.Header().Get("Content-Disposition"), `filename="sample-sheet.png"`) {
+			t.Fatalf("unexpected Content-Disposition %q", rec.Header().Get("Content-Disposition"))
+		}
+		cfg, _, err := image.DecodeConfig(rec.Body)
+		if err != nil {
+			t.Fatalf("decode sheet: %v", err)
+		}
+		// Two columns of 100px plus three 10px gaps; three rows of five pages.
+		if cfg.Width != 230 || cfg.Height <= 3*129 {
+			t.Fatalf("unexpected sheet size %dx%d", cfg.Width, cfg.Height)
+		}
+	})
+
+	t.Run("invalid background", func(t *testing.T) {
+		body, contentType := createMultipartBodyWithFields(t, expectedFileName, minimalPDF(), map[string]string{"background": "blue"})
+		rec := sendConvertRequest(t, sheetHandler, body, contentType, testAPIKey)
+		assertJSONError(t, rec, http.StatusBadRequest, "invalid background parameter")
+	})
+
+	t.Run("rejects dpi", func(t *testing.T) {
+		body, contentType := createMultipartBodyWithFields(t, expectedFileName, minimalPDF(), map[string]string{"dpi": "150"})
+		rec := sendConvertRequest(t, sheetHandler, body, contentType, testAPIKey)
+		assertJSONError(t, rec, http.StatusBadRequest, "use thumbWidth to size contact sheets")
+	})
+
+	t.Run("rejects vector format", func(t *testing.T) {
+		body, contentType := createMultipartBodyWithFields(t, expectedFileName, minimalPDF(), map[string]string{"format": "svg"})
+		rec := sendConvertRequest(t, sheetHandler, body, contentType, testAPIKey)
+		assertJSONError(t, rec, http.StatusBadRequest, "unsupported format")
+	})
+}
+
+func TestExtractTextEndpoint(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	blocks := []service.TextBlock{{
+		BBox:  service.Rect{X: 72, Y: 72, W: 100, H: 28},
+		Lines: []service.TextLine{{BBox: service.Rect{X: 72, Y: 72, W: 100, H: 14}, Text: "Hello"}, {Text: "world"}},
+	}}
+	restore := service.SetDocumentOpenerForTest(func(string) (service.Document, error) {
+		return &fakeDocument{pages: 2, blocks: blocks}, nil
+	})
+	t.Cleanup(restore)
+
+	pdfService := service.NewPDFService(service.Config{})
+	textHandler := auth.APIKeyMiddleware(auth.APIKeyMiddlewareConfig{
+		StaticKeys: []string{testAPIKey},
+		Logger:     logger,
+	})(handler.NewExtractTextHandler(pdfService, nil, nil, logger, maxUploadBytes))
+
+	type response struct {
+		Pages []service.PageText `json:"pages"`
+	}
+
+	t.Run("plain text", func(t *testing.T) {
+		body, contentType := createMultipartBody(t, expectedFileName, minimalPDF())
+		rec := sendConvertRequest(t, textHandler, body, contentType, testAPIKey)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp response
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if len(resp.Pages) != 2 || resp.Pages[1].Page != 2 || resp.Pages[0].Text != "Hello\nworld" {
+			t.Fatalf("unexpected pages %+v", resp.Pages)
+		}
+		if resp.Pages[0].Blocks != nil {
+			t.Fatalf("expected blocks to be omitted, got %+v", resp.Pages[0].Blocks)
+		}
+	})
+
+	t.Run("blocks", func(t *testing.T) {
+		body, contentType := createMultipartBodyWithFields(t, expectedFileName, minimalPDF(), map[string]string{"pages": "1", "blocks": "true"})
+		rec := sendConvertRequest(t, textHandler, body, contentType, testAPIKey)
+		var resp response
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if len(resp.Pages) != 1 || len(resp.Pages[0].Blocks) != 1 || resp.Pages[0].Blocks[0].Lines[0].BBox.W != 100 {
+			t.Fatalf("unexpected pages %+v", resp.Pages)
+		}
+	})
+
+	t.Run("invalid blocks", func(t *testing.T) {
+		body, contentType := createMultipartBodyWithFields(t, expectedFileName, minimalPDF(), map[string]string{"blocks": "sometimes"})
+		rec := sendConvertRequest(t, textHandler, body, contentType, testAPIKey)
+		assertJSONError(t, rec, http.StatusBadRequest, "invalid blocks parameter")
+	})
+
+	t.Run("missing api key", func(t *testing.T) {
+		body, contentType := createMultipartBody(t, expectedFileName, minimalPDF())
+		rec := sendConvertRequest(t, textHandler, body, contentType, "")
+		assertJSONError(t, rec, http.StatusUnauthorized, "unauthorized")
+	})
+}
+
+func TestInspectEndpoint(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	restore := service.SetDocumentOpenerForTest(func(string) (service.Document, error) {
+		return &fakeDocument{
+			pages: 2,
+			meta:  map[string]string{"format": "PDF 1.7", "encryption": "None", "title": "Handbook", "creationDate": "D:20250101120000Z"},
+			toc:   []service.OutlineItem{{Level: 1, Title: "Chapter 1", Page: 2}},
+		}, nil
+	})
+	t.Cleanup(restore)
+
+	pdfService := service.NewPDFService(service.Config{})
+	inspectHandler := auth.APIKeyMiddleware(auth.APIKeyMiddlewareConfig{
+		StaticKeys: []string{testAPIKey},
+		Logger:     logger,
+		SkipUsage:  true,
+	})(handler.NewInspectHandler(pdfService, nil, nil, logger, maxUploadBytes))
+
+	t.Run("metadata", func(t *testing.T) {
+		body, contentType := createMultipartBody(t, expectedFileName, minimalPDF())
+		rec := sendConvertRequest(t, inspectHandler, body, contentType, testAPIKey)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var info service.DocumentInfo
+		if err := json.NewDecoder(rec.Body).Decode(&info); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if info.PageCount != 2 || len(info.Pages) != 2 || info.Pages[0].Width != 612 || info.Title != "Handbook" {
+			t.Fatalf("unexpected info %+v", info)
+		}
+		if info.CreatedAt == nil || info.CreatedAt.Year() != 2025 || info.Encrypted {
+			t.Fatalf("unexpected dates or encryption %+v", info)
+		}
+		if len(info.Outline) != 1 || info.Outline[0].Page != 2 {
+			t.Fatalf("unexpected outline %+v", info.Outline)
+		}
+	})
+
+	t.Run("encrypted without password", func(t *testing.T) {
+		restore := service.SetDocumentOpenerForTest(func(string) (service.Document, error) {
+			return &lockedDocument{
+				fakeDocument: fakeDocument{pages: 4, meta: map[string]string{"encryption": "Standard V4 R4 128-bit AES"}},
+				password:     "s3cret",
+			}, nil
+		})
+		t.Cleanup(restore)
+
+		body, contentType := createMultipartBody(t, expectedFileName, minimalPDF())
+		rec := sendConvertRequest(t, inspectHandler, body, contentType, testAPIKey)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var info service.DocumentInfo
+		if err := json.NewDecoder(rec.Body).Decode(&info); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if !info.Encrypted || !info.NeedsPassword || info.PageCount != 4 {
+			t.Fatalf("unexpected info %+v", info)
+		}
+
+		body, contentType = createMultipartBodyWithFields(t, expectedFileName, minimalPDF(), map[string]string{"password": "wrong"})
+		rec = sendConvertRequest(t, inspectHandler, body, contentType, testAPIKey)
+		assertJSONError(t, rec, http.StatusUnprocessableEntity, "incorrect pdf password")
+	})
+
+	t.Run("rejects non pdf", func(t *testing.T) {
+		body, contentType := createMultipartBody(t, "notes.txt", []byte("hello"))
+		rec := sendConvertRequest(t, inspectHandler, body, contentType, testAPIKey)
+		assertJSONError(t, rec, http.StatusBadRequest, "file must be a pdf")
+	})
+
+	t.Run("waits for a render slot", func(t *testing.T) {
+		renders := limiter.New(limiter.Config{MaxConcurrent: 1, QueueSize: 1, QueueTimeout: 20 * time.Millisecond})
+		limited := handler.NewInspectHandler(pdfService, renders, nil, logger, maxUploadBytes)
+		release, err := renders.Acquire(context.Background())
+		if err != nil {
+			t.Fatalf("acquire: %v", err)
+		}
+		defer release()
+
+		body, contentType := createMultipartBody(t, expectedFileName, minimalPDF())
+		rec := sendConvertRequest(t, limited, body, contentType, testAPIKey)
+		assertJSONError(t, rec, http.StatusServiceUnavailable, "server busy")
+	})
+}
+
+func TestComposeEndpoint(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	repo := newTestRepository()
+	keyService := auth.NewKeyService(repo, logger, nil, auth.ServiceConfig{})
+	pdfService := service.NewPDFService(service.Config{})
+	composeHandler := auth.APIKeyMiddleware(auth.APIKeyMiddlewareConfig{
+		StaticKeys:     []string{testAPIKey},
+		KeyService:     keyService,
+		Logger:         logger,
+		FeatureEnabled: true,
+	})(handler.NewComposeHandler(pdfService, nil, logger, maxUploadBytes))
+
+	var jpg, pngData bytes.Buffer
+	if err := jpeg.Encode(&jpg, image.NewRGBA(image.Rect(0, 0, 30, 20)), nil); err != nil {
+		t.Fatalf("encode jpeg: %v", err)
+	}
+	if err := png.Encode(&pngData, image.NewGray(image.Rect(0, 0, 20, 30))); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+
+	t.Run("bundles images in order", func(t *testing.T) {
+		body, contentType := createMultiFileBody(t, []uploadFile{{"cover.jpg", jpg.Bytes()}, {"page.png", pngData.Bytes()}}, map[string]string{
+			"pageSize": "a4", "fit": "fit", "margin": "36",
+		})
+		rec := sendConvertRequest(t, composeHandler, body, contentType, testAPIKey)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/pdf" {
+			t.Fatalf("unexpected Content-Type %q", ct)
+		}
+		if !strings.Contains(rec.Header().Get("Content-Disposition"), `filename="cover.pdf"`) {
+			t.Fatalf("unexpected Content-Disposition %q", rec.Header().Get("Content-Disposition"))
+		}
+		out := rec.Body.String()
+		if !strings.HasPrefix(out, "%PDF-") || !strings.Contains(out, "/Count 2") || !strings.HasSuffix(out, "%%EOF\n") {
+			t.Fatalf("response is not a two-page pdf: %.200q", out)
+		}
+		// The JPEG is embedded untouched ahead of the PNG, which is recompressed.
+		if first, second := strings.Index(out, "/DCTDecode"), strings.Index(out, "/FlateDecode"); first < 0 || second < first {
+			t.Fatalf("expected the jpeg before the png, got offsets %d and %d", first, second)
+		}
+	})
+
+	t.Run("requires api key", func(t *testing.T) {
+		body, contentType := createMultiFileBody(t, []uploadFile{{"cover.jpg", jpg.Bytes()}}, nil)
+		rec := sendConvertRequest(t, composeHandler, body, contentType, "")
+		assertJSONError(t, rec, http.StatusUnauthorized, "unauthorized")
+	})
+
+	t.Run("spends temporary key usage", func(t *testing.T) {
+		resp, err := keyService.IssueTemporaryKey(context.Background(), auth.IssueRequest{
+			Label:      "trial",
+			UsageLimit: 1,
+			TTL:        time.Hour,
+			Operator:   "tester",
+		})
+		if err != nil {
+			t.Fatalf("issue temporary key: %v", err)
+		}
+		body, contentType := createMultiFileBody(t, []uploadFile{{"cover.jpg", jpg.Bytes()}}, nil)
+		if rec := sendConvertRequest(t, composeHandler, body, contentType, resp.Key); rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		body, contentType = createMultiFileBody(t, []uploadFile{{"cover.jpg", jpg.Bytes()}}, nil)
+		assertJSONError(t, sendConvertRequest(t, composeHandler, body, contentType, resp.Key), http.StatusTooManyRequests, "usage limit reached")
+	})
+
+	t.Run("rejects other file types", func(t *testing.T) {
+		body, contentType := createMultiFileBody(t, []uploadFile{{"cover.jpg", jpg.Bytes()}, {expectedFileName, minimalPDF()}}, nil)
+		rec := sendConvertRequest(t, composeHandler, body, contentType, testAPIKey)
+		assertJSONError(t, rec, http.StatusBadRequest, "file must be a png or jpeg")
+	})
+
+	t.Run("rejects mislabelled content", func(t *testing.T) {
+		body, contentType := createMultiFileBody(t, []uploadFile{{"cover.png", jpg.Bytes()}}, nil)
+		rec := sendConvertRequest(t, composeHandler, body, contentType, testAPIKey)
+		assertJSONError(t, rec, http.StatusBadRequest, "file content does not match its type")
+	})
+
+	t.Run("requires files", func(t *testing.T) {
+		body, contentType := createMultiFileBody(t, nil, map[string]string{"pageSize": "a4"})
+		rec := sendConvertRequest(t, composeHandler, body, contentType, testAPIKey)
+		assertJSONError(t, rec, http.StatusBadRequest, "file field is required")
+	})
+
+	t.Run("invalid page size", func(t *testing.T) {
+		body, contentType := createMultiFileBody(t, []uploadFile{{"cover.jpg", jpg.Bytes()}}, map[string]string{"pageSize": "b7"})
+		rec := sendConvertRequest(t, composeHandler, body, contentType, testAPIKey)
+		assertJSONError(t, rec, http.StatusBadRequest, "invalid pageSize parameter")
+	})
+
+	t.Run("margin too large", func(t *testing.T) {
+		body, contentType := createMultiFileBody(t, []uploadFile{{"cover.jpg", jpg.Bytes()}}, map[string]string{"pageSize": "100x100", "margin": "60"})
+		rec := sendConvertRequest(t, composeHandler, body, contentType, testAPIKey)
+		assertJSONError(t, rec, http.StatusBadRequest, "invalid render options")
+	})
+
+	t.Run("auto page too large", func(t *testing.T) {
+		body, contentType := createMultiFileBody(t, []uploadFile{{"cover.jpg", jpg.Bytes()}}, map[string]string{"margin": "20000"})
+		rec := sendConvertRequest(t, composeHandler, body, contentType, testAPIKey)
+		assertJSONError(t, rec, http.StatusBadRequest, "invalid render options")
+	})
+}
+
+func TestJobsEndpoint(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	restore := service.SetDocumentOpenerForTest(func(string) (service.Document, error) {
+		return &fakeDocument{pages: 3, img: image.NewRGBA(image.Rect(0, 0, 4, 4))}, nil
+	})
+	t.Cleanup(restore)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	manager := jobs.NewManager(jobs.NewMemoryStore(jobs.MemoryStoreConfig{}), logger, jobs.Config{Workers: 1})
+	manager.Start(ctx)
+	t.Cleanup(func() { cancel(); manager.Wait() })
+
+	pdfService := service.NewPDFService(service.Config{Quality: defaultJPEGQual})
+	jobsHandler := auth.APIKeyMiddleware(auth.APIKeyMiddlewareConfig{
+		StaticKeys: []string{testAPIKey, "other-key"},
+		Logger:     logger,
+	})(handler.NewJobsHandler(pdfService, manager, nil, nil, logger, maxUploadBytes))
+
+	get := func(path, apiKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-API-Key", apiKey)
+		rec := httptest.NewRecorder()
+		jobsHandler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	body, contentType := createMultipartBodyWithFields(t, expectedFileName, minimalPDF(), map[string]string{"pages": "1-3"})
+	req := httptest.NewRequest(http.MethodPost, "/jobs", body)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-API-Key", testAPIKey)
+	rec := httptest.NewRecorder()
+	jobsHandler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var submitted map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &submitted); err != nil {
+		t.Fatalf("decode submit response: %v", err)
+	}
+	id, _ := submitted["id"].(string)
+	if id == "" || rec.Header().Get("Location") != "/jobs/"+id {
+		t.Fatalf("unexpected submit response %v (Location %q)", submitted, rec.Header().Get("Location"))
+	}
+
+	var status map[string]interface{}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		rec = get("/jobs/"+id, testAPIKey)
+		if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+			t.Fatalf("decode status: %v", err)
+		}
+		if status["status"] == "succeeded" || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if status["status"] != "succeeded" || status["pagesDone"] != float64(3) || status["pagesTotal"] != float64(3) {
+		t.Fatalf("unexpected job status %v", status)
+	}
+
+	if rec := get("/jobs/"+id, "other-key"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for another key, got %d", rec.Code)
+	}
+
+	rec = get("/jobs/"+id+"/result", testAPIKey)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("unexpected result response %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatalf("open zip: %v", err)
+	}
+	if len(zr.File) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(zr.File))
+	}
+
+	if rec := get("/jobs/unknown", testAPIKey); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown job, got %d", rec.Code)
+	}
+}
+
+func TestJobsEndpoint_WaitsForRenderSlot(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	restore := service.SetDocumentOpenerForTest(func(string) (service.Document, error) {
+		return &fakeDocument{pages: 1, img: image.NewRGBA(image.Rect(0, 0, 4, 4))}, nil
+	})
+	t.Cleanup(restore)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	manager := jobs.NewManager(jobs.NewMemoryStore(jobs.MemoryStoreConfig{}), logger, jobs.Config{Workers: 1})
+	manager.Start(ctx)
+	t.Cleanup(func() { cancel(); manager.Wait() })
+
+	renders := limiter.New(limiter.Config{MaxConcurrent: 1, QueueSize: 1, QueueTimeout: 20 * time.Millisecond})
+	jobsHandler := handler.NewJobsHandler(service.NewPDFService(service.Config{}), manager, renders, nil, logger, maxUploadBytes)
+
+	release, err := renders.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	body, contentType := createMultipartBody(t, expectedFileName, minimalPDF())
+	req := httptest.NewRequest(http.MethodPost, "/jobs", body)
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	jobsHandler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var submitted struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &submitted); err != nil {
+		t.Fatalf("decode submit response: %v", err)
+	}
+
+	// The job outlasts the queue timeout without failing while a synchronous render holds the slot.
+	time.Sleep(100 * time.Millisecond)
+	if job, err := manager.Get(context.Background(), submitted.ID); err != nil || job.Finished() || job.PagesTotal != 0 {
+		t.Fatalf("expected the job to wait for the render slot, got %+v (%v)", job, err)
+	}
+
+	release()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := manager.Get(context.Background(), submitted.ID)
+		if err != nil {
+			t.Fatalf("get job: %v", err)
+		}
+		if job.Status == jobs.StatusSucceeded {
+			break
+		}
+		if job.Finished() || time.Now().After(deadline) {
+			t.Fatalf("expected the job to succeed once the slot is free, got %+v", job)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestJobsEndpoint_Callback(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	restore := service.SetDocumentOpenerForTest(func(string) (service.Document, error) {
+		return &fakeDocument{pages: 1, img: image.NewRGBA(image.Rect(0, 0, 4, 4))}, nil
+	})
+	t.Cleanup(restore)
+
+	received := make(chan jobs.WebhookPayload, 1)
+	callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(jobs.SignatureHeader) != "sha256="+jobs.SignPayload(testAPIKey, r.Header.Get(jobs.TimestampHeader), body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var payload jobs.WebhookPayload
+		_ = json.Unmarshal(body, &payload)
+		received <- payload
+	}))
+	defer callback.Close()
+
+	// The callback server listens on loopback, which the dispatcher refuses unless allowed.
+	guard, err := urlfetch.NewGuard(nil, []string{"127.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("new guard: %v", err)
+	}
+	webhooks := jobs.NewWebhookDispatcher(logger, jobs.WebhookConfig{
+		Guard:     guard,
+		ResultURL: func(id string) string { return "https://api.example.com/jobs/" + id + "/result" },
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	manager := jobs.NewManager(jobs.NewMemoryStore(jobs.MemoryStoreConfig{}), logger, jobs.Config{Workers: 1, Notifier: webhooks})
+	manager.Start(ctx)
+	t.Cleanup(func() { cancel(); manager.Wait(); webhooks.Close() })
+
+	pdfService := service.NewPDFService(service.Config{Quality: defaultJPEGQual})
+	jobsHandler := auth.APIKeyMiddleware(auth.APIKeyMiddlewareConfig{
+		StaticKeys: []string{testAPIKey},
+		Logger:     logger,
+	})(handler.NewJobsHandler(pdfService, manager, nil, nil, logger, maxUploadBytes))
+
+	body, contentType := createMultipartBodyWithFields(t, expectedFileName, minimalPDF(), map[string]string{"callbackUrl": callback.URL})
+	req := httptest.NewRequest(http.MethodPost, "/jobs", body)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-API-Key", testAPIKey)
+	rec := httptest.NewRecorder()
+	jobsHandler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	select {
+	case payload := <-received:
+		if payload.Status != jobs.StatusSucceeded || payload.PagesTotal != 1 || !strings.HasSuffix(payload.ResultURL, "/result") {
+			t.Fatalf("unexpected payload %+v", payload)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("callback was not delivered")
+	}
+
+	body, contentType = createMultipartBodyWithFields(t, expectedFileName, minimalPDF(), map[string]string{"callbackUrl": "file:///etc/passwd"})
+	req = httptest.NewRequest(http.MethodPost, "/jobs", body)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-API-Key", testAPIKey)
+	rec = httptest.NewRecorder()
+	jobsHandler.ServeHTTP(rec, req)
+	assertJSONError(t, rec, http.StatusBadRequest, "invalid callbackUrl parameter")
+}
+
 func createMultipartBody(t *testing.T, filename string, fileBytes []byte) (*bytes.Buffer, string) {
+	t.Helper()
+	return createMultipartBodyWithFields(t, filename, fileBytes, nil)
+}
+
+func createMultipartBodyWithFields(t *testing.T, filename string, fileBytes []byte, fields map[string]string) (*bytes.Buffer, string) {
 	t.Helper()
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
+	for name, value := range fields {
+		if err := writer.WriteField(name, value); err != nil {
+			t.Fatalf("WriteField %s: %v", name, err)
+		}
+	}
 	part, err := writer.CreateFormFile(multipartField, filename)
 	if err != nil {
 		t.Fatalf("CreateFormFile: %v", err)
@@ -182,6 +1446,36 @@ func createMultipartBody(t *testing.T, filename string, fileBytes []byte) (*byte
 	return body, writer.FormDataContentType()
 }
 
+type uploadFile struct {
+	name string
+	data []byte
+}
+
+// createMultiFileBody writes every file as its own "file" part, in order.
+func createMultiFileBody(t *testing.T, files []uploadFile, fields map[string]string) (*bytes.Buffer, string) {
+	t.Helper()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for name, value := range fields {
+		if err := writer.WriteField(name, value); err != nil {
+			t.Fatalf("WriteField %s: %v", name, err)
+		}
+	}
+	for _, f := range files {
+		part, err := writer.CreateFormFile(multipartField, f.name)
+		if err != nil {
+			t.Fatalf("CreateFormFile: %v", err)
+		}
+		if _, err := part.Write(f.data); err != nil {
+			t.Fatalf("write file: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("close writer: %v", err)
+	}
+	return body, writer.FormDataContentType()
+}
+
 func sendConvertRequest(t *testing.T, handler http.Handler, body *bytes.Buffer, contentType, apiKey string) *httptest.ResponseRecorder {
 	t.Helper()
 	req := httptest.NewRequest(http.MethodPost, "/convert", body)